@@ -1,37 +1,85 @@
 package main
 
 import (
+	"GoServerGames/internal/config"
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/logging"
 	"GoServerGames/internal/server"
+	"GoServerGames/internal/storage"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
-	// Check password is set
-	if _, err := server.GetPassword(); err != nil {
-		log.Fatal("GAME_PASSWORD environment variable must be set")
+	// JSON logs; the level is set from the config once it's loaded, and the
+	// admin API can change it while the server runs
+	logging.Setup(os.Stdout, slog.LevelInfo)
+
+	// Settings from the config file, environment variables and flags
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
 	}
+	logging.SetLevel(cfg.LogLevel)
+	slog.Info("Loaded configuration", "config", cfg)
 
-	sessionStore := server.NewSessionStore()
-	mm := server.NewMatchmaking()
+	sessionStore := server.NewSessionStore(cfg.Session)
+	// Throttles failed logins; behind Fly's proxy the client IP is in Fly-Client-IP
+	limiter := server.NewLimiter(cfg.Server.ClientIPHeader)
+	mm := server.NewMatchmaking(cfg)
 
-	// Start room tick processing
-	go mm.StartRoomTicks()
+	// Match history file (kept on a volume in production so it survives deploys)
+	store, err := storage.OpenFileStore(cfg.Storage.MatchesPath)
+	if err != nil {
+		logging.Fatal("Failed to open match history", "error", err)
+	}
+	if err := mm.SetMatchStore(store); err != nil {
+		logging.Fatal("Failed to load match history", "error", err)
+	}
 
-	// Serve static files from web directory
-	webDir := filepath.Join(".", "web")
-	if _, err := os.Stat(webDir); os.IsNotExist(err) {
-		log.Fatalf("Web directory not found: %s", webDir)
+	// Player accounts, kept next to the match history
+	accountStore, err := storage.OpenFileAccountStore(cfg.Storage.AccountsPath)
+	if err != nil {
+		logging.Fatal("Failed to open accounts", "error", err)
+	}
+	accounts, err := server.NewAccounts(accountStore, cfg.Auth.InviteCode)
+	if err != nil {
+		logging.Fatal("Failed to set up accounts", "error", err)
 	}
-	log.Printf("Serving static files from: %s", webDir)
-	
+	if accounts.InviteRequired() {
+		slog.Info("Registration requires the invite code set in GAME_PASSWORD")
+	}
+
+	// Invite links; set INVITE_SECRET so they keep working across restarts
+	invites, err := server.NewInvites(cfg.Auth.InviteSecret)
+	if err != nil {
+		logging.Fatal("Failed to set up invites", "error", err)
+	}
+
+	// Pair players waiting for a quick match
+	go mm.StartQuickMatch()
+
+	// Serve static files from web directory
+	webDir := cfg.Server.WebDir
+	slog.Info("Serving static files", "path", webDir)
+
 	// File server for static assets
 	fs := http.FileServer(http.Dir(webDir))
-	
+
 	// Handle root - serve index.html
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
@@ -52,77 +100,461 @@ func main() {
 			Username string `json:"username"`
 			Password string `json:"password"`
 			RoomCode string `json:"roomCode"`
+			// Sent instead of a room code to be paired with a stranger
+			QuickMatch *struct {
+				GameType string `json:"gameType"`
+				ByRating bool   `json:"byRating"`
+			} `json:"quickMatch"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			log.Printf("Login error: Failed to decode request: %v", err)
+			slog.Info("Login error: Failed to decode request", "error", err)
 			http.Error(w, "Invalid request", http.StatusBadRequest)
 			return
 		}
 
 		// Normalize room code: uppercase and remove non-alphanumeric
-		roomCode := strings.ToUpper(strings.TrimSpace(req.RoomCode))
-		roomCode = strings.Map(func(r rune) rune {
-			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-				return r
-			}
-			return -1
-		}, roomCode)
+		roomCode := server.NormalizeRoomCode(req.RoomCode)
 
-		if roomCode == "" {
-			log.Printf("Login error: Room code is empty or invalid (original: '%s')", req.RoomCode)
+		if roomCode == "" && req.QuickMatch == nil {
+			slog.Info("Login error: Room code is empty or invalid", "original", req.RoomCode)
 			http.Error(w, "Room code is required", http.StatusBadRequest)
 			return
 		}
+		if roomCode == "" && !game.IsRegistered(req.QuickMatch.GameType) {
+			http.Error(w, "Pick a game to find a quick match", http.StatusBadRequest)
+			return
+		}
 
-		log.Printf("Login attempt: username=%s, roomCode=%s", req.Username, roomCode)
+		slog.Info("Login attempt", logging.RoomCode(roomCode), "name", req.Username)
 
-		authenticated, err := server.Authenticate(req.Username, req.Password)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Failed logins are throttled by client IP and by username
+		ipKey, userKey := server.IPKey(limiter.ClientIP(r)), server.UserKey(req.Username)
+		if wait, blocked := limiter.Blocked(ipKey, userKey); blocked {
+			slog.Warn("Login throttled", logging.RoomCode(roomCode), "name", req.Username, "ip", ipKey)
+			server.CountLogin(server.LoginThrottled)
+			server.TooManyRequests(w, wait)
 			return
 		}
 
-		if !authenticated {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		account, ok := accounts.Authenticate(req.Username, req.Password)
+		if !ok {
+			server.CountLogin(server.LoginFailure)
+			if wait := limiter.Fail(ipKey, userKey); wait > 0 {
+				slog.Warn("Login failed - blocked", logging.RoomCode(roomCode), "name", req.Username, "ip", ipKey, "wait", wait.String())
+				server.TooManyRequests(w, wait)
+				return
+			}
+			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
 		}
+		limiter.Succeed(userKey)
+		server.CountLogin(server.LoginSuccess)
 
-		session, err := sessionStore.CreateSession(req.Username, roomCode)
+		// Players logging in with a tournament code go straight to their current match
+		if matchCode, ok := mm.TournamentRoomCode(roomCode, account.Username); ok {
+			slog.Info("Login: Player is playing a tournament match", logging.RoomCode(matchCode), "name", account.Username, "tournament", roomCode)
+			roomCode = matchCode
+		}
+
+		var session *server.Session
+		var err error
+		if roomCode == "" {
+			slog.Info("Login: Player is looking for a quick match", logging.GameType(req.QuickMatch.GameType), "name", account.Username)
+			session, err = sessionStore.CreateQuickMatchSession(account, server.QuickMatch{
+				GameType: req.QuickMatch.GameType,
+				ByRating: req.QuickMatch.ByRating,
+			})
+		} else {
+			session, err = sessionStore.CreateSession(account, roomCode)
+		}
 		if err != nil {
 			http.Error(w, "Failed to create session", http.StatusInternalServerError)
 			return
 		}
+		setSessionCookie(w, session, cfg.Session.MaxLifetime)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    true,
+			"session":    session.ID,
+			"quickMatch": session.QuickMatch != nil,
+		})
+	})
+
+	// Logout endpoint: ends the session and disconnects its pages
+	http.HandleFunc("/api/logout", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if cookie, err := r.Cookie("session"); err == nil {
+			if session, err := sessionStore.GetSession(cookie.Value); err == nil {
+				slog.Info("Logout", logging.RoomCode(session.RoomCode), "name", session.PlayerName)
+			}
+			sessionStore.DeleteSession(cookie.Value)
+		}
 
-		// Set session cookie
 		http.SetCookie(w, &http.Cookie{
 			Name:     "session",
-			Value:    session.ID,
+			Value:    "",
 			Path:     "/",
 			HttpOnly: true,
 			SameSite: http.SameSiteStrictMode,
-			MaxAge:   3600, // 1 hour
+			MaxAge:   -1,
 		})
-
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
-			"session": session.ID,
 		})
 	})
 
+	// Session endpoint: who is logged in and which room they are in
+	http.HandleFunc("/api/session", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			http.Error(w, "Not logged in", http.StatusUnauthorized)
+			return
+		}
+		session, err := sessionStore.GetSession(cookie.Value)
+		if errors.Is(err, server.ErrSessionExpired) {
+			http.Error(w, "Session expired", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Not logged in", http.StatusUnauthorized)
+			return
+		}
+		expiresAt, _ := sessionStore.ExpiresAt(session.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"accountId": session.AccountID,
+			"username":  session.PlayerName,
+			"roomCode":  session.RoomCode,
+			"createdAt": session.CreatedAt,
+			"expiresAt": expiresAt,
+		})
+	})
+
+	// Invite links: /join/{token} opens the join page, which checks the token
+	// and lets a guest pick a name for the room it invites them to
+	http.HandleFunc("/join/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(webDir, "join.html"))
+	})
+	http.HandleFunc("/api/rooms/", server.HandleRoomInvites(invites, sessionStore))
+
+	// Invite details for the join page: /api/invites/{token}
+	http.HandleFunc("/api/invites/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		invite, err := invites.CheckInvite(strings.TrimPrefix(r.URL.Path, "/api/invites/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"roomCode":  invite.RoomCode,
+			"host":      invite.Host,
+			"expiresAt": invite.ExpiresAt,
+		})
+	})
+
+	// Join endpoint: logs a guest holding an invite link into its room,
+	// creating their account first if the name is new. The invite stands in
+	// for the server's invite code.
+	http.HandleFunc("/api/join", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Token    string `json:"token"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Info("Join error: Failed to decode request", "error", err)
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		username := strings.TrimSpace(req.Username)
+
+		// Made up tokens and wrong passwords are throttled like logins
+		ipKey, userKey := server.IPKey(limiter.ClientIP(r)), server.UserKey(username)
+		if wait, blocked := limiter.Blocked(ipKey, userKey); blocked {
+			slog.Warn("Join throttled", "name", username, "ip", ipKey)
+			server.CountLogin(server.LoginThrottled)
+			server.TooManyRequests(w, wait)
+			return
+		}
+
+		invite, err := invites.CheckInvite(req.Token)
+		if err != nil {
+			if errors.Is(err, server.ErrInvalidInvite) {
+				limiter.Fail(ipKey)
+			}
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		var account *storage.Account
+		if accounts.UsernameTaken(username) {
+			// Returning players log in with their existing account
+			var ok bool
+			account, ok = accounts.Authenticate(username, req.Password)
+			if !ok {
+				server.CountLogin(server.LoginFailure)
+				if wait := limiter.Fail(ipKey, userKey); wait > 0 {
+					slog.Warn("Join failed - blocked", logging.RoomCode(invite.RoomCode), "name", username, "ip", ipKey, "wait", wait.String())
+					server.TooManyRequests(w, wait)
+					return
+				}
+				http.Error(w, "That name is taken - enter its password to log in", http.StatusUnauthorized)
+				return
+			}
+			limiter.Succeed(userKey)
+			server.CountLogin(server.LoginSuccess)
+		} else {
+			account, err = accounts.RegisterInvited(username, req.Password)
+			if errors.Is(err, storage.ErrUsernameTaken) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Info("Registered account from an invite", logging.RoomCode(invite.RoomCode), "accountID", account.ID, "name", account.Username, "host", invite.Host)
+		}
+
+		session, err := sessionStore.CreateSession(account, invite.RoomCode)
+		if err != nil {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		setSessionCookie(w, session, cfg.Session.MaxLifetime)
+		slog.Info("Join: Player joined with an invite", logging.RoomCode(invite.RoomCode), "name", account.Username, "host", invite.Host)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"roomCode": invite.RoomCode,
+		})
+	})
+
+	// Register endpoint: creates an account, then the player logs in with it
+	http.HandleFunc("/api/register", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// Tells the registration form whether to ask for an invite code
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"inviteRequired": accounts.InviteRequired(),
+			})
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			Username   string `json:"username"`
+			Password   string `json:"password"`
+			InviteCode string `json:"inviteCode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Info("Register error: Failed to decode request", "error", err)
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		// Wrong invite codes are throttled by client IP like failed logins
+		ipKey := server.IPKey(limiter.ClientIP(r))
+		if wait, blocked := limiter.Blocked(ipKey); blocked {
+			slog.Warn("Register throttled", "name", req.Username, "ip", ipKey)
+			server.TooManyRequests(w, wait)
+			return
+		}
+
+		account, err := accounts.Register(strings.TrimSpace(req.Username), req.Password, req.InviteCode)
+		switch {
+		case errors.Is(err, server.ErrInvalidInviteCode):
+			if wait := limiter.Fail(ipKey); wait > 0 {
+				slog.Warn("Register failed - blocked", "name", req.Username, "ip", ipKey, "wait", wait.String())
+				server.TooManyRequests(w, wait)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, storage.ErrUsernameTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("Registered account", "accountID", account.ID, "name", account.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"id":       account.ID,
+			"username": account.Username,
+		})
+	})
+
+	// Leaderboard for one game type, from the match history
+	http.HandleFunc("/api/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		board, err := mm.Leaderboard(r.URL.Query().Get("game"))
+		if errors.Is(err, server.ErrNoMatchStore) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(board)
+	})
+
+	// Player stats: /api/players/{name}/stats
+	http.HandleFunc("/api/players/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/api/players/")
+		if !strings.HasSuffix(name, "/stats") {
+			http.NotFound(w, r)
+			return
+		}
+		name = strings.TrimSuffix(name, "/stats")
+		if name == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		stats, ok, err := mm.PlayerStats(name)
+		if errors.Is(err, server.ErrNoMatchStore) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "No games recorded for "+name, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+
+	// Tournament brackets
+	tournaments := server.HandleTournaments(mm, sessionStore)
+	http.HandleFunc("/api/tournaments", tournaments)
+	http.HandleFunc("/api/tournaments/", tournaments)
+
+	// Admin API for the dashboard at /admin.html; off unless ADMIN_TOKEN is set
+	adminToken := cfg.Server.AdminToken
+	if adminToken == "" {
+		slog.Info("Admin API disabled - set ADMIN_TOKEN to enable it")
+	}
+	http.HandleFunc("/api/admin/", server.HandleAdmin(mm, sessionStore, limiter, adminToken))
+
+	// Health checks: /healthz while the process is up, /readyz while it should get players
+	http.HandleFunc("/healthz", server.HandleHealth())
+	http.HandleFunc("/readyz", server.HandleReady(mm, sessionStore))
+
 	// WebSocket endpoint with session verification
-	http.HandleFunc("/ws", server.HandleWebSocketWithAuth(mm, sessionStore))
+	http.HandleFunc("/ws", server.HandleWebSocketWithAuth(mm, sessionStore, limiter))
+
+	port := strconv.Itoa(cfg.Server.Port)
+
+	slog.Info("Server starting", "port", port, "logLevel", logging.Level().String())
+	slog.Info("WebSocket endpoint: ws://localhost:" + port + "/ws")
+	slog.Info("Web interface: http://localhost:" + port)
+
+	srv := &http.Server{Addr: ":" + port}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Server error", "error", err)
+		}
+	}()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Prometheus metrics get their own port, which isn't exposed publicly
+	var metricsSrv *http.Server
+	if cfg.Server.MetricsPort != 0 {
+		metricsPort := strconv.Itoa(cfg.Server.MetricsPort)
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", server.HandleMetrics(mm))
+		metricsSrv = &http.Server{Addr: ":" + metricsPort, Handler: metricsMux}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.Fatal("Metrics server error", "error", err)
+			}
+		}()
+		slog.Info("Metrics endpoint: http://localhost:" + metricsPort + "/metrics")
 	}
 
-	log.Printf("Server starting on :%s", port)
-	log.Printf("WebSocket endpoint: ws://localhost:%s/ws", port)
-	log.Printf("Web interface: http://localhost:%s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatal("Server error:", err)
+	// Fly sends SIGTERM before stopping the machine on a deploy
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	slog.Info("Shutdown signal received - draining games", "timeout", cfg.Server.ShutdownTimeout.String())
+	mm.Shutdown(cfg.Server.ShutdownTimeout)
+
+	httpCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		slog.Warn("HTTP server shutdown", "error", err)
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(httpCtx); err != nil {
+			slog.Warn("Metrics server shutdown", "error", err)
+		}
+	}
+	sessionStore.Close()
+
+	if err := store.Close(); err != nil {
+		slog.Error("Failed to close match history", "error", err)
+	}
+	if err := accountStore.Close(); err != nil {
+		slog.Error("Failed to close accounts", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// setSessionCookie hands a new session to the browser
+func setSessionCookie(w http.ResponseWriter, session *server.Session, maxLifetime time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    session.ID,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(maxLifetime.Seconds()), // The server expires idle sessions sooner
+	})
 }
@@ -0,0 +1,257 @@
+// Package config holds the server's settings. They start from defaults and
+// are overridden in turn by a JSON config file, environment variables and
+// command line flags, then validated before the server starts.
+package config
+
+import (
+	"GoServerGames/internal/game"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Server   Server
+	Session  Session
+	Game     Game
+	Storage  Storage
+	Auth     Auth
+	LogLevel slog.Level // Lowest level logged at startup; the admin API can change it
+}
+
+type Server struct {
+	Port            int
+	MetricsPort     int           // Serves /metrics apart from the public port, 0 to turn it off
+	WebDir          string        // Static files for the web interface
+	ClientIPHeader  string        // Header a proxy puts the client IP in, "" to use the remote address
+	SendBuffer      int           // Messages queued per connection before new ones are dropped
+	ShutdownTimeout time.Duration // How long running games get to finish when the server is stopped
+	AdminToken      string        // "" turns the admin API off
+}
+
+type Session struct {
+	IdleTimeout time.Duration // A session expires after this long without activity
+	MaxLifetime time.Duration // and after this long no matter what
+}
+
+type Game struct {
+	TickRate       int           // Game room ticks per second; each one rebroadcasts the state while players join or play
+	Rounds         int           // Rounds in a game unless the lobby picks other rules
+	RoundTimeLimit time.Duration // How long players get to submit before a round is forfeited, 0 for no limit
+	StartDelay     time.Duration // Time for players to reconnect after being sent to a game
+	ResultsDelay   time.Duration // Pause between a round's results and the next round
+	RematchWait    time.Duration // How long players get to accept a rematch after the summary
+	SummaryWait    time.Duration // How long a summary shows before moving on when there's no rematch
+}
+
+type Storage struct {
+	MatchesPath  string // Match history, kept on a volume in production so it survives deploys
+	AccountsPath string
+}
+
+type Auth struct {
+	InviteCode   string // Needed to register, "" lets anyone register
+	InviteSecret string // Signs invite links, "" for a new one each start
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:            8080,
+			MetricsPort:     9091,
+			WebDir:          "web",
+			SendBuffer:      1024,
+			ShutdownTimeout: 60 * time.Second,
+		},
+		Session: Session{
+			IdleTimeout: 1 * time.Hour,
+			MaxLifetime: 24 * time.Hour,
+		},
+		Game: Game{
+			TickRate:       2,
+			Rounds:         5,
+			RoundTimeLimit: 30 * time.Second,
+			StartDelay:     2 * time.Second,
+			ResultsDelay:   3 * time.Second,
+			RematchWait:    60 * time.Second,
+			SummaryWait:    10 * time.Second,
+		},
+		Storage: Storage{
+			MatchesPath:  filepath.Join("data", "matches.jsonl"),
+			AccountsPath: filepath.Join("data", "accounts.jsonl"),
+		},
+		LogLevel: slog.LevelInfo,
+	}
+}
+
+// Load builds the config from the defaults, the config file named by the
+// -config flag or CONFIG_FILE, environment variables and then the other
+// flags, each overriding the last, and validates it
+func Load(args []string) (*Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON config file (env CONFIG_FILE)")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		s := s
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if def := s.value(Default()).String(); def != "" {
+			usage = fmt.Sprintf("%s (env %s, default %s)", s.usage, s.env, def)
+		}
+		fs.Func(s.flag, usage, func(v string) error {
+			flags[s.flag] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *path != "" {
+		if err := c.readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.value(c).Set(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.flag]; ok {
+			if err := s.value(c).Set(v); err != nil {
+				return nil, fmt.Errorf("-%s: %w", s.flag, err)
+			}
+		}
+	}
+	return c, c.Validate()
+}
+
+// readFile applies the settings in a JSON config file, with sections as
+// objects: {"server": {"port": 8080}, "game": {"rounds": 3}}
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var file map[string]interface{}
+	if err := dec.Decode(&file); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", file, values)
+	for _, s := range settings {
+		if v, ok := values[s.key]; ok {
+			if err := s.value(c).Set(v); err != nil {
+				return fmt.Errorf("config file %s: %s: %w", path, s.key, err)
+			}
+			delete(values, s.key)
+		}
+	}
+	if len(values) > 0 {
+		unknown := make([]string, 0, len(values))
+		for key := range values {
+			unknown = append(unknown, key)
+		}
+		sort.Strings(unknown)
+		return fmt.Errorf("config file %s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// flatten turns nested objects into dotted keys, with values as text
+func flatten(prefix string, m map[string]interface{}, values map[string]string) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flatten(prefix+k+".", v, values)
+		case nil:
+			values[prefix+k] = ""
+		default:
+			values[prefix+k] = fmt.Sprint(v)
+		}
+	}
+}
+
+// Validate reports every setting that is out of range
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	check(c.Server.MetricsPort >= 0 && c.Server.MetricsPort <= 65535, "server.metricsPort must be between 0 and 65535")
+	check(c.Server.MetricsPort != c.Server.Port, "server.metricsPort must differ from server.port")
+	info, err := os.Stat(c.Server.WebDir)
+	check(err == nil && info.IsDir(), "server.webDir %q isn't a directory", c.Server.WebDir)
+	check(c.Server.SendBuffer >= 1, "server.sendBuffer must be at least 1")
+	check(c.Server.ShutdownTimeout >= 0, "server.shutdownTimeout can't be negative")
+
+	check(c.Session.IdleTimeout > 0, "session.idleTimeout must be positive")
+	check(c.Session.MaxLifetime >= c.Session.IdleTimeout, "session.maxLifetime must be at least session.idleTimeout")
+
+	check(c.Game.TickRate >= 1 && c.Game.TickRate <= 60, "game.tickRate must be between 1 and 60")
+	check(c.Game.Rounds >= 1 && c.Game.Rounds <= game.MaxMatchRounds, "game.rounds must be between 1 and %d", game.MaxMatchRounds)
+	check(c.Game.RoundTimeLimit >= 0, "game.roundTimeLimit can't be negative")
+	check(c.Game.StartDelay >= 0, "game.startDelay can't be negative")
+	check(c.Game.ResultsDelay >= 0, "game.resultsDelay can't be negative")
+	check(c.Game.RematchWait > 0, "game.rematchWait must be positive")
+	check(c.Game.SummaryWait > 0, "game.summaryWait must be positive")
+
+	check(c.Storage.MatchesPath != "", "storage.matchesPath is required")
+	check(c.Storage.AccountsPath != "", "storage.accountsPath is required")
+
+	return errors.Join(errs...)
+}
+
+// LogValue summarises the config for the startup log, by section, with
+// secrets only shown as set or not
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	sections := make(map[string][]slog.Attr)
+	var order []string
+	for _, s := range settings {
+		v := s.value(c)
+		value := slog.StringValue(v.String())
+		if getter, ok := v.(flag.Getter); ok {
+			value = slog.AnyValue(getter.Get())
+		}
+		if s.secret && v.String() != "" {
+			value = slog.StringValue("(set)")
+		}
+		section, name, ok := strings.Cut(s.key, ".")
+		if !ok {
+			attrs = append(attrs, slog.Attr{Key: s.key, Value: value})
+			continue
+		}
+		if _, seen := sections[section]; !seen {
+			order = append(order, section)
+		}
+		sections[section] = append(sections[section], slog.Attr{Key: name, Value: value})
+	}
+	for _, section := range order {
+		attrs = append(attrs, slog.Attr{Key: section, Value: slog.GroupValue(sections[section]...)})
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv hides any settings from the environment running the tests
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+}
+
+// writeConfig writes a config file to a temporary directory
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := `{"server": {"port": 7001}, "game": {"rounds": 3, "roundTimeLimit": "45s"}}`
+	tests := []struct {
+		name      string
+		file      bool
+		env       map[string]string
+		args      []string
+		port      int
+		rounds    int
+		timeLimit time.Duration
+	}{
+		{name: "defaults", port: 8080, rounds: 5, timeLimit: 30 * time.Second},
+		{name: "file", file: true, port: 7001, rounds: 3, timeLimit: 45 * time.Second},
+		{name: "env over file", file: true,
+			env:  map[string]string{"PORT": "7002", "ROUND_TIME_LIMIT": "20"},
+			port: 7002, rounds: 3, timeLimit: 20 * time.Second},
+		{name: "flag over env and file", file: true,
+			env:  map[string]string{"PORT": "7002", "ROUNDS": "7"},
+			args: []string{"-port", "7003", "-round-time-limit", "0"},
+			port: 7003, rounds: 7, timeLimit: 0},
+		{name: "flag over env", env: map[string]string{"ROUNDS": "7"},
+			args: []string{"-rounds=9"},
+			port: 8080, rounds: 9, timeLimit: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file {
+				t.Setenv("CONFIG_FILE", writeConfig(t, file))
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := append([]string{"-web-dir", t.TempDir()}, tt.args...)
+
+			c, err := Load(args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if c.Server.Port != tt.port {
+				t.Errorf("port = %d, want %d", c.Server.Port, tt.port)
+			}
+			if c.Game.Rounds != tt.rounds {
+				t.Errorf("rounds = %d, want %d", c.Game.Rounds, tt.rounds)
+			}
+			if c.Game.RoundTimeLimit != tt.timeLimit {
+				t.Errorf("round time limit = %v, want %v", c.Game.RoundTimeLimit, tt.timeLimit)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeConfig(t, `{"server": {"port": 7001}}`))
+	path := writeConfig(t, `{"server": {"port": 7004}}`)
+
+	c, err := Load([]string{"-config", path, "-web-dir", t.TempDir()})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Server.Port != 7004 {
+		t.Errorf("port = %d, want 7004 from the -config file", c.Server.Port)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want []string // Every one must be in the error
+	}{
+		{name: "port out of range", args: []string{"-port", "70000"},
+			want: []string{"server.port must be between 1 and 65535"}},
+		{name: "metrics on the public port", args: []string{"-port", "9091"},
+			want: []string{"server.metricsPort must differ from server.port"}},
+		{name: "tick rate zero", args: []string{"-tick-rate", "0"},
+			want: []string{"game.tickRate must be between 1 and 60"}},
+		{name: "negative round time limit", env: map[string]string{"ROUND_TIME_LIMIT": "-5s"},
+			want: []string{"game.roundTimeLimit can't be negative"}},
+		{name: "lifetime shorter than idle timeout", args: []string{"-session-idle-timeout", "2h", "-session-max-lifetime", "1h"},
+			want: []string{"session.maxLifetime must be at least session.idleTimeout"}},
+		{name: "every problem reported", args: []string{"-rounds", "0", "-send-buffer", "0", "-rematch-wait", "0"},
+			want: []string{"game.rounds must be between 1", "server.sendBuffer must be at least 1", "game.rematchWait must be positive"}},
+		{name: "not a number", env: map[string]string{"ROUNDS": "many"},
+			want: []string{"ROUNDS:", `"many" isn't a whole number`}},
+		{name: "not a duration", args: []string{"-start-delay", "soon"},
+			want: []string{"-start-delay:", `"soon" isn't a duration`}},
+		{name: "unknown file setting", file: `{"server": {"port": 8080, "prot": 1}, "extra": true}`,
+			want: []string{"unknown settings extra, server.prot"}},
+		{name: "bad file value", file: `{"game": {"tickRate": "fast"}}`,
+			want: []string{"game.tickRate:", `"fast" isn't a whole number`}},
+		{name: "malformed file", file: `{"server": `,
+			want: []string{"config file"}},
+		{name: "stray argument", args: []string{"8080"},
+			want: []string{`unexpected argument "8080"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeConfig(t, tt.file))
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := append([]string{"-web-dir", t.TempDir()}, tt.args...)
+
+			_, err := Load(args)
+			if err == nil {
+				t.Fatal("Load succeeded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
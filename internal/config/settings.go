@@ -0,0 +1,126 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// setting is one config value with its config file key, environment
+// variable and flag
+type setting struct {
+	key    string // "section.name" in the config file
+	env    string
+	flag   string
+	usage  string
+	secret bool                       // Only shown as set or not in the summary
+	value  func(c *Config) flag.Value // A flag.Getter when the summary should show more than text
+}
+
+var settings = []setting{
+	{key: "server.port", env: "PORT", flag: "port", usage: "Port to listen on",
+		value: func(c *Config) flag.Value { return (*intValue)(&c.Server.Port) }},
+	{key: "server.metricsPort", env: "METRICS_PORT", flag: "metrics-port", usage: "Port serving /metrics, kept off the public port; 0 turns metrics off",
+		value: func(c *Config) flag.Value { return (*intValue)(&c.Server.MetricsPort) }},
+	{key: "server.webDir", env: "WEB_DIR", flag: "web-dir", usage: "Directory of the web interface's files",
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Server.WebDir) }},
+	{key: "server.clientIPHeader", env: "CLIENT_IP_HEADER", flag: "client-ip-header", usage: "Header holding the client IP behind a proxy",
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Server.ClientIPHeader) }},
+	{key: "server.sendBuffer", env: "SEND_BUFFER", flag: "send-buffer", usage: "Messages queued per connection before new ones are dropped",
+		value: func(c *Config) flag.Value { return (*intValue)(&c.Server.SendBuffer) }},
+	{key: "server.shutdownTimeout", env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "How long running games get to finish when the server is stopped",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Server.ShutdownTimeout) }},
+	{key: "server.adminToken", env: "ADMIN_TOKEN", flag: "admin-token", usage: "Token for the admin API, which is off without one", secret: true,
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Server.AdminToken) }},
+
+	{key: "session.idleTimeout", env: "SESSION_IDLE_TIMEOUT", flag: "session-idle-timeout", usage: "How long a session lasts without activity",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Session.IdleTimeout) }},
+	{key: "session.maxLifetime", env: "SESSION_MAX_LIFETIME", flag: "session-max-lifetime", usage: "How long a session lasts no matter what",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Session.MaxLifetime) }},
+
+	{key: "game.tickRate", env: "TICK_RATE", flag: "tick-rate", usage: "Game room ticks per second, each rebroadcasting the state while players join or play",
+		value: func(c *Config) flag.Value { return (*intValue)(&c.Game.TickRate) }},
+	{key: "game.rounds", env: "ROUNDS", flag: "rounds", usage: "Rounds in a game unless the lobby picks other rules",
+		value: func(c *Config) flag.Value { return (*intValue)(&c.Game.Rounds) }},
+	{key: "game.roundTimeLimit", env: "ROUND_TIME_LIMIT", flag: "round-time-limit", usage: "How long players get to submit each round, 0 for no limit",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Game.RoundTimeLimit) }},
+	{key: "game.startDelay", env: "START_DELAY", flag: "start-delay", usage: "Countdown before a game's first round",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Game.StartDelay) }},
+	{key: "game.resultsDelay", env: "RESULTS_DELAY", flag: "results-delay", usage: "Pause between a round's results and the next round",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Game.ResultsDelay) }},
+	{key: "game.rematchWait", env: "REMATCH_WAIT", flag: "rematch-wait", usage: "How long players get to accept a rematch",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Game.RematchWait) }},
+	{key: "game.summaryWait", env: "SUMMARY_WAIT", flag: "summary-wait", usage: "How long a party or tournament game's summary shows",
+		value: func(c *Config) flag.Value { return (*durationValue)(&c.Game.SummaryWait) }},
+
+	{key: "storage.matchesPath", env: "MATCH_STORE_PATH", flag: "match-store-path", usage: "Match history file",
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Storage.MatchesPath) }},
+	{key: "storage.accountsPath", env: "ACCOUNT_STORE_PATH", flag: "account-store-path", usage: "Player accounts file",
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Storage.AccountsPath) }},
+
+	{key: "auth.inviteCode", env: "GAME_PASSWORD", flag: "invite-code", usage: "Invite code needed to register", secret: true,
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Auth.InviteCode) }},
+	{key: "auth.inviteSecret", env: "INVITE_SECRET", flag: "invite-secret", usage: "Secret signing invite links so they survive restarts", secret: true,
+		value: func(c *Config) flag.Value { return (*stringValue)(&c.Auth.InviteSecret) }},
+
+	{key: "logLevel", env: "LOG_LEVEL", flag: "log-level", usage: "Lowest level logged: debug, info, warn or error",
+		value: func(c *Config) flag.Value { return (*levelValue)(&c.LogLevel) }},
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q isn't a whole number", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Get() interface{} { return int(*v) }
+
+// durationValue takes a duration such as "90s" or "5m", or a bare number
+// of seconds
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		*v = durationValue(time.Duration(seconds) * time.Second)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q isn't a duration such as 30s or 5m", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type levelValue slog.Level
+
+func (v *levelValue) Set(s string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(s)); err != nil {
+		return fmt.Errorf("%q isn't debug, info, warn or error", s)
+	}
+	*v = levelValue(lvl)
+	return nil
+}
+
+func (v *levelValue) String() string { return slog.Level(*v).String() }
@@ -2,10 +2,17 @@ package game
 
 import (
 	"GoServerGames/internal/net"
+	"encoding/json"
 	"math/rand"
 	"time"
 )
 
+func init() {
+	Register("clickspeed", func(id string, roomCode string) MiniGame {
+		return NewClickSpeedRoom(id, roomCode)
+	})
+}
+
 type ClickTarget struct {
 	X      float64 // 0-100 percentage
 	Y      float64 // 0-100 percentage
@@ -22,38 +29,40 @@ func GenerateClickTarget() ClickTarget {
 }
 
 type ClickSpeedPlayer struct {
-	ID             int
-	Name           string
-	Score          int
-	LastTimeMs     float64
-	Connected      bool
-	ReadyForNext   bool
+	ID              int
+	Name            string
+	Score           int
+	LastTimeMs      float64
+	Connected       bool
 	ReadyForNewGame bool
 }
 
 type ClickRoundHistory struct {
-	RoundNumber   int
-	Player1TimeMs float64
-	Player2TimeMs float64
-	WinnerID      int
-	TargetX       float64
-	TargetY       float64
+	RoundNumber int
+	Results     []Placement // Fastest first, then players who didn't click
+	WinnerID    int
+	TargetX     float64
+	TargetY     float64
+	TimedOut    bool // Round ended at the deadline instead of every player clicking
 }
 
 type ClickSpeedRoom struct {
-	ID                string
-	RoomCode          string // Room code this game belongs to (for isolation)
-	Players           [2]*ClickSpeedPlayer
-	CurrentTarget     ClickTarget
-	State             string // "waiting", "ready", "playing", "results"
-	RoundStartTime    time.Time
-	TargetAppearDelayMs int64 // Delay in milliseconds before target appears (server-controlled)
-	Player1SubmitTime float64
-	Player2SubmitTime float64
-	RoundWinner       int
-	RoundNumber       int
-	RoundHistory      []ClickRoundHistory
-	GameEnded         bool
+	ID                  string
+	RoomCode            string // Room code this game belongs to (for isolation)
+	Players             []*ClickSpeedPlayer
+	CurrentTarget       ClickTarget
+	State               string // "waiting", "ready", "playing", "results"
+	RoundStartTime      time.Time
+	TargetAppearDelayMs int64     // Delay in milliseconds before target appears (server-controlled)
+	RoundDeadline       time.Time // Zero when rounds have no time limit
+	RoundTimedOut       bool
+	SubmitTimes         map[int]float64 // Reaction times this round keyed by player ID
+	RoundResults        []Placement
+	RoundWinner         int
+	RoundNumber         int
+	RoundHistory        []ClickRoundHistory
+	Ratings             map[int]RatingChange // Set once the game is rated, keyed by player ID
+	GameEnded           bool
 }
 
 func NewClickSpeedRoom(id string, roomCode string) *ClickSpeedRoom {
@@ -62,38 +71,82 @@ func NewClickSpeedRoom(id string, roomCode string) *ClickSpeedRoom {
 		RoomCode:     roomCode,
 		State:        "waiting",
 		RoundNumber:  0,
+		SubmitTimes:  make(map[int]float64),
 		RoundHistory: make([]ClickRoundHistory, 0),
 	}
 }
 
 func (r *ClickSpeedRoom) AddPlayer(id int, name string) {
-	if r.Players[0] == nil {
-		r.Players[0] = &ClickSpeedPlayer{
-			ID:        id,
-			Name:      name,
-			Score:     0,
-			Connected: true,
-		}
-	} else if r.Players[1] == nil {
-		r.Players[1] = &ClickSpeedPlayer{
-			ID:        id,
-			Name:      name,
-			Score:     0,
-			Connected: true,
-		}
+	if len(r.Players) >= MaxPlayers {
+		return
+	}
+	r.Players = append(r.Players, &ClickSpeedPlayer{
+		ID:        id,
+		Name:      name,
+		Connected: true,
+	})
+	if len(r.Players) >= MinPlayers {
 		r.State = "ready"
 	}
 }
 
-func (r *ClickSpeedRoom) ResetReadyForNext() {
+func (r *ClickSpeedRoom) player(playerID int) *ClickSpeedPlayer {
+	for _, player := range r.Players {
+		if player.ID == playerID {
+			return player
+		}
+	}
+	return nil
+}
+
+func (r *ClickSpeedRoom) SetReadyForNewGame(playerID int, ready bool) bool {
+	if player := r.player(playerID); player != nil {
+		player.ReadyForNewGame = ready
+		return true
+	}
+	return false
+}
+
+func (r *ClickSpeedRoom) IsReadyForNewGame(playerID int) bool {
+	if player := r.player(playerID); player != nil {
+		return player.ReadyForNewGame
+	}
+	return false
+}
+
+func (r *ClickSpeedRoom) AllReadyForNewGame() bool {
 	for _, player := range r.Players {
-		if player != nil {
-			player.ReadyForNext = false
+		if !player.ReadyForNewGame {
+			return false
 		}
 	}
+	return true
+}
+
+func (r *ClickSpeedRoom) ResetGame() {
+	// Reset all game state for a new game
+	r.RoundNumber = 0
+	r.RoundHistory = make([]ClickRoundHistory, 0)
+	r.Ratings = nil
+	r.State = "ready" // Everyone is still seated
+	r.CurrentTarget = ClickTarget{}
+	r.TargetAppearDelayMs = 0
+	r.RoundDeadline = time.Time{}
+	r.RoundTimedOut = false
+	r.SubmitTimes = make(map[int]float64)
+	r.RoundResults = nil
+	r.RoundWinner = 0
+	r.GameEnded = false
+
+	// Reset player scores and ready status
+	for _, player := range r.Players {
+		player.Score = 0
+		player.LastTimeMs = 0
+		player.ReadyForNewGame = false
+	}
 }
 
-func (r *ClickSpeedRoom) StartRound() {
+func (r *ClickSpeedRoom) StartRound(timeLimit time.Duration) {
 	if r.State != "ready" && r.State != "results" {
 		return
 	}
@@ -104,8 +157,14 @@ func (r *ClickSpeedRoom) StartRound() {
 	r.RoundStartTime = time.Now()
 	// Random delay between 2000-4000ms (2-4 seconds) for target to appear
 	r.TargetAppearDelayMs = int64(2000 + rand.Intn(2000))
-	r.Player1SubmitTime = 0
-	r.Player2SubmitTime = 0
+	// The time limit starts counting once the target is visible
+	r.RoundDeadline = time.Time{}
+	if timeLimit > 0 {
+		r.RoundDeadline = r.RoundStartTime.Add(time.Duration(r.TargetAppearDelayMs)*time.Millisecond + timeLimit)
+	}
+	r.RoundTimedOut = false
+	r.SubmitTimes = make(map[int]float64)
+	r.RoundResults = nil
 	r.RoundWinner = 0
 }
 
@@ -114,191 +173,228 @@ func (r *ClickSpeedRoom) SubmitClick(playerID int, timeMs float64) bool {
 		return false
 	}
 
-	playerIdx := -1
-	if r.Players[0] != nil && r.Players[0].ID == playerID {
-		playerIdx = 0
-	} else if r.Players[1] != nil && r.Players[1].ID == playerID {
-		playerIdx = 1
-	}
-
-	if playerIdx == -1 {
+	if r.player(playerID) == nil {
 		return false
 	}
+	if r.SubmitTimes[playerID] > 0 {
+		return false // Already submitted
+	}
 
 	// Client sends time from when target appeared - use it directly
 	// The delay is only for synchronization, NOT part of reaction time
 	actualTimeMs := timeMs
-	
-	// Validate: only check for negative times (invalid)
-	if actualTimeMs < 0 {
-		// Invalid time, use 0 as minimum
-		actualTimeMs = 0
+
+	// Validate: only non-positive times are invalid, and 0 means no click
+	if actualTimeMs <= 0 {
+		actualTimeMs = 1
 	}
 	// No maximum time limit - players can take as long as they need
 
 	// Store submission time (actual time from round start)
-	if playerIdx == 0 {
-		if r.Player1SubmitTime > 0 {
-			return false // Already submitted
-		}
-		r.Player1SubmitTime = actualTimeMs
-	} else {
-		if r.Player2SubmitTime > 0 {
-			return false // Already submitted
+	r.SubmitTimes[playerID] = actualTimeMs
+
+	if r.roundComplete() {
+		r.endRound(false)
+	}
+
+	return true
+}
+
+// roundComplete reports whether every connected player has clicked, and at
+// least one is still connected
+func (r *ClickSpeedRoom) roundComplete() bool {
+	connected := 0
+	for _, player := range r.Players {
+		if !player.Connected {
+			continue
 		}
-		r.Player2SubmitTime = actualTimeMs
-	}
-
-	// Check if both players clicked
-	if r.Player1SubmitTime > 0 && r.Player2SubmitTime > 0 {
-		r.State = "results"
-		// Determine winner (faster time wins)
-		// RoundWinner = 0 means tie
-		if r.Player1SubmitTime < r.Player2SubmitTime {
-			r.RoundWinner = r.Players[0].ID
-			r.Players[0].Score++
-		} else if r.Player2SubmitTime < r.Player1SubmitTime {
-			r.RoundWinner = r.Players[1].ID
-			r.Players[1].Score++
-		} else {
-			// Tie - RoundWinner stays 0
-			r.RoundWinner = 0
+		if r.SubmitTimes[player.ID] == 0 {
+			return false
 		}
+		connected++
+	}
+	return connected > 0
+}
 
-		// Store times for display
-		r.Players[0].LastTimeMs = r.Player1SubmitTime
-		r.Players[1].LastTimeMs = r.Player2SubmitTime
+// ExpireRound ends a round whose deadline has passed. Players who clicked
+// in time are placed by their times; everyone else scores nothing.
+func (r *ClickSpeedRoom) ExpireRound() {
+	if r.State != "playing" {
+		return
+	}
+	r.endRound(true)
+}
 
-		// Add to history
-		r.RoundHistory = append(r.RoundHistory, ClickRoundHistory{
-			RoundNumber:   r.RoundNumber,
-			Player1TimeMs: r.Player1SubmitTime,
-			Player2TimeMs: r.Player2SubmitTime,
-			WinnerID:      r.RoundWinner,
-			TargetX:       r.CurrentTarget.X,
-			TargetY:       r.CurrentTarget.Y,
-		})
+// endRound places the players, awards points and records the round
+func (r *ClickSpeedRoom) endRound(timedOut bool) {
+	r.State = "results"
+	r.RoundTimedOut = timedOut
+	r.RoundResults, r.RoundWinner = rankRound(r.PlayerIDs(), r.SubmitTimes)
+	for _, result := range r.RoundResults {
+		player := r.player(result.PlayerID)
+		player.Score += result.Points
+		player.LastTimeMs = result.TimeMs // Store times for display
 	}
+	r.recordRoundHistory()
+}
 
-	return true
+func (r *ClickSpeedRoom) recordRoundHistory() {
+	r.RoundHistory = append(r.RoundHistory, ClickRoundHistory{
+		RoundNumber: r.RoundNumber,
+		Results:     r.RoundResults,
+		WinnerID:    r.RoundWinner,
+		TargetX:     r.CurrentTarget.X,
+		TargetY:     r.CurrentTarget.Y,
+		TimedOut:    r.RoundTimedOut,
+	})
 }
 
 func (r *ClickSpeedRoom) GetState() *net.ClickSpeedStateMessage {
-	scores := []net.ClickSpeedScore{}
-
-	if r.Players[0] != nil {
-		scores = append(scores, net.ClickSpeedScore{
-			PlayerID: r.Players[0].ID,
-			Name:     r.Players[0].Name,
-			Score:    r.Players[0].Score,
-			TimeMs:   r.Players[0].LastTimeMs,
-		})
-	}
-	if r.Players[1] != nil {
-		scores = append(scores, net.ClickSpeedScore{
-			PlayerID: r.Players[1].ID,
-			Name:     r.Players[1].Name,
-			Score:    r.Players[1].Score,
-			TimeMs:   r.Players[1].LastTimeMs,
-		})
+	scores := make([]net.ClickSpeedScore, len(r.Players))
+	for i, player := range r.Players {
+		scores[i] = net.ClickSpeedScore{
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Score:    player.Score,
+			TimeMs:   player.LastTimeMs,
+		}
 	}
 
 	msg := &net.ClickSpeedStateMessage{
-		Type:              "clickSpeedState",
-		TargetX:           r.CurrentTarget.X,
-		TargetY:           r.CurrentTarget.Y,
-		Radius:            r.CurrentTarget.Radius,
-		State:             r.State,
-		Scores:            scores,
+		Type:                "clickSpeedState",
+		TargetX:             r.CurrentTarget.X,
+		TargetY:             r.CurrentTarget.Y,
+		Radius:              r.CurrentTarget.Radius,
+		State:               r.State,
+		Scores:              scores,
 		TargetAppearDelayMs: int(r.TargetAppearDelayMs),
 	}
 
+	if r.State == "playing" && !r.RoundDeadline.IsZero() {
+		msg.DeadlineMs = r.RoundDeadline.UnixMilli()
+		msg.TimeLeftMs = timeLeftMs(r.RoundDeadline)
+	}
+
 	if r.State == "results" {
 		msg.RoundResult = &net.ClickSpeedResult{
-			WinnerID:      r.RoundWinner,
-			Player1TimeMs: r.Player1SubmitTime,
-			Player2TimeMs: r.Player2SubmitTime,
+			WinnerID: r.RoundWinner,
+			Results:  resultMessages(r.RoundResults),
+			TimedOut: r.RoundTimedOut,
 		}
 	}
 
 	return msg
 }
 
-func (r *ClickSpeedRoom) CheckGameEnd() bool {
-	return r.RoundNumber >= 10 || r.GameEnded
+// MiniGame implementation
+
+func (r *ClickSpeedRoom) GetID() string       { return r.ID }
+func (r *ClickSpeedRoom) GetRoomCode() string { return r.RoomCode }
+func (r *ClickSpeedRoom) GameType() string    { return "clickspeed" }
+func (r *ClickSpeedRoom) SubmitType() string  { return "clickSpeedSubmit" }
+func (r *ClickSpeedRoom) Phase() string       { return r.State }
+func (r *ClickSpeedRoom) Deadline() time.Time { return r.RoundDeadline }
+func (r *ClickSpeedRoom) Ended() bool         { return r.GameEnded }
+func (r *ClickSpeedRoom) End()                { r.GameEnded = true }
+
+func (r *ClickSpeedRoom) PlayerIDs() []int {
+	ids := make([]int, len(r.Players))
+	for i, player := range r.Players {
+		ids[i] = player.ID
+	}
+	return ids
 }
 
-func (r *ClickSpeedRoom) GetGameSummary() *ClickGameSummary {
-	if len(r.RoundHistory) == 0 {
-		return nil
+func (r *ClickSpeedRoom) Scores() map[int]int {
+	scores := make(map[int]int)
+	for _, player := range r.Players {
+		scores[player.ID] = player.Score
 	}
+	return scores
+}
 
-	var p1TotalTime, p2TotalTime float64
-	for _, rh := range r.RoundHistory {
-		p1TotalTime += rh.Player1TimeMs
-		p2TotalTime += rh.Player2TimeMs
+func (r *ClickSpeedRoom) SetConnected(playerID int, connected bool) {
+	if player := r.player(playerID); player != nil {
+		player.Connected = connected
 	}
+	// The round may have been waiting only on the player who left
+	if !connected && r.State == "playing" && r.roundComplete() {
+		r.endRound(false)
+	}
+}
 
-	numRounds := float64(len(r.RoundHistory))
-	p1AvgTime := p1TotalTime / numRounds
-	p2AvgTime := p2TotalTime / numRounds
+func (r *ClickSpeedRoom) NextRound() {
+	r.State = "ready"
+}
 
-	var winnerID int
-	if r.Players[0] != nil && r.Players[1] != nil {
-		if r.Players[0].Score > r.Players[1].Score {
-			winnerID = r.Players[0].ID
-		} else if r.Players[1].Score > r.Players[0].Score {
-			winnerID = r.Players[1].ID
-		}
+func (r *ClickSpeedRoom) Submit(playerID int, payload []byte) bool {
+	var msg net.ClickSpeedSubmitMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return false
 	}
+	return r.SubmitClick(playerID, msg.TimeMs)
+}
 
-	summary := &ClickGameSummary{
-		WinnerID:     winnerID,
-		RoundHistory: make([]ClickRoundHistoryData, len(r.RoundHistory)),
-	}
+func (r *ClickSpeedRoom) StateMessage() interface{} {
+	return r.GetState()
+}
 
-	if r.Players[0] != nil {
-		summary.Player1ID = r.Players[0].ID
-		summary.Player1Name = r.Players[0].Name
-		summary.Player1Score = r.Players[0].Score
-		summary.Player1AvgTime = p1AvgTime
-	}
-	if r.Players[1] != nil {
-		summary.Player2ID = r.Players[1].ID
-		summary.Player2Name = r.Players[1].Name
-		summary.Player2Score = r.Players[1].Score
-		summary.Player2AvgTime = p2AvgTime
+// SpectatorStateMessage is the regular state; a target has nothing to give away
+func (r *ClickSpeedRoom) SpectatorStateMessage() interface{} {
+	return r.GetState()
+}
+
+func (r *ClickSpeedRoom) SummaryMessage() interface{} {
+	summary := r.GetGameSummary()
+	if summary == nil {
+		return nil
 	}
 
+	msg := &net.ClickGameSummaryMessage{
+		Type:         "clickGameSummary",
+		Players:      standingMessages(summary.Standings),
+		WinnerID:     summary.WinnerID,
+		RoundHistory: make([]net.ClickRoundHistoryData, len(r.RoundHistory)),
+	}
 	for i, rh := range r.RoundHistory {
-		summary.RoundHistory[i] = ClickRoundHistoryData{
-			RoundNumber:   rh.RoundNumber,
-			Player1TimeMs: rh.Player1TimeMs,
-			Player2TimeMs: rh.Player2TimeMs,
-			WinnerID:      rh.WinnerID,
+		msg.RoundHistory[i] = net.ClickRoundHistoryData{
+			RoundNumber: rh.RoundNumber,
+			Results:     resultMessages(rh.Results),
+			WinnerID:    rh.WinnerID,
+			TimedOut:    rh.TimedOut,
 		}
 	}
-
-	return summary
+	return msg
 }
 
-type ClickGameSummary struct {
-	Player1ID      int
-	Player1Name    string
-	Player1Score   int
-	Player1AvgTime float64
-	Player2ID      int
-	Player2Name    string
-	Player2Score   int
-	Player2AvgTime float64
-	WinnerID       int
-	RoundHistory   []ClickRoundHistoryData
+func (r *ClickSpeedRoom) SetRatings(ratings map[int]RatingChange) {
+	r.Ratings = ratings
 }
 
-type ClickRoundHistoryData struct {
-	RoundNumber   int
-	Player1TimeMs float64
-	Player2TimeMs float64
-	WinnerID      int
-}
+func (r *ClickSpeedRoom) GetGameSummary() *GameSummary {
+	if len(r.RoundHistory) == 0 {
+		return nil
+	}
 
+	standings := make([]Standing, len(r.Players))
+	for i, player := range r.Players {
+		rating := r.Ratings[player.ID]
+		standings[i] = Standing{
+			PlayerID:     player.ID,
+			Name:         player.Name,
+			Score:        player.Score,
+			Rating:       rating.Rating,
+			RatingChange: rating.Change,
+		}
+	}
+	rounds := make([]RoundSummary, len(r.RoundHistory))
+	for i, rh := range r.RoundHistory {
+		rounds[i] = RoundSummary{
+			RoundNumber: rh.RoundNumber,
+			Results:     rh.Results,
+			WinnerID:    rh.WinnerID,
+			TimedOut:    rh.TimedOut,
+		}
+	}
+	return summarize(standings, rounds)
+}
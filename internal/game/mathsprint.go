@@ -2,11 +2,19 @@ package game
 
 import (
 	"GoServerGames/internal/net"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"time"
 )
 
+func init() {
+	Register("mathsprint", func(id string, roomCode string) MiniGame {
+		return NewMathSprintRoom(id, roomCode)
+	})
+}
+
 type MathOperation int
 
 const (
@@ -81,37 +89,39 @@ func GenerateMathQuestion() MathQuestion {
 }
 
 type MathSprintPlayer struct {
-	ID             int
-	Name           string
-	Score          int
-	LastTimeMs     float64
-	Connected      bool
-	ReadyForNext   bool
+	ID              int
+	Name            string
+	Score           int
+	LastTimeMs      float64
+	Connected       bool
 	ReadyForNewGame bool
 }
 
 type MathRoundHistory struct {
-	RoundNumber   int
-	Player1TimeMs float64
-	Player2TimeMs float64
-	WinnerID      int
-	Question      string
-	Answer        int
+	RoundNumber int
+	Results     []Placement // Fastest first, then players who didn't answer
+	WinnerID    int
+	Question    string
+	Answer      int
+	TimedOut    bool // Round ended at the deadline instead of every player answering
 }
 
 type MathSprintRoom struct {
-	ID                string
-	RoomCode          string // Room code this game belongs to (for isolation)
-	Players           [2]*MathSprintPlayer
-	CurrentQuestion   MathQuestion
-	State             string // "waiting", "ready", "playing", "results", "finished"
-	RoundStartTime    time.Time
-	Player1SubmitTime float64
-	Player2SubmitTime float64
-	RoundWinner       int
-	RoundNumber       int
-	RoundHistory      []MathRoundHistory
-	GameEnded         bool
+	ID              string
+	RoomCode        string // Room code this game belongs to (for isolation)
+	Players         []*MathSprintPlayer
+	CurrentQuestion MathQuestion
+	State           string // "waiting", "ready", "playing", "results", "finished"
+	RoundStartTime  time.Time
+	RoundDeadline   time.Time // Zero when rounds have no time limit
+	RoundTimedOut   bool
+	SubmitTimes     map[int]float64 // Answer times this round keyed by player ID
+	RoundResults    []Placement
+	RoundWinner     int
+	RoundNumber     int
+	RoundHistory    []MathRoundHistory
+	Ratings         map[int]RatingChange // Set once the game is rated, keyed by player ID
+	GameEnded       bool
 }
 
 func NewMathSprintRoom(id string, roomCode string) *MathSprintRoom {
@@ -120,38 +130,81 @@ func NewMathSprintRoom(id string, roomCode string) *MathSprintRoom {
 		RoomCode:     roomCode,
 		State:        "waiting",
 		RoundNumber:  0,
+		SubmitTimes:  make(map[int]float64),
 		RoundHistory: make([]MathRoundHistory, 0),
 	}
 }
 
 func (r *MathSprintRoom) AddPlayer(id int, name string) {
-	if r.Players[0] == nil {
-		r.Players[0] = &MathSprintPlayer{
-			ID:        id,
-			Name:      name,
-			Score:     0,
-			Connected: true,
-		}
-	} else if r.Players[1] == nil {
-		r.Players[1] = &MathSprintPlayer{
-			ID:        id,
-			Name:      name,
-			Score:     0,
-			Connected: true,
-		}
+	if len(r.Players) >= MaxPlayers {
+		return
+	}
+	r.Players = append(r.Players, &MathSprintPlayer{
+		ID:        id,
+		Name:      name,
+		Connected: true,
+	})
+	if len(r.Players) >= MinPlayers {
 		r.State = "ready"
 	}
 }
 
-func (r *MathSprintRoom) ResetReadyForNext() {
+func (r *MathSprintRoom) player(playerID int) *MathSprintPlayer {
 	for _, player := range r.Players {
-		if player != nil {
-			player.ReadyForNext = false
+		if player.ID == playerID {
+			return player
 		}
 	}
+	return nil
 }
 
-func (r *MathSprintRoom) StartRound() {
+func (r *MathSprintRoom) SetReadyForNewGame(playerID int, ready bool) bool {
+	if player := r.player(playerID); player != nil {
+		player.ReadyForNewGame = ready
+		return true
+	}
+	return false
+}
+
+func (r *MathSprintRoom) IsReadyForNewGame(playerID int) bool {
+	if player := r.player(playerID); player != nil {
+		return player.ReadyForNewGame
+	}
+	return false
+}
+
+func (r *MathSprintRoom) AllReadyForNewGame() bool {
+	for _, player := range r.Players {
+		if !player.ReadyForNewGame {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *MathSprintRoom) ResetGame() {
+	// Reset all game state for a new game
+	r.RoundNumber = 0
+	r.RoundHistory = make([]MathRoundHistory, 0)
+	r.Ratings = nil
+	r.State = "ready" // Everyone is still seated
+	r.CurrentQuestion = MathQuestion{}
+	r.RoundDeadline = time.Time{}
+	r.RoundTimedOut = false
+	r.SubmitTimes = make(map[int]float64)
+	r.RoundResults = nil
+	r.RoundWinner = 0
+	r.GameEnded = false
+
+	// Reset player scores and ready status
+	for _, player := range r.Players {
+		player.Score = 0
+		player.LastTimeMs = 0
+		player.ReadyForNewGame = false
+	}
+}
+
+func (r *MathSprintRoom) StartRound(timeLimit time.Duration) {
 	if r.State != "ready" && r.State != "results" {
 		return
 	}
@@ -160,8 +213,13 @@ func (r *MathSprintRoom) StartRound() {
 	r.CurrentQuestion = GenerateMathQuestion()
 	r.State = "playing"
 	r.RoundStartTime = time.Now()
-	r.Player1SubmitTime = 0
-	r.Player2SubmitTime = 0
+	r.RoundDeadline = time.Time{}
+	if timeLimit > 0 {
+		r.RoundDeadline = r.RoundStartTime.Add(timeLimit)
+	}
+	r.RoundTimedOut = false
+	r.SubmitTimes = make(map[int]float64)
+	r.RoundResults = nil
 	r.RoundWinner = 0
 }
 
@@ -174,14 +232,7 @@ func (r *MathSprintRoom) SubmitAnswer(playerID int, answer int, timeMs float64)
 		return false // Wrong answer
 	}
 
-	playerIdx := -1
-	if r.Players[0] != nil && r.Players[0].ID == playerID {
-		playerIdx = 0
-	} else if r.Players[1] != nil && r.Players[1].ID == playerID {
-		playerIdx = 1
-	}
-
-	if playerIdx == -1 {
+	if r.player(playerID) == nil || r.SubmitTimes[playerID] > 0 {
 		return false
 	}
 
@@ -190,163 +241,219 @@ func (r *MathSprintRoom) SubmitAnswer(playerID int, answer int, timeMs float64)
 	if timeMs > elapsedMs+1000 {
 		timeMs = elapsedMs
 	}
+	if timeMs <= 0 {
+		timeMs = 1 // 0 means no answer
+	}
 
 	// Store submission time
-	if playerIdx == 0 {
-		r.Player1SubmitTime = timeMs
-	} else {
-		r.Player2SubmitTime = timeMs
-	}
-
-	// Check if both players submitted
-	if r.Player1SubmitTime > 0 && r.Player2SubmitTime > 0 {
-		r.State = "results"
-		// Determine winner (faster time wins)
-		if r.Player1SubmitTime < r.Player2SubmitTime {
-			r.RoundWinner = r.Players[0].ID
-			r.Players[0].Score++
-		} else if r.Player2SubmitTime < r.Player1SubmitTime {
-			r.RoundWinner = r.Players[1].ID
-			r.Players[1].Score++
-		}
-
-		// Store times for display
-		r.Players[0].LastTimeMs = r.Player1SubmitTime
-		r.Players[1].LastTimeMs = r.Player2SubmitTime
+	r.SubmitTimes[playerID] = timeMs
 
-		// Add to history
-		r.RoundHistory = append(r.RoundHistory, MathRoundHistory{
-			RoundNumber:   r.RoundNumber,
-			Player1TimeMs: r.Player1SubmitTime,
-			Player2TimeMs: r.Player2SubmitTime,
-			WinnerID:      r.RoundWinner,
-			Question:      r.CurrentQuestion.Display,
-			Answer:        r.CurrentQuestion.Answer,
-		})
+	if r.roundComplete() {
+		r.endRound(false)
 	}
 
 	return true
 }
 
-func (r *MathSprintRoom) GetState() *net.MathSprintStateMessage {
-	scores := []net.MathSprintScore{}
+// roundComplete reports whether every connected player has answered, and at
+// least one is still connected
+func (r *MathSprintRoom) roundComplete() bool {
+	connected := 0
+	for _, player := range r.Players {
+		if !player.Connected {
+			continue
+		}
+		if r.SubmitTimes[player.ID] == 0 {
+			return false
+		}
+		connected++
+	}
+	return connected > 0
+}
 
-	if r.Players[0] != nil {
-		scores = append(scores, net.MathSprintScore{
-			PlayerID: r.Players[0].ID,
-			Name:     r.Players[0].Name,
-			Score:    r.Players[0].Score,
-			TimeMs:   r.Players[0].LastTimeMs,
-		})
+// ExpireRound ends a round whose deadline has passed. Players who answered
+// in time are placed by their times; everyone else scores nothing.
+func (r *MathSprintRoom) ExpireRound() {
+	if r.State != "playing" {
+		return
 	}
-	if r.Players[1] != nil {
-		scores = append(scores, net.MathSprintScore{
-			PlayerID: r.Players[1].ID,
-			Name:     r.Players[1].Name,
-			Score:    r.Players[1].Score,
-			TimeMs:   r.Players[1].LastTimeMs,
-		})
+	r.endRound(true)
+}
+
+// endRound places the players, awards points and records the round
+func (r *MathSprintRoom) endRound(timedOut bool) {
+	r.State = "results"
+	r.RoundTimedOut = timedOut
+	r.RoundResults, r.RoundWinner = rankRound(r.PlayerIDs(), r.SubmitTimes)
+	for _, result := range r.RoundResults {
+		player := r.player(result.PlayerID)
+		player.Score += result.Points
+		player.LastTimeMs = result.TimeMs // Store times for display
+	}
+	r.recordRoundHistory()
+}
+
+func (r *MathSprintRoom) recordRoundHistory() {
+	r.RoundHistory = append(r.RoundHistory, MathRoundHistory{
+		RoundNumber: r.RoundNumber,
+		Results:     r.RoundResults,
+		WinnerID:    r.RoundWinner,
+		Question:    r.CurrentQuestion.Display,
+		Answer:      r.CurrentQuestion.Answer,
+		TimedOut:    r.RoundTimedOut,
+	})
+}
+
+func (r *MathSprintRoom) GetState() *net.MathSprintStateMessage {
+	scores := make([]net.MathSprintScore, len(r.Players))
+	for i, player := range r.Players {
+		scores[i] = net.MathSprintScore{
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Score:    player.Score,
+			TimeMs:   player.LastTimeMs,
+		}
 	}
 
 	msg := &net.MathSprintStateMessage{
 		Type:     "mathSprintState",
 		Question: r.CurrentQuestion.Display,
-		Answer:   r.CurrentQuestion.Answer,
 		State:    r.State,
 		Scores:   scores,
 	}
 
+	if r.State == "playing" && !r.RoundDeadline.IsZero() {
+		msg.DeadlineMs = r.RoundDeadline.UnixMilli()
+		msg.TimeLeftMs = timeLeftMs(r.RoundDeadline)
+	}
+
 	if r.State == "results" {
+		answer := r.CurrentQuestion.Answer
+		msg.Answer = &answer
 		msg.RoundResult = &net.MathSprintResult{
 			WinnerID:      r.RoundWinner,
-			Player1TimeMs: r.Player1SubmitTime,
-			Player2TimeMs: r.Player2SubmitTime,
+			Results:       resultMessages(r.RoundResults),
 			CorrectAnswer: r.CurrentQuestion.Answer,
+			TimedOut:      r.RoundTimedOut,
 		}
 	}
 
 	return msg
 }
 
-func (r *MathSprintRoom) CheckGameEnd() bool {
-	return r.RoundNumber >= 10 || r.GameEnded
+// MiniGame implementation
+
+func (r *MathSprintRoom) GetID() string       { return r.ID }
+func (r *MathSprintRoom) GetRoomCode() string { return r.RoomCode }
+func (r *MathSprintRoom) GameType() string    { return "mathsprint" }
+func (r *MathSprintRoom) SubmitType() string  { return "mathSprintSubmit" }
+func (r *MathSprintRoom) Phase() string       { return r.State }
+func (r *MathSprintRoom) Deadline() time.Time { return r.RoundDeadline }
+func (r *MathSprintRoom) Ended() bool         { return r.GameEnded }
+func (r *MathSprintRoom) End()                { r.GameEnded = true }
+
+func (r *MathSprintRoom) PlayerIDs() []int {
+	ids := make([]int, len(r.Players))
+	for i, player := range r.Players {
+		ids[i] = player.ID
+	}
+	return ids
 }
 
-func (r *MathSprintRoom) GetGameSummary() *MathGameSummary {
-	if len(r.RoundHistory) == 0 {
-		return nil
+func (r *MathSprintRoom) Scores() map[int]int {
+	scores := make(map[int]int)
+	for _, player := range r.Players {
+		scores[player.ID] = player.Score
 	}
+	return scores
+}
 
-	var p1TotalTime, p2TotalTime float64
-	for _, rh := range r.RoundHistory {
-		p1TotalTime += rh.Player1TimeMs
-		p2TotalTime += rh.Player2TimeMs
+func (r *MathSprintRoom) SetConnected(playerID int, connected bool) {
+	if player := r.player(playerID); player != nil {
+		player.Connected = connected
 	}
+	// The round may have been waiting only on the player who left
+	if !connected && r.State == "playing" && r.roundComplete() {
+		r.endRound(false)
+	}
+}
 
-	numRounds := float64(len(r.RoundHistory))
-	p1AvgTime := p1TotalTime / numRounds
-	p2AvgTime := p2TotalTime / numRounds
+func (r *MathSprintRoom) NextRound() {
+	r.State = "ready"
+}
 
-	var winnerID int
-	if r.Players[0] != nil && r.Players[1] != nil {
-		if r.Players[0].Score > r.Players[1].Score {
-			winnerID = r.Players[0].ID
-		} else if r.Players[1].Score > r.Players[0].Score {
-			winnerID = r.Players[1].ID
-		}
+func (r *MathSprintRoom) Submit(playerID int, payload []byte) bool {
+	var msg net.MathSprintSubmitMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return false
 	}
+	return r.SubmitAnswer(playerID, msg.Answer, msg.TimeMs)
+}
 
-	summary := &MathGameSummary{
-		WinnerID:     winnerID,
-		RoundHistory: make([]MathRoundHistoryData, len(r.RoundHistory)),
-	}
+func (r *MathSprintRoom) StateMessage() interface{} {
+	return r.GetState()
+}
 
-	if r.Players[0] != nil {
-		summary.Player1ID = r.Players[0].ID
-		summary.Player1Name = r.Players[0].Name
-		summary.Player1Score = r.Players[0].Score
-		summary.Player1AvgTime = p1AvgTime
-	}
-	if r.Players[1] != nil {
-		summary.Player2ID = r.Players[1].ID
-		summary.Player2Name = r.Players[1].Name
-		summary.Player2Score = r.Players[1].Score
-		summary.Player2AvgTime = p2AvgTime
+// SpectatorStateMessage is the regular state, which already withholds the answer until results
+func (r *MathSprintRoom) SpectatorStateMessage() interface{} {
+	return r.GetState()
+}
+
+func (r *MathSprintRoom) SummaryMessage() interface{} {
+	summary := r.GetGameSummary()
+	if summary == nil {
+		return nil
 	}
 
+	msg := &net.MathGameSummaryMessage{
+		Type:         "mathGameSummary",
+		Players:      standingMessages(summary.Standings),
+		WinnerID:     summary.WinnerID,
+		RoundHistory: make([]net.MathRoundHistoryData, len(r.RoundHistory)),
+	}
 	for i, rh := range r.RoundHistory {
-		summary.RoundHistory[i] = MathRoundHistoryData{
-			RoundNumber:   rh.RoundNumber,
-			Player1TimeMs: rh.Player1TimeMs,
-			Player2TimeMs: rh.Player2TimeMs,
-			WinnerID:      rh.WinnerID,
-			Question:      rh.Question,
-			Answer:        rh.Answer,
+		msg.RoundHistory[i] = net.MathRoundHistoryData{
+			RoundNumber: rh.RoundNumber,
+			Results:     resultMessages(rh.Results),
+			WinnerID:    rh.WinnerID,
+			Question:    rh.Question,
+			Answer:      rh.Answer,
+			TimedOut:    rh.TimedOut,
 		}
 	}
-
-	return summary
+	return msg
 }
 
-type MathGameSummary struct {
-	Player1ID      int
-	Player1Name    string
-	Player1Score   int
-	Player1AvgTime float64
-	Player2ID      int
-	Player2Name    string
-	Player2Score   int
-	Player2AvgTime float64
-	WinnerID       int
-	RoundHistory   []MathRoundHistoryData
+func (r *MathSprintRoom) SetRatings(ratings map[int]RatingChange) {
+	r.Ratings = ratings
 }
 
-type MathRoundHistoryData struct {
-	RoundNumber   int
-	Player1TimeMs float64
-	Player2TimeMs float64
-	WinnerID      int
-	Question      string
-	Answer        int
-}
+func (r *MathSprintRoom) GetGameSummary() *GameSummary {
+	if len(r.RoundHistory) == 0 {
+		return nil
+	}
 
+	standings := make([]Standing, len(r.Players))
+	for i, player := range r.Players {
+		rating := r.Ratings[player.ID]
+		standings[i] = Standing{
+			PlayerID:     player.ID,
+			Name:         player.Name,
+			Score:        player.Score,
+			Rating:       rating.Rating,
+			RatingChange: rating.Change,
+		}
+	}
+	rounds := make([]RoundSummary, len(r.RoundHistory))
+	for i, rh := range r.RoundHistory {
+		rounds[i] = RoundSummary{
+			RoundNumber: rh.RoundNumber,
+			Results:     rh.Results,
+			WinnerID:    rh.WinnerID,
+			TimedOut:    rh.TimedOut,
+			Prompt:      rh.Question,
+			Answer:      strconv.Itoa(rh.Answer),
+		}
+	}
+	return summarize(standings, rounds)
+}
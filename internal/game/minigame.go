@@ -0,0 +1,90 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MiniGame is a round-based game played by the players of one room code.
+// Each minigame lives in its own file and registers a factory in init.
+type MiniGame interface {
+	GetID() string
+	GetRoomCode() string
+	GameType() string
+	SubmitType() string // Client message type that carries a submission
+
+	AddPlayer(id int, name string)
+	PlayerIDs() []int
+	SetConnected(playerID int, connected bool)
+
+	Phase() string       // "waiting", "ready", "playing", "results"
+	Scores() map[int]int // Points keyed by player ID
+	StartRound(timeLimit time.Duration)
+	NextRound() // Prepare for the next StartRound after results
+	Submit(playerID int, payload []byte) bool
+	Deadline() time.Time // When the current round times out
+	ExpireRound()        // Resolve a round whose deadline passed; non-submitters score nothing
+
+	SetReadyForNewGame(playerID int, ready bool) bool
+	IsReadyForNewGame(playerID int) bool
+	AllReadyForNewGame() bool
+	ResetGame() // Clear scores and history for a rematch with the same players
+
+	StateMessage() interface{}               // Per-round state message sent to clients
+	SpectatorStateMessage() interface{}      // Same, minus anything that gives away the answer before results
+	SummaryMessage() interface{}             // End of game summary message, nil if no rounds were played
+	GetGameSummary() *GameSummary            // Final standings, nil if no rounds were played
+	SetRatings(ratings map[int]RatingChange) // Skill ratings after the game, keyed by player ID
+	Ended() bool
+	End()
+}
+
+// Factory creates a new room for a registered minigame
+type Factory func(id string, roomCode string) MiniGame
+
+var registry = make(map[string]Factory)
+
+// Register makes a minigame available under the given game type.
+// It is meant to be called from an init function in the minigame's file.
+func Register(gameType string, factory Factory) {
+	if _, exists := registry[gameType]; exists {
+		panic("game: Register called twice for " + gameType)
+	}
+	registry[gameType] = factory
+}
+
+// New creates a room for the given game type
+func New(gameType string, id string, roomCode string) (MiniGame, error) {
+	factory, ok := registry[gameType]
+	if !ok {
+		return nil, fmt.Errorf("unknown game type: %s", gameType)
+	}
+	return factory(id, roomCode), nil
+}
+
+// IsRegistered reports whether a minigame exists for the given game type
+func IsRegistered(gameType string) bool {
+	_, ok := registry[gameType]
+	return ok
+}
+
+// Types returns the registered game types in sorted order
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for gameType := range registry {
+		types = append(types, gameType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// timeLeftMs returns the milliseconds until a round deadline, never less than 1
+// so a running countdown is not dropped from the state message
+func timeLeftMs(deadline time.Time) int64 {
+	left := time.Until(deadline).Milliseconds()
+	if left < 1 {
+		return 1
+	}
+	return left
+}
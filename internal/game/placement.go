@@ -0,0 +1,164 @@
+package game
+
+import (
+	"GoServerGames/internal/net"
+	"sort"
+)
+
+// Minigame rooms seat between MinPlayers and MaxPlayers players
+const (
+	MinPlayers = 2
+	MaxPlayers = 8
+)
+
+// Placement is one player's finish in a round
+type Placement struct {
+	PlayerID int
+	TimeMs   float64 // 0 if the player didn't submit before the round ended
+	Place    int     // 1 is the fastest, shared on a tie; 0 if the player didn't submit
+	Points   int     // One for every player finished ahead of
+}
+
+// rankRound places the players who submitted by their time, fastest first,
+// followed by everyone who didn't. Each player scores a point for every
+// player they finished ahead of, so with two players a round win is worth
+// one point and a tie is worth nothing. The winner is the only player in
+// first place, or 0 on a tie or when nobody submitted.
+func rankRound(playerIDs []int, times map[int]float64) ([]Placement, int) {
+	placements := make([]Placement, 0, len(playerIDs))
+	var missed []Placement
+	for _, id := range playerIDs {
+		if times[id] > 0 {
+			placements = append(placements, Placement{PlayerID: id, TimeMs: times[id]})
+		} else {
+			missed = append(missed, Placement{PlayerID: id})
+		}
+	}
+	sort.SliceStable(placements, func(i, j int) bool {
+		return placements[i].TimeMs < placements[j].TimeMs
+	})
+
+	for i := range placements {
+		if i > 0 && placements[i].TimeMs == placements[i-1].TimeMs {
+			placements[i].Place = placements[i-1].Place
+		} else {
+			placements[i].Place = i + 1
+		}
+	}
+	for i := range placements {
+		behind := len(missed)
+		for _, other := range placements {
+			if other.TimeMs > placements[i].TimeMs {
+				behind++
+			}
+		}
+		placements[i].Points = behind
+	}
+
+	winnerID := 0
+	if len(placements) == 1 || (len(placements) > 1 && placements[1].Place > 1) {
+		winnerID = placements[0].PlayerID
+	}
+	return append(placements, missed...), winnerID
+}
+
+// Standing is a player's final position in a game
+type Standing struct {
+	PlayerID  int
+	Name      string
+	Score     int
+	AvgTimeMs float64 // Over the rounds the player submitted in
+	Place     int     // 1 is the top score, shared on a tie
+
+	Rating       int // Skill rating after the game, 0 if the game isn't rated
+	RatingChange int
+}
+
+// RatingChange is a player's skill rating after a game and how far it moved
+type RatingChange struct {
+	Rating int
+	Change int
+}
+
+// RoundSummary is one played round, in the same form for every minigame
+type RoundSummary struct {
+	RoundNumber int
+	Results     []Placement // Fastest first, then players who didn't submit
+	WinnerID    int
+	TimedOut    bool
+	Prompt      string // What players had to answer, e.g. the word or question; "" if nothing
+	Answer      string // The expected answer to the prompt, "" if it is the prompt itself
+}
+
+// GameSummary is the end of game result shared by every minigame
+type GameSummary struct {
+	Standings []Standing // Ordered by place
+	WinnerID  int        // 0 if the top score is tied
+	Rounds    []RoundSummary
+}
+
+// summarize ranks players by score and works out their average times from
+// the placements of every round played
+func summarize(standings []Standing, rounds []RoundSummary) *GameSummary {
+	totals := make(map[int]float64)
+	counts := make(map[int]int)
+	for _, round := range rounds {
+		for _, p := range round.Results {
+			if p.TimeMs > 0 {
+				totals[p.PlayerID] += p.TimeMs
+				counts[p.PlayerID]++
+			}
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Score > standings[j].Score
+	})
+	for i := range standings {
+		if counts[standings[i].PlayerID] > 0 {
+			standings[i].AvgTimeMs = totals[standings[i].PlayerID] / float64(counts[standings[i].PlayerID])
+		}
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Place = standings[i-1].Place
+		} else {
+			standings[i].Place = i + 1
+		}
+	}
+
+	summary := &GameSummary{Standings: standings, Rounds: rounds}
+	if len(standings) == 1 || (len(standings) > 1 && standings[1].Place > 1) {
+		summary.WinnerID = standings[0].PlayerID
+	}
+	return summary
+}
+
+// resultMessages converts round placements to their protocol form
+func resultMessages(placements []Placement) []net.PlayerResult {
+	results := make([]net.PlayerResult, len(placements))
+	for i, p := range placements {
+		results[i] = net.PlayerResult{
+			PlayerID: p.PlayerID,
+			TimeMs:   p.TimeMs,
+			Place:    p.Place,
+			Points:   p.Points,
+		}
+	}
+	return results
+}
+
+// standingMessages converts final standings to their protocol form
+func standingMessages(standings []Standing) []net.PlayerStanding {
+	players := make([]net.PlayerStanding, len(standings))
+	for i, s := range standings {
+		players[i] = net.PlayerStanding{
+			PlayerID:     s.PlayerID,
+			Name:         s.Name,
+			Score:        s.Score,
+			AvgTimeMs:    s.AvgTimeMs,
+			Place:        s.Place,
+			Rating:       s.Rating,
+			RatingChange: s.RatingChange,
+		}
+	}
+	return players
+}
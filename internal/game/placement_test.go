@@ -0,0 +1,147 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankRound(t *testing.T) {
+	tests := []struct {
+		name       string
+		playerIDs  []int
+		times      map[int]float64
+		want       []Placement
+		wantWinner int
+	}{
+		{
+			name:       "two players",
+			playerIDs:  []int{1, 2},
+			times:      map[int]float64{1: 200, 2: 100},
+			want:       []Placement{{2, 100, 1, 1}, {1, 200, 2, 0}},
+			wantWinner: 2,
+		},
+		{
+			name:       "two players tied",
+			playerIDs:  []int{1, 2},
+			times:      map[int]float64{1: 150, 2: 150},
+			want:       []Placement{{1, 150, 1, 0}, {2, 150, 1, 0}},
+			wantWinner: 0,
+		},
+		{
+			name:       "opponent forfeits",
+			playerIDs:  []int{1, 2},
+			times:      map[int]float64{1: 300},
+			want:       []Placement{{1, 300, 1, 1}, {2, 0, 0, 0}},
+			wantWinner: 1,
+		},
+		{
+			name:       "nobody submits",
+			playerIDs:  []int{1, 2, 3},
+			times:      map[int]float64{},
+			want:       []Placement{{1, 0, 0, 0}, {2, 0, 0, 0}, {3, 0, 0, 0}},
+			wantWinner: 0,
+		},
+		{
+			name:       "tie for first",
+			playerIDs:  []int{1, 2, 3},
+			times:      map[int]float64{1: 100, 2: 100, 3: 200},
+			want:       []Placement{{1, 100, 1, 1}, {2, 100, 1, 1}, {3, 200, 3, 0}},
+			wantWinner: 0,
+		},
+		{
+			name:       "tie for second",
+			playerIDs:  []int{1, 2, 3, 4},
+			times:      map[int]float64{1: 300, 2: 100, 3: 200, 4: 200},
+			want:       []Placement{{2, 100, 1, 3}, {3, 200, 2, 1}, {4, 200, 2, 1}, {1, 300, 4, 0}},
+			wantWinner: 2,
+		},
+		{
+			name:      "eight players with two forfeits",
+			playerIDs: []int{1, 2, 3, 4, 5, 6, 7, 8},
+			times:     map[int]float64{1: 80, 2: 70, 3: 60, 4: 50, 5: 40, 6: 30},
+			want: []Placement{
+				{6, 30, 1, 7}, {5, 40, 2, 6}, {4, 50, 3, 5}, {3, 60, 4, 4},
+				{2, 70, 5, 3}, {1, 80, 6, 2}, {7, 0, 0, 0}, {8, 0, 0, 0},
+			},
+			wantWinner: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, winner := rankRound(tt.playerIDs, tt.times)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankRound placements = %v, want %v", got, tt.want)
+			}
+			if winner != tt.wantWinner {
+				t.Errorf("rankRound winner = %d, want %d", winner, tt.wantWinner)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	// Player 3 never submits; player 2 forfeits the second round
+	rounds := []RoundSummary{
+		{RoundNumber: 1, Results: []Placement{{1, 100, 1, 2}, {2, 300, 2, 1}, {3, 0, 0, 0}}, WinnerID: 1},
+		{RoundNumber: 2, Results: []Placement{{1, 200, 1, 2}, {2, 0, 0, 0}, {3, 0, 0, 0}}, WinnerID: 1},
+	}
+
+	tests := []struct {
+		name       string
+		standings  []Standing
+		rounds     []RoundSummary
+		want       []Standing
+		wantWinner int
+	}{
+		{
+			name:      "clear winner",
+			standings: []Standing{{PlayerID: 3, Score: 0}, {PlayerID: 2, Score: 1}, {PlayerID: 1, Score: 4}},
+			rounds:    rounds,
+			want: []Standing{
+				{PlayerID: 1, Score: 4, AvgTimeMs: 150, Place: 1},
+				{PlayerID: 2, Score: 1, AvgTimeMs: 300, Place: 2},
+				{PlayerID: 3, Score: 0, AvgTimeMs: 0, Place: 3},
+			},
+			wantWinner: 1,
+		},
+		{
+			name:      "tied top score",
+			standings: []Standing{{PlayerID: 1, Score: 2}, {PlayerID: 2, Score: 2}},
+			want: []Standing{
+				{PlayerID: 1, Score: 2, Place: 1},
+				{PlayerID: 2, Score: 2, Place: 1},
+			},
+			wantWinner: 0,
+		},
+		{
+			name: "tie for second",
+			standings: []Standing{
+				{PlayerID: 1, Score: 1}, {PlayerID: 2, Score: 3}, {PlayerID: 3, Score: 5}, {PlayerID: 4, Score: 3},
+			},
+			want: []Standing{
+				{PlayerID: 3, Score: 5, Place: 1},
+				{PlayerID: 2, Score: 3, Place: 2},
+				{PlayerID: 4, Score: 3, Place: 2},
+				{PlayerID: 1, Score: 1, Place: 4},
+			},
+			wantWinner: 3,
+		},
+		{
+			name:       "nobody scores",
+			standings:  []Standing{{PlayerID: 1}, {PlayerID: 2}},
+			want:       []Standing{{PlayerID: 1, Place: 1}, {PlayerID: 2, Place: 1}},
+			wantWinner: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := summarize(tt.standings, tt.rounds)
+			if !reflect.DeepEqual(summary.Standings, tt.want) {
+				t.Errorf("summarize standings = %+v, want %+v", summary.Standings, tt.want)
+			}
+			if summary.WinnerID != tt.wantWinner {
+				t.Errorf("summarize winner = %d, want %d", summary.WinnerID, tt.wantWinner)
+			}
+		})
+	}
+}
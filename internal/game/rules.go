@@ -0,0 +1,102 @@
+package game
+
+import "fmt"
+
+// Match modes
+const (
+	MatchFixedRounds = "rounds"  // Play exactly Count rounds
+	MatchBestOf      = "bestOf"  // Best of Count rounds, ends early once the leader can't be caught
+	MatchFirstTo     = "firstTo" // First player to reach Count points
+)
+
+// MaxMatchRounds caps how long a match can run, so first-to and sudden
+// death matches still end when rounds keep finishing without a winner
+const MaxMatchRounds = 25
+
+// MatchRules decide when a match is over. They are picked in the lobby and
+// enforced by the game room after every round.
+type MatchRules struct {
+	Mode        string
+	Count       int  // Rounds to play (rounds, bestOf) or points needed (firstTo)
+	SuddenDeath bool // Keep playing single rounds while the top score is tied
+}
+
+// DefaultMatchRules is the given number of rounds with no sudden death
+func DefaultMatchRules(rounds int) MatchRules {
+	return MatchRules{Mode: MatchFixedRounds, Count: rounds}
+}
+
+func (r MatchRules) Validate() error {
+	switch r.Mode {
+	case MatchFixedRounds, MatchBestOf, MatchFirstTo:
+	default:
+		return fmt.Errorf("unknown match mode: %s", r.Mode)
+	}
+	if r.Count < 1 || r.Count > MaxMatchRounds {
+		return fmt.Errorf("round count must be between 1 and %d", MaxMatchRounds)
+	}
+	return nil
+}
+
+// Over reports whether the match is finished after roundsPlayed rounds
+// with the given scores (keyed by player ID). A round is worth at most one
+// point per opponent, which is how far the leader can be caught up each round.
+func (r MatchRules) Over(roundsPlayed int, scores map[int]int) bool {
+	if roundsPlayed >= MaxMatchRounds {
+		return true
+	}
+
+	top, second := topTwoScores(scores)
+
+	var over bool
+	switch r.Mode {
+	case MatchBestOf:
+		remaining := r.Count - roundsPlayed
+		maxRoundPoints := len(scores) - 1
+		if maxRoundPoints < 1 {
+			maxRoundPoints = 1
+		}
+		over = remaining <= 0 || top-second > remaining*maxRoundPoints
+	case MatchFirstTo:
+		over = top >= r.Count
+	default:
+		over = roundsPlayed >= r.Count
+	}
+
+	if over && r.SuddenDeath && len(scores) > 1 && top == second {
+		return false
+	}
+	return over
+}
+
+// SuddenDeathRound reports whether the next round is played only to break a tie
+func (r MatchRules) SuddenDeathRound(roundsPlayed int) bool {
+	return r.SuddenDeath && r.Mode != MatchFirstTo && roundsPlayed >= r.Count
+}
+
+func (r MatchRules) String() string {
+	var desc string
+	switch r.Mode {
+	case MatchBestOf:
+		desc = fmt.Sprintf("best of %d", r.Count)
+	case MatchFirstTo:
+		desc = fmt.Sprintf("first to %d", r.Count)
+	default:
+		desc = fmt.Sprintf("%d rounds", r.Count)
+	}
+	if r.SuddenDeath {
+		desc += " with sudden death"
+	}
+	return desc
+}
+
+func topTwoScores(scores map[int]int) (top, second int) {
+	for _, score := range scores {
+		if score > top {
+			top, second = score, top
+		} else if score > second {
+			second = score
+		}
+	}
+	return top, second
+}
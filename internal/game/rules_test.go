@@ -0,0 +1,72 @@
+package game
+
+import "testing"
+
+func TestMatchRulesOver(t *testing.T) {
+	rounds := func(n int) MatchRules { return MatchRules{Mode: MatchFixedRounds, Count: n} }
+	bestOf := func(n int) MatchRules { return MatchRules{Mode: MatchBestOf, Count: n} }
+	firstTo := func(n int) MatchRules { return MatchRules{Mode: MatchFirstTo, Count: n} }
+	suddenDeath := func(r MatchRules) MatchRules {
+		r.SuddenDeath = true
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		rules  MatchRules
+		played int
+		scores map[int]int
+		want   bool
+	}{
+		{"rounds not all played", rounds(5), 4, map[int]int{1: 4, 2: 0}, false},
+		{"rounds all played", rounds(5), 5, map[int]int{1: 3, 2: 2}, true},
+		{"rounds tied without sudden death", rounds(5), 5, map[int]int{1: 2, 2: 2}, true},
+		{"rounds tied with sudden death", suddenDeath(rounds(5)), 5, map[int]int{1: 2, 2: 2}, false},
+		{"sudden death round breaks the tie", suddenDeath(rounds(5)), 6, map[int]int{1: 3, 2: 2}, true},
+		{"sudden death needs a tie at the top", suddenDeath(rounds(3)), 3, map[int]int{1: 6, 2: 2, 3: 2}, true},
+
+		{"best of still open", bestOf(5), 3, map[int]int{1: 2, 2: 1}, false},
+		{"best of decided early", bestOf(5), 3, map[int]int{1: 3, 2: 0}, true},
+		{"best of lead just catchable", bestOf(5), 3, map[int]int{1: 2, 2: 0}, false},
+		{"best of all played", bestOf(5), 5, map[int]int{1: 3, 2: 2}, true},
+		{"best of tied with sudden death", suddenDeath(bestOf(5)), 5, map[int]int{1: 2, 2: 2}, false},
+		// With four players a round is worth up to three points
+		{"best of four players catchable", bestOf(5), 3, map[int]int{1: 9, 2: 3, 3: 3, 4: 3}, false},
+		{"best of four players decided", bestOf(5), 4, map[int]int{1: 12, 2: 6, 3: 4, 4: 2}, true},
+
+		{"first to not reached", firstTo(3), 4, map[int]int{1: 2, 2: 2}, false},
+		{"first to reached", firstTo(3), 4, map[int]int{1: 3, 2: 1}, true},
+		{"first to reached by two with sudden death", suddenDeath(firstTo(3)), 5, map[int]int{1: 3, 2: 3}, false},
+		{"first to eight players in one round", firstTo(5), 1, map[int]int{1: 7, 2: 6, 3: 5, 4: 4, 5: 3, 6: 2, 7: 1, 8: 0}, true},
+
+		{"round cap ends first to", firstTo(25), MaxMatchRounds, map[int]int{1: 10, 2: 10}, true},
+		{"round cap ends sudden death", suddenDeath(rounds(25)), MaxMatchRounds, map[int]int{1: 12, 2: 12}, true},
+		{"round cap ends sudden death first to", suddenDeath(firstTo(25)), MaxMatchRounds + 1, map[int]int{1: 3, 2: 3, 3: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Over(tt.played, tt.scores); got != tt.want {
+				t.Errorf("%s Over(%d, %v) = %v, want %v", tt.rules, tt.played, tt.scores, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRulesSuddenDeathRound(t *testing.T) {
+	tests := []struct {
+		rules  MatchRules
+		played int
+		want   bool
+	}{
+		{MatchRules{Mode: MatchFixedRounds, Count: 5}, 5, false},
+		{MatchRules{Mode: MatchFixedRounds, Count: 5, SuddenDeath: true}, 4, false},
+		{MatchRules{Mode: MatchFixedRounds, Count: 5, SuddenDeath: true}, 5, true},
+		{MatchRules{Mode: MatchBestOf, Count: 3, SuddenDeath: true}, 3, true},
+		{MatchRules{Mode: MatchFirstTo, Count: 3, SuddenDeath: true}, 5, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.SuddenDeathRound(tt.played); got != tt.want {
+			t.Errorf("%s SuddenDeathRound(%d) = %v, want %v", tt.rules, tt.played, got, tt.want)
+		}
+	}
+}
@@ -2,10 +2,17 @@ package game
 
 import (
 	"GoServerGames/internal/net"
+	"encoding/json"
 	"math/rand"
 	"time"
 )
 
+func init() {
+	Register("speedtype", func(id string, roomCode string) MiniGame {
+		return NewSpeedTypeRoom(id, roomCode)
+	})
+}
+
 // Words and phrases for Speed Type game
 var SpeedTypeWords = []string{
 	// Single words - common but long
@@ -53,116 +60,92 @@ var SpeedTypeWords = []string{
 }
 
 type SpeedTypePlayer struct {
-	ID           int
-	Name         string
-	Score        int
-	LastTimeMs   float64
-	Connected    bool
-	ReadyForNext bool // Ready for next round
+	ID              int
+	Name            string
+	Score           int
+	LastTimeMs      float64
+	Connected       bool
 	ReadyForNewGame bool // Ready to play a new game
 }
 
 type RoundHistory struct {
-	RoundNumber    int
-	Player1TimeMs  float64
-	Player2TimeMs  float64
-	WinnerID       int
-	Word           string
+	RoundNumber int
+	Results     []Placement // Fastest first, then players who didn't submit
+	WinnerID    int
+	Word        string
+	TimedOut    bool // Round ended at the deadline instead of every player submitting
 }
 
 type SpeedTypeRoom struct {
-	ID          string
-	RoomCode    string // Room code this game belongs to (for isolation)
-	Players     [2]*SpeedTypePlayer
-	CurrentWord string
-	State       string // "waiting", "ready", "playing", "results", "finished"
+	ID             string
+	RoomCode       string // Room code this game belongs to (for isolation)
+	Players        []*SpeedTypePlayer
+	CurrentWord    string
+	State          string // "waiting", "ready", "playing", "results", "finished"
 	RoundStartTime time.Time
-	Player1SubmitTime float64
-	Player2SubmitTime float64
-	RoundWinner int
-	RoundNumber int
-	RoundHistory []RoundHistory
-	GameEnded   bool
+	RoundDeadline  time.Time // Zero when rounds have no time limit
+	RoundTimedOut  bool
+	SubmitTimes    map[int]float64 // Submission times this round keyed by player ID
+	RoundResults   []Placement
+	RoundWinner    int
+	RoundNumber    int
+	RoundHistory   []RoundHistory
+	Ratings        map[int]RatingChange // Set once the game is rated, keyed by player ID
+	GameEnded      bool
 }
 
 func NewSpeedTypeRoom(id string, roomCode string) *SpeedTypeRoom {
 	return &SpeedTypeRoom{
-		ID:          id,
-		RoomCode:    roomCode,
-		State:       "waiting",
-		RoundNumber: 0,
+		ID:           id,
+		RoomCode:     roomCode,
+		State:        "waiting",
+		RoundNumber:  0,
+		SubmitTimes:  make(map[int]float64),
 		RoundHistory: make([]RoundHistory, 0),
 	}
 }
 
 func (r *SpeedTypeRoom) AddPlayer(id int, name string) {
-	if r.Players[0] == nil {
-		r.		Players[0] = &SpeedTypePlayer{
-			ID:             id,
-			Name:           name,
-			Score:          0,
-			Connected:      true,
-			ReadyForNext:   false,
-			ReadyForNewGame: false,
-		}
-	} else if r.Players[1] == nil {
-		r.		Players[1] = &SpeedTypePlayer{
-			ID:             id,
-			Name:           name,
-			Score:          0,
-			Connected:      true,
-			ReadyForNext:   false,
-			ReadyForNewGame: false,
-		}
-		r.State = "ready"
+	if len(r.Players) >= MaxPlayers {
+		return
 	}
-}
-
-func (r *SpeedTypeRoom) SetReadyForNext(playerID int, ready bool) bool {
-	// Find player and set ready status
-	for _, player := range r.Players {
-		if player != nil && player.ID == playerID {
-			player.ReadyForNext = ready
-			return true
-		}
+	r.Players = append(r.Players, &SpeedTypePlayer{
+		ID:        id,
+		Name:      name,
+		Connected: true,
+	})
+	if len(r.Players) >= MinPlayers {
+		r.State = "ready"
 	}
-	return false
 }
 
-func (r *SpeedTypeRoom) AllReadyForNext() bool {
-	if r.State != "results" {
-		return false
-	}
+func (r *SpeedTypeRoom) player(playerID int) *SpeedTypePlayer {
 	for _, player := range r.Players {
-		if player != nil && !player.ReadyForNext {
-			return false
+		if player.ID == playerID {
+			return player
 		}
 	}
-	return true
+	return nil
 }
 
-func (r *SpeedTypeRoom) ResetReadyForNext() {
-	for _, player := range r.Players {
-		if player != nil {
-			player.ReadyForNext = false
-		}
+func (r *SpeedTypeRoom) SetReadyForNewGame(playerID int, ready bool) bool {
+	if player := r.player(playerID); player != nil {
+		player.ReadyForNewGame = ready
+		return true
 	}
+	return false
 }
 
-func (r *SpeedTypeRoom) SetReadyForNewGame(playerID int, ready bool) bool {
-	// Find player and set ready status
-	for _, player := range r.Players {
-		if player != nil && player.ID == playerID {
-			player.ReadyForNewGame = ready
-			return true
-		}
+func (r *SpeedTypeRoom) IsReadyForNewGame(playerID int) bool {
+	if player := r.player(playerID); player != nil {
+		return player.ReadyForNewGame
 	}
 	return false
 }
 
 func (r *SpeedTypeRoom) AllReadyForNewGame() bool {
 	for _, player := range r.Players {
-		if player != nil && !player.ReadyForNewGame {
+		if !player.ReadyForNewGame {
 			return false
 		}
 	}
@@ -173,24 +156,25 @@ func (r *SpeedTypeRoom) ResetGame() {
 	// Reset all game state for a new game
 	r.RoundNumber = 0
 	r.RoundHistory = make([]RoundHistory, 0)
-	r.State = "waiting"
+	r.Ratings = nil
+	r.State = "ready" // Everyone is still seated
 	r.CurrentWord = ""
-	r.Player1SubmitTime = 0
-	r.Player2SubmitTime = 0
+	r.RoundDeadline = time.Time{}
+	r.RoundTimedOut = false
+	r.SubmitTimes = make(map[int]float64)
+	r.RoundResults = nil
 	r.RoundWinner = 0
-	
+	r.GameEnded = false
+
 	// Reset player scores and ready status
 	for _, player := range r.Players {
-		if player != nil {
-			player.Score = 0
-			player.LastTimeMs = 0
-			player.ReadyForNext = false
-			player.ReadyForNewGame = false
-		}
+		player.Score = 0
+		player.LastTimeMs = 0
+		player.ReadyForNewGame = false
 	}
 }
 
-func (r *SpeedTypeRoom) StartRound() {
+func (r *SpeedTypeRoom) StartRound(timeLimit time.Duration) {
 	if r.State != "ready" && r.State != "results" {
 		return
 	}
@@ -199,8 +183,13 @@ func (r *SpeedTypeRoom) StartRound() {
 	r.CurrentWord = SpeedTypeWords[rand.Intn(len(SpeedTypeWords))]
 	r.State = "playing"
 	r.RoundStartTime = time.Now()
-	r.Player1SubmitTime = 0
-	r.Player2SubmitTime = 0
+	r.RoundDeadline = time.Time{}
+	if timeLimit > 0 {
+		r.RoundDeadline = r.RoundStartTime.Add(timeLimit)
+	}
+	r.RoundTimedOut = false
+	r.SubmitTimes = make(map[int]float64)
+	r.RoundResults = nil
 	r.RoundWinner = 0
 }
 
@@ -213,14 +202,7 @@ func (r *SpeedTypeRoom) SubmitWord(playerID int, word string, timeMs float64) bo
 		return false // Wrong word
 	}
 
-	playerIdx := -1
-	if r.Players[0] != nil && r.Players[0].ID == playerID {
-		playerIdx = 0
-	} else if r.Players[1] != nil && r.Players[1].ID == playerID {
-		playerIdx = 1
-	}
-
-	if playerIdx == -1 {
+	if r.player(playerID) == nil || r.SubmitTimes[playerID] > 0 {
 		return false
 	}
 
@@ -230,53 +212,67 @@ func (r *SpeedTypeRoom) SubmitWord(playerID int, word string, timeMs float64) bo
 		// Client time exceeds server elapsed time by more than 1 second - use server time
 		timeMs = elapsedMs
 	}
-	
+	if timeMs <= 0 {
+		timeMs = 1 // 0 means no submission
+	}
+
 	// Store submission time (no capping - use actual time)
-	if playerIdx == 0 {
-		r.Player1SubmitTime = timeMs
-	} else {
-		r.Player2SubmitTime = timeMs
-	}
-
-	// Check if both players submitted
-	if r.Player1SubmitTime > 0 && r.Player2SubmitTime > 0 {
-		r.State = "results"
-		// Determine winner (faster time wins)
-		if r.Player1SubmitTime < r.Player2SubmitTime {
-			r.RoundWinner = r.Players[0].ID
-			r.Players[0].Score++
-			r.Players[0].LastTimeMs = r.Player1SubmitTime
-		} else {
-			r.RoundWinner = r.Players[1].ID
-			r.Players[1].Score++
-			r.Players[1].LastTimeMs = r.Player2SubmitTime
-		}
-		// Record round history
-		r.recordRoundHistory()
+	r.SubmitTimes[playerID] = timeMs
+
+	if r.roundComplete() {
+		r.endRound(false)
 	}
 
 	return true
 }
 
+// roundComplete reports whether every connected player has submitted, and at
+// least one is still connected
+func (r *SpeedTypeRoom) roundComplete() bool {
+	connected := 0
+	for _, player := range r.Players {
+		if !player.Connected {
+			continue
+		}
+		if r.SubmitTimes[player.ID] == 0 {
+			return false
+		}
+		connected++
+	}
+	return connected > 0
+}
+
+// ExpireRound ends a round whose deadline has passed. Players who submitted
+// in time are placed by their times; everyone else scores nothing.
+func (r *SpeedTypeRoom) ExpireRound() {
+	if r.State != "playing" {
+		return
+	}
+	r.endRound(true)
+}
+
+// endRound places the players, awards points and records the round
+func (r *SpeedTypeRoom) endRound(timedOut bool) {
+	r.State = "results"
+	r.RoundTimedOut = timedOut
+	r.RoundResults, r.RoundWinner = rankRound(r.PlayerIDs(), r.SubmitTimes)
+	for _, result := range r.RoundResults {
+		player := r.player(result.PlayerID)
+		player.Score += result.Points
+		player.LastTimeMs = result.TimeMs
+	}
+	r.recordRoundHistory()
+}
 
 func (r *SpeedTypeRoom) GetState() *net.SpeedTypeStateMessage {
-	scores := []net.SpeedTypeScore{}
-	
-	if r.Players[0] != nil {
-		scores = append(scores, net.SpeedTypeScore{
-			PlayerID: r.Players[0].ID,
-			Name:     r.Players[0].Name,
-			Score:    r.Players[0].Score,
-			TimeMs:   r.Players[0].LastTimeMs,
-		})
-	}
-	if r.Players[1] != nil {
-		scores = append(scores, net.SpeedTypeScore{
-			PlayerID: r.Players[1].ID,
-			Name:     r.Players[1].Name,
-			Score:    r.Players[1].Score,
-			TimeMs:   r.Players[1].LastTimeMs,
-		})
+	scores := make([]net.SpeedTypeScore, len(r.Players))
+	for i, player := range r.Players {
+		scores[i] = net.SpeedTypeScore{
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Score:    player.Score,
+			TimeMs:   player.LastTimeMs,
+		}
 	}
 
 	msg := &net.SpeedTypeStateMessage{
@@ -286,26 +282,25 @@ func (r *SpeedTypeRoom) GetState() *net.SpeedTypeStateMessage {
 		Scores: scores,
 	}
 
+	if r.State == "playing" && !r.RoundDeadline.IsZero() {
+		msg.DeadlineMs = r.RoundDeadline.UnixMilli()
+		msg.TimeLeftMs = timeLeftMs(r.RoundDeadline)
+	}
+
 	if r.State == "results" {
 		msg.RoundResult = &net.SpeedTypeResult{
-			WinnerID:      r.RoundWinner,
-			Player1TimeMs: r.Player1SubmitTime,
-			Player2TimeMs: r.Player2SubmitTime,
-		}
-		
-		// Include ready status for next round
-		readyStatus := []net.ReadyStatus{}
-		if r.Players[0] != nil {
-			readyStatus = append(readyStatus, net.ReadyStatus{
-				PlayerID: r.Players[0].ID,
-				Ready:    r.Players[0].ReadyForNext,
-			})
+			WinnerID: r.RoundWinner,
+			Results:  resultMessages(r.RoundResults),
+			TimedOut: r.RoundTimedOut,
 		}
-		if r.Players[1] != nil {
-			readyStatus = append(readyStatus, net.ReadyStatus{
-				PlayerID: r.Players[1].ID,
-				Ready:    r.Players[1].ReadyForNext,
-			})
+
+		// Include who has asked for a rematch
+		readyStatus := make([]net.ReadyStatus, len(r.Players))
+		for i, player := range r.Players {
+			readyStatus[i] = net.ReadyStatus{
+				PlayerID: player.ID,
+				Ready:    player.ReadyForNewGame,
+			}
 		}
 		msg.ReadyStatus = readyStatus
 	}
@@ -315,84 +310,129 @@ func (r *SpeedTypeRoom) GetState() *net.SpeedTypeStateMessage {
 
 func (r *SpeedTypeRoom) recordRoundHistory() {
 	history := RoundHistory{
-		RoundNumber:   r.RoundNumber,
-		Player1TimeMs: r.Player1SubmitTime,
-		Player2TimeMs: r.Player2SubmitTime,
-		WinnerID:      r.RoundWinner,
-		Word:          r.CurrentWord,
+		RoundNumber: r.RoundNumber,
+		Results:     r.RoundResults,
+		WinnerID:    r.RoundWinner,
+		Word:        r.CurrentWord,
+		TimedOut:    r.RoundTimedOut,
 	}
 	r.RoundHistory = append(r.RoundHistory, history)
 }
 
-func (r *SpeedTypeRoom) CheckGameEnd() bool {
-	// Game ends after 10 rounds or if explicitly marked as ended
-	return r.RoundNumber >= 10 || r.GameEnded
+// MiniGame implementation
+
+func (r *SpeedTypeRoom) GetID() string       { return r.ID }
+func (r *SpeedTypeRoom) GetRoomCode() string { return r.RoomCode }
+func (r *SpeedTypeRoom) GameType() string    { return "speedtype" }
+func (r *SpeedTypeRoom) SubmitType() string  { return "speedTypeSubmit" }
+func (r *SpeedTypeRoom) Phase() string       { return r.State }
+func (r *SpeedTypeRoom) Deadline() time.Time { return r.RoundDeadline }
+func (r *SpeedTypeRoom) Ended() bool         { return r.GameEnded }
+func (r *SpeedTypeRoom) End()                { r.GameEnded = true }
+
+func (r *SpeedTypeRoom) PlayerIDs() []int {
+	ids := make([]int, len(r.Players))
+	for i, player := range r.Players {
+		ids[i] = player.ID
+	}
+	return ids
 }
 
-func (r *SpeedTypeRoom) GetGameSummary() *GameSummary {
-	if len(r.RoundHistory) == 0 {
-		return nil
+func (r *SpeedTypeRoom) Scores() map[int]int {
+	scores := make(map[int]int)
+	for _, player := range r.Players {
+		scores[player.ID] = player.Score
 	}
+	return scores
+}
 
-	// Calculate average times
-	var player1TotalTime, player2TotalTime float64
-	var player1Rounds, player2Rounds int
-
-	for _, round := range r.RoundHistory {
-		if round.Player1TimeMs > 0 {
-			player1TotalTime += round.Player1TimeMs
-			player1Rounds++
-		}
-		if round.Player2TimeMs > 0 {
-			player2TotalTime += round.Player2TimeMs
-			player2Rounds++
-		}
+func (r *SpeedTypeRoom) SetConnected(playerID int, connected bool) {
+	if player := r.player(playerID); player != nil {
+		player.Connected = connected
+	}
+	// The round may have been waiting only on the player who left
+	if !connected && r.State == "playing" && r.roundComplete() {
+		r.endRound(false)
 	}
+}
+
+func (r *SpeedTypeRoom) NextRound() {
+	r.State = "ready"
+}
 
-	player1AvgTime := 0.0
-	if player1Rounds > 0 {
-		player1AvgTime = player1TotalTime / float64(player1Rounds)
+func (r *SpeedTypeRoom) Submit(playerID int, payload []byte) bool {
+	var msg net.SpeedTypeSubmitMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return false
 	}
+	return r.SubmitWord(playerID, msg.Word, msg.TimeMs)
+}
+
+func (r *SpeedTypeRoom) StateMessage() interface{} {
+	return r.GetState()
+}
 
-	player2AvgTime := 0.0
-	if player2Rounds > 0 {
-		player2AvgTime = player2TotalTime / float64(player2Rounds)
+func (r *SpeedTypeRoom) SpectatorStateMessage() interface{} {
+	msg := r.GetState()
+	if r.State == "playing" {
+		msg.Word = "" // The word is the answer; spectators see it with the results
 	}
+	return msg
+}
 
-	// Determine winner
-	winnerID := 0
-	if r.Players[0] != nil && r.Players[1] != nil {
-		if r.Players[0].Score > r.Players[1].Score {
-			winnerID = r.Players[0].ID
-		} else if r.Players[1].Score > r.Players[0].Score {
-			winnerID = r.Players[1].ID
-		}
+func (r *SpeedTypeRoom) SummaryMessage() interface{} {
+	summary := r.GetGameSummary()
+	if summary == nil {
+		return nil
 	}
 
-	return &GameSummary{
-		Player1ID:      r.Players[0].ID,
-		Player1Name:    r.Players[0].Name,
-		Player1Score:   r.Players[0].Score,
-		Player1AvgTime: player1AvgTime,
-		Player2ID:      r.Players[1].ID,
-		Player2Name:    r.Players[1].Name,
-		Player2Score:   r.Players[1].Score,
-		Player2AvgTime: player2AvgTime,
-		WinnerID:       winnerID,
-		RoundHistory:   r.RoundHistory,
+	msg := &net.GameSummaryMessage{
+		Type:         "gameSummary",
+		Players:      standingMessages(summary.Standings),
+		WinnerID:     summary.WinnerID,
+		RoundHistory: make([]net.RoundHistoryData, len(r.RoundHistory)),
 	}
+	for i, rh := range r.RoundHistory {
+		msg.RoundHistory[i] = net.RoundHistoryData{
+			RoundNumber: rh.RoundNumber,
+			Results:     resultMessages(rh.Results),
+			WinnerID:    rh.WinnerID,
+			Word:        rh.Word,
+			TimedOut:    rh.TimedOut,
+		}
+	}
+	return msg
 }
 
-type GameSummary struct {
-	Player1ID      int
-	Player1Name    string
-	Player1Score   int
-	Player1AvgTime float64
-	Player2ID      int
-	Player2Name    string
-	Player2Score   int
-	Player2AvgTime float64
-	WinnerID       int
-	RoundHistory   []RoundHistory
+func (r *SpeedTypeRoom) SetRatings(ratings map[int]RatingChange) {
+	r.Ratings = ratings
 }
 
+func (r *SpeedTypeRoom) GetGameSummary() *GameSummary {
+	if len(r.RoundHistory) == 0 {
+		return nil
+	}
+
+	standings := make([]Standing, len(r.Players))
+	for i, player := range r.Players {
+		rating := r.Ratings[player.ID]
+		standings[i] = Standing{
+			PlayerID:     player.ID,
+			Name:         player.Name,
+			Score:        player.Score,
+			Rating:       rating.Rating,
+			RatingChange: rating.Change,
+		}
+	}
+	rounds := make([]RoundSummary, len(r.RoundHistory))
+	for i, rh := range r.RoundHistory {
+		rounds[i] = RoundSummary{
+			RoundNumber: rh.RoundNumber,
+			Results:     rh.Results,
+			WinnerID:    rh.WinnerID,
+			TimedOut:    rh.TimedOut,
+			Prompt:      rh.Word,
+		}
+	}
+	return summarize(standings, rounds)
+}
@@ -0,0 +1,139 @@
+// Package logging sets up the server's structured logs: JSON lines through
+// log/slog at a level that can be changed while the server runs. Every line
+// carries the roomCode, roomID, playerID and gameType fields, left empty when
+// they don't apply, so logs can always be filtered on them.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Correlation field names
+const (
+	RoomCodeKey = "roomCode"
+	RoomIDKey   = "roomID"
+	PlayerIDKey = "playerID"
+	GameTypeKey = "gameType"
+)
+
+var level = new(slog.LevelVar)
+
+// Setup makes a JSON logger writing to w the default for both slog and the
+// log package
+func Setup(w io.Writer, lvl slog.Level) {
+	level.Set(lvl)
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(&correlationHandler{Handler: handler}))
+}
+
+// Level returns the lowest level being logged
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the lowest level logged, taking effect straight away
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+// ParseLevel reads a level name such as "debug" or "WARN"
+func ParseLevel(name string) (slog.Level, error) {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(strings.TrimSpace(name)))
+	return lvl, err
+}
+
+// Fatal logs an error and exits, for failures the server can't start with
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// RoomCode, RoomID, PlayerID and GameType make the correlation fields
+func RoomCode(code string) slog.Attr { return slog.String(RoomCodeKey, code) }
+
+func RoomID(id string) slog.Attr { return slog.String(RoomIDKey, id) }
+
+func PlayerID(id int) slog.Attr { return slog.Int(PlayerIDKey, id) }
+
+func GameType(gameType string) slog.Attr { return slog.String(GameTypeKey, gameType) }
+
+// fieldSet marks which correlation fields a log line already has
+type fieldSet uint8
+
+const (
+	hasRoomCode fieldSet = 1 << iota
+	hasRoomID
+	hasPlayerID
+	hasGameType
+
+	hasAll = hasRoomCode | hasRoomID | hasPlayerID | hasGameType
+)
+
+func fieldsIn(attrs ...slog.Attr) fieldSet {
+	var set fieldSet
+	for _, a := range attrs {
+		switch a.Key {
+		case RoomCodeKey:
+			set |= hasRoomCode
+		case RoomIDKey:
+			set |= hasRoomID
+		case PlayerIDKey:
+			set |= hasPlayerID
+		case GameTypeKey:
+			set |= hasGameType
+		}
+	}
+	return set
+}
+
+// missing returns empty values for the correlation fields not in set
+func missing(set fieldSet) []slog.Attr {
+	var attrs []slog.Attr
+	if set&hasRoomCode == 0 {
+		attrs = append(attrs, RoomCode(""))
+	}
+	if set&hasRoomID == 0 {
+		attrs = append(attrs, RoomID(""))
+	}
+	if set&hasPlayerID == 0 {
+		attrs = append(attrs, PlayerID(0))
+	}
+	if set&hasGameType == 0 {
+		attrs = append(attrs, GameType(""))
+	}
+	return attrs
+}
+
+// correlationHandler fills in whichever correlation fields a log line
+// wasn't given, so every line has all of them
+type correlationHandler struct {
+	slog.Handler
+	has fieldSet // Fields already attached with With
+}
+
+func (h *correlationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &correlationHandler{Handler: h.Handler.WithAttrs(attrs), has: h.has | fieldsIn(attrs...)}
+}
+
+func (h *correlationHandler) WithGroup(name string) slog.Handler {
+	// Fields logged inside a group aren't top level, so fill them in first
+	return &correlationHandler{Handler: h.Handler.WithAttrs(missing(h.has)).WithGroup(name), has: hasAll}
+}
+
+func (h *correlationHandler) Handle(ctx context.Context, r slog.Record) error {
+	has := h.has
+	r.Attrs(func(a slog.Attr) bool {
+		has |= fieldsIn(a)
+		return true
+	})
+	if has != hasAll {
+		r = r.Clone()
+		r.AddAttrs(missing(has)...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
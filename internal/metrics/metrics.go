@@ -0,0 +1,269 @@
+// Package metrics keeps counters, gauges and histograms and writes them in
+// the Prometheus text exposition format, so the server can be scraped
+// without pulling in a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds metrics in the order they were registered
+type Registry struct {
+	metrics []metric
+	mu      sync.Mutex
+}
+
+type metric struct {
+	name    string
+	help    string
+	kind    string // counter, gauge or histogram
+	samples func() []sample
+}
+
+type sample struct {
+	suffix string // Added to the metric name, e.g. _bucket
+	labels []label
+	value  float64
+}
+
+type label struct {
+	name, value string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(name, help, kind string, samples func() []sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.metrics {
+		if m.name == name {
+			panic("metrics: " + name + " registered twice")
+		}
+	}
+	r.metrics = append(r.metrics, metric{name: name, help: help, kind: kind, samples: samples})
+}
+
+// Counter is a count that only goes up
+type Counter struct {
+	n atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.n.Add(1)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.n.Load()
+}
+
+// Counter registers a counter without labels
+func (r *Registry) Counter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", func() []sample {
+		return []sample{{value: float64(c.Value())}}
+	})
+	return c
+}
+
+// CounterVec is a set of counters told apart by the value of one label
+type CounterVec struct {
+	counters map[string]*Counter
+	mu       sync.Mutex
+}
+
+// With returns the counter for a label value, creating it the first time
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.counters[value]
+	if !ok {
+		c = &Counter{}
+		v.counters[value] = c
+	}
+	return c
+}
+
+// CounterVec registers counters split by one label. The values given up
+// front are reported from the start, even before they are counted.
+func (r *Registry) CounterVec(name, help, labelName string, values ...string) *CounterVec {
+	v := &CounterVec{counters: make(map[string]*Counter)}
+	for _, value := range values {
+		v.With(value)
+	}
+	r.register(name, help, "counter", func() []sample {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		samples := make([]sample, 0, len(v.counters))
+		for value, c := range v.counters {
+			samples = append(samples, sample{labels: []label{{labelName, value}}, value: float64(c.Value())})
+		}
+		sortSamples(samples)
+		return samples
+	})
+	return v
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	n atomic.Int64
+}
+
+func (g *Gauge) Inc() {
+	g.n.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.n.Add(-1)
+}
+
+func (g *Gauge) Value() int64 {
+	return g.n.Load()
+}
+
+// Gauge registers a gauge without labels
+func (r *Registry) Gauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, help, "gauge", func() []sample {
+		return []sample{{value: float64(g.Value())}}
+	})
+	return g
+}
+
+// GaugeFunc registers a gauge split by one label whose values are read from
+// fn every time the metrics are scraped
+func (r *Registry) GaugeFunc(name, help, labelName string, fn func() map[string]float64) {
+	r.register(name, help, "gauge", func() []sample {
+		values := fn()
+		samples := make([]sample, 0, len(values))
+		for value, n := range values {
+			samples = append(samples, sample{labels: []label{{labelName, value}}, value: n})
+		}
+		sortSamples(samples)
+		return samples
+	})
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	bounds []float64 // Upper bounds, ascending
+	counts []uint64  // One per bound, plus +Inf
+	sum    float64
+	mu     sync.Mutex
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// Histogram registers a histogram with the given bucket upper bounds
+func (r *Registry) Histogram(name, help string, bounds []float64) *Histogram {
+	h := &Histogram{
+		bounds: append([]float64(nil), bounds...),
+		counts: make([]uint64, len(bounds)+1),
+	}
+	sort.Float64s(h.bounds)
+	r.register(name, help, "histogram", func() []sample {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		samples := make([]sample, 0, len(h.counts)+2)
+		var total uint64
+		for i, n := range h.counts {
+			total += n
+			le := math.Inf(1)
+			if i < len(h.bounds) {
+				le = h.bounds[i]
+			}
+			samples = append(samples, sample{suffix: "_bucket", labels: []label{{"le", formatValue(le)}}, value: float64(total)})
+		}
+		samples = append(samples,
+			sample{suffix: "_sum", value: h.sum},
+			sample{suffix: "_count", value: float64(total)},
+		)
+		return samples
+	})
+	return h
+}
+
+// WriteTo writes every metric in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range m.samples() {
+			b.WriteString(m.name + s.suffix)
+			if len(s.labels) > 0 {
+				b.WriteByte('{')
+				for i, l := range s.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", l.name, escapeLabel(l.value))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+func sortSamples(samples []sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].labels[0].value < samples[j].labels[0].value
+	})
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
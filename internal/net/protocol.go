@@ -1,5 +1,7 @@
 package net
 
+import "time"
+
 // Client → Server messages
 
 type HelloMessage struct {
@@ -8,21 +10,9 @@ type HelloMessage struct {
 	Version int    `json:"version"`
 }
 
-type InputMessage struct {
-	Type        string  `json:"type"`
-	Seq         uint32  `json:"seq"`
-	Up          bool    `json:"up"`
-	Down        bool    `json:"down"`
-	Left        bool    `json:"left"`
-	Right       bool    `json:"right"`
-	YawDelta    float32 `json:"yawDelta"`
-	Shoot       bool    `json:"shoot"`
-	ClientTimeMs int64  `json:"clientTimeMs"`
-}
-
 type ReadyMessage struct {
-	Type string `json:"type"`
-	Ready bool  `json:"ready"`
+	Type  string `json:"type"`
+	Ready bool   `json:"ready"`
 }
 
 type SelectGameMessage struct {
@@ -31,14 +21,20 @@ type SelectGameMessage struct {
 }
 
 type SpeedTypeSubmitMessage struct {
-	Type      string  `json:"type"`
-	Word      string  `json:"word"`
-	TimeMs    float64 `json:"timeMs"`
+	Type   string  `json:"type"`
+	Word   string  `json:"word"`
+	TimeMs float64 `json:"timeMs"`
 }
 
-type ReadyForNextRoundMessage struct {
-	Type  string `json:"type"`
-	Ready bool   `json:"ready"`
+type SetRulesMessage struct {
+	Type  string     `json:"type"`
+	Rules MatchRules `json:"rules"`
+}
+
+// SetPlaylistMessage picks a party series: the games are played back to back
+type SetPlaylistMessage struct {
+	Type      string   `json:"type"`
+	GameTypes []string `json:"gameTypes"`
 }
 
 type ReadyForNewGameMessage struct {
@@ -49,16 +45,27 @@ type ReadyForNewGameMessage struct {
 // Server → Client messages
 
 type LobbyPlayer struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Ready bool   `json:"ready"`
+	ID      int            `json:"id"`
+	Name    string         `json:"name"`
+	Ready   bool           `json:"ready"`
+	Ratings map[string]int `json:"ratings,omitempty"` // Skill rating keyed by game type
 }
 
 type LobbyState struct {
 	Players      []LobbyPlayer `json:"players"`
-	State        string        `json:"state"` // "waiting", "ready", "starting"
+	State        string        `json:"state"`                  // "waiting", "ready", "starting"
 	SelectedGame string        `json:"selectedGame,omitempty"` // Game type if selected
 	SelectedBy   *SelectedBy   `json:"selectedBy,omitempty"`   // Who selected the game
+	Rules        *MatchRules   `json:"rules,omitempty"`        // Match rules for the next game
+	Spectators   int           `json:"spectators"`             // Number of spectators watching
+	Tournament   string        `json:"tournament,omitempty"`   // Tournament code if this room code hosts a bracket match
+	Playlist     []string      `json:"playlist,omitempty"`     // Games of a party series, in order
+}
+
+type MatchRules struct {
+	Mode        string `json:"mode"`  // "rounds", "bestOf", "firstTo"
+	Count       int    `json:"count"` // Rounds to play, or points needed for "firstTo"
+	SuddenDeath bool   `json:"suddenDeath"`
 }
 
 type SelectedBy struct {
@@ -67,42 +74,20 @@ type SelectedBy struct {
 }
 
 type WelcomeMessage struct {
-	Type      string     `json:"type"`
-	PlayerID  int        `json:"playerId"`
-	RoomID    string     `json:"roomId"`
-	RoomCode  string     `json:"roomCode,omitempty"`
-	Lobby     *LobbyState `json:"lobby,omitempty"`
-}
-
-type PlayerState struct {
-	ID     int     `json:"id"`
-	X      float32 `json:"x"`
-	Y      float32 `json:"y"`
-	Yaw    float32 `json:"yaw"`
-	Alive  bool    `json:"alive"`
-	Score  int     `json:"score"`
-}
-
-type RoundState struct {
-	State     string `json:"state"` // "waiting", "playing", "ended"
-	WinnerID  int    `json:"winnerId"`
-	ResetInMs int    `json:"resetInMs"`
-}
-
-type Wall struct {
-	X float32 `json:"x"`
-	Y float32 `json:"y"`
-	W float32 `json:"w"`
-	H float32 `json:"h"`
+	Type           string      `json:"type"`
+	PlayerID       int         `json:"playerId"`
+	RoomID         string      `json:"roomId"`
+	RoomCode       string      `json:"roomCode,omitempty"`
+	Lobby          *LobbyState `json:"lobby,omitempty"`
+	Rules          *MatchRules `json:"rules,omitempty"` // Sent when joining a game room
+	Spectator      bool        `json:"spectator,omitempty"`
+	Tournament     string      `json:"tournament,omitempty"`     // Sent when joining a tournament match's game room
+	ReconnectToken string      `json:"reconnectToken,omitempty"` // Sent to a seated player joining a game room
 }
 
 type SnapMessage struct {
-	Type    string        `json:"type"`
-	Tick    uint32        `json:"tick"`
-	Players []PlayerState `json:"players"`
-	Round   RoundState    `json:"round"`
-	Walls   []Wall        `json:"walls"`
-	Lobby   *LobbyState   `json:"lobby,omitempty"`
+	Type  string      `json:"type"`
+	Lobby *LobbyState `json:"lobby,omitempty"`
 }
 
 type GameSelectedMessage struct {
@@ -112,18 +97,79 @@ type GameSelectedMessage struct {
 }
 
 type GameStartMessage struct {
-	Type     string `json:"type"`
-	GameType string `json:"gameType"`
-	RoomID   string `json:"roomId"`
+	Type           string `json:"type"`
+	GameType       string `json:"gameType"`
+	RoomID         string `json:"roomId"`
+	Spectator      bool   `json:"spectator,omitempty"`
+	ReconnectToken string `json:"reconnectToken,omitempty"` // The game page connects with it to take the player's seat
+}
+
+// QueueStatusMessage tells a player waiting for a quick match how the search is going
+type QueueStatusMessage struct {
+	Type        string `json:"type"`
+	PlayerID    int    `json:"playerId"`
+	GameType    string `json:"gameType"`
+	Waiting     int    `json:"waiting"` // Players queued for the game, including this one
+	Rating      int    `json:"rating"`
+	ByRating    bool   `json:"byRating"`
+	RatingRange int    `json:"ratingRange"` // How far from their rating an opponent can be, -1 for anyone
+	WaitedMs    int64  `json:"waitedMs"`
+}
+
+// ServerShutdownMessage warns everyone the server is restarting. Games still
+// running at the deadline are ended.
+type ServerShutdownMessage struct {
+	Type     string    `json:"type"`
+	Deadline time.Time `json:"deadline"`
+	InMs     int64     `json:"inMs"` // Time left until the deadline when sent
+}
+
+type RedirectMessage struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// RematchStatusMessage is sent after a game summary while players decide on a rematch
+type RematchStatusMessage struct {
+	Type        string        `json:"type"`
+	ReadyStatus []ReadyStatus `json:"readyStatus"`
+	ExpiresInMs int64         `json:"expiresInMs"` // Time left before everyone returns to the lobby
+}
+
+// TournamentState is a single-elimination bracket, as broadcast to the
+// players and served over HTTP for the bracket page
+type TournamentState struct {
+	Code      string              `json:"code"`
+	GameType  string              `json:"gameType"`
+	Rules     *MatchRules         `json:"rules"`
+	Host      string              `json:"host"`
+	Rounds    [][]TournamentMatch `json:"rounds"` // First round first, the final last
+	Champion  string              `json:"champion,omitempty"`
+	YourMatch string              `json:"yourMatch,omitempty"` // Room code of the requester's next match (HTTP only)
+}
+
+type TournamentMatch struct {
+	RoomCode string    `json:"roomCode"`
+	Players  [2]string `json:"players"` // "" for a bye or a seat still waiting on an earlier match
+	Winner   string    `json:"winner,omitempty"`
+	State    string    `json:"state"` // "pending", "ready", "playing", "done"
+}
+
+// BracketMessage is sent to everyone in a tournament whenever its bracket changes
+type BracketMessage struct {
+	Type       string           `json:"type"`
+	Tournament *TournamentState `json:"tournament"`
 }
 
 type SpeedTypeStateMessage struct {
 	Type        string           `json:"type"`
 	Word        string           `json:"word"`
-	State       string           `json:"state"` // "waiting", "ready", "playing", "results"
+	State       string           `json:"state"`                // "waiting", "ready", "playing", "results"
+	DeadlineMs  int64            `json:"deadlineMs,omitempty"` // Round deadline (Unix ms), only while playing
+	TimeLeftMs  int64            `json:"timeLeftMs,omitempty"` // Time left before the deadline when sent
 	Scores      []SpeedTypeScore `json:"scores"`
 	RoundResult *SpeedTypeResult `json:"roundResult,omitempty"`
-	ReadyStatus []ReadyStatus    `json:"readyStatus,omitempty"` // Ready status for next round
+	ReadyStatus []ReadyStatus    `json:"readyStatus,omitempty"` // Who has asked for a rematch
 }
 
 type ReadyStatus struct {
@@ -132,38 +178,84 @@ type ReadyStatus struct {
 }
 
 type SpeedTypeScore struct {
-	PlayerID int `json:"playerId"`
-	Name     string `json:"name"`
-	Score    int    `json:"score"`
+	PlayerID int     `json:"playerId"`
+	Name     string  `json:"name"`
+	Score    int     `json:"score"`
 	TimeMs   float64 `json:"timeMs,omitempty"`
 }
 
+// PlayerResult is one player's finish in a round, listed fastest first
+type PlayerResult struct {
+	PlayerID int     `json:"playerId"`
+	TimeMs   float64 `json:"timeMs"` // 0 if the player didn't submit
+	Place    int     `json:"place"`  // 1 is the fastest, shared on a tie; 0 if the player didn't submit
+	Points   int     `json:"points"` // Points scored this round
+}
+
+// PlayerStanding is a player's final position in a game summary
+type PlayerStanding struct {
+	PlayerID  int     `json:"playerId"`
+	Name      string  `json:"name"`
+	Score     int     `json:"score"`
+	AvgTimeMs float64 `json:"avgTimeMs"`
+	Place     int     `json:"place"`
+
+	Rating       int `json:"rating,omitempty"` // Skill rating after the game
+	RatingChange int `json:"ratingChange"`
+}
+
 type SpeedTypeResult struct {
-	WinnerID      int     `json:"winnerId"`
-	Player1TimeMs float64 `json:"player1TimeMs"`
-	Player2TimeMs float64 `json:"player2TimeMs"`
+	WinnerID int            `json:"winnerId"` // 0 if nobody finished alone in first place
+	Results  []PlayerResult `json:"results"`
+	TimedOut bool           `json:"timedOut,omitempty"`
 }
 
 type RoundHistoryData struct {
-	RoundNumber   int     `json:"roundNumber"`
-	Player1TimeMs float64 `json:"player1TimeMs"`
-	Player2TimeMs float64 `json:"player2TimeMs"`
-	WinnerID      int     `json:"winnerId"`
-	Word          string  `json:"word"`
+	RoundNumber int            `json:"roundNumber"`
+	Results     []PlayerResult `json:"results"`
+	WinnerID    int            `json:"winnerId"`
+	Word        string         `json:"word"`
+	TimedOut    bool           `json:"timedOut,omitempty"`
 }
 
 type GameSummaryMessage struct {
-	Type          string            `json:"type"`
-	Player1ID     int               `json:"player1Id"`
-	Player1Name   string            `json:"player1Name"`
-	Player1Score  int               `json:"player1Score"`
-	Player1AvgTime float64          `json:"player1AvgTime"`
-	Player2ID     int               `json:"player2Id"`
-	Player2Name   string            `json:"player2Name"`
-	Player2Score  int               `json:"player2Score"`
-	Player2AvgTime float64          `json:"player2AvgTime"`
-	WinnerID      int               `json:"winnerId"`
-	RoundHistory  []RoundHistoryData `json:"roundHistory"`
+	Type         string             `json:"type"`
+	Players      []PlayerStanding   `json:"players"` // Ordered by place
+	WinnerID     int                `json:"winnerId"`
+	RoundHistory []RoundHistoryData `json:"roundHistory"`
+}
+
+// SeriesStanding is a player's running total in a party series
+type SeriesStanding struct {
+	PlayerID int    `json:"playerId"`
+	Name     string `json:"name"`
+	Points   int    `json:"points"` // One for every player finished ahead of, in each game
+	GamesWon int    `json:"gamesWon"`
+	Place    int    `json:"place"`
+}
+
+// SeriesGame is one finished game of a party series
+type SeriesGame struct {
+	GameType string           `json:"gameType"`
+	WinnerID int              `json:"winnerId"`
+	Players  []PlayerStanding `json:"players"` // Ordered by place
+}
+
+// SeriesStatusMessage tells everyone in a game room where its party series
+// stands; sent on joining and after every game
+type SeriesStatusMessage struct {
+	Type     string           `json:"type"`
+	Playlist []string         `json:"playlist"`
+	Game     int              `json:"game"` // Index into the playlist of the game being played
+	Players  []SeriesStanding `json:"players"`
+}
+
+// SeriesSummaryMessage is sent after the last game of a party series
+type SeriesSummaryMessage struct {
+	Type     string           `json:"type"`
+	Players  []SeriesStanding `json:"players"`  // Ordered by place
+	WinnerID int              `json:"winnerId"` // 0 if the top total is tied
+	Games    []SeriesGame     `json:"games"`
 }
 
 // Math Sprint messages
@@ -175,12 +267,14 @@ type MathSprintSubmitMessage struct {
 }
 
 type MathSprintStateMessage struct {
-	Type        string             `json:"type"`
-	Question    string             `json:"question"`
-	Answer      int                `json:"answer,omitempty"` // Only sent in results
-	State       string             `json:"state"`
-	Scores      []MathSprintScore  `json:"scores"`
-	RoundResult *MathSprintResult  `json:"roundResult,omitempty"`
+	Type        string            `json:"type"`
+	Question    string            `json:"question"`
+	Answer      *int              `json:"answer,omitempty"` // Only sent in results, where 0 is a real answer
+	State       string            `json:"state"`
+	DeadlineMs  int64             `json:"deadlineMs,omitempty"` // Round deadline (Unix ms), only while playing
+	TimeLeftMs  int64             `json:"timeLeftMs,omitempty"` // Time left before the deadline when sent
+	Scores      []MathSprintScore `json:"scores"`
+	RoundResult *MathSprintResult `json:"roundResult,omitempty"`
 }
 
 type MathSprintScore struct {
@@ -191,33 +285,26 @@ type MathSprintScore struct {
 }
 
 type MathSprintResult struct {
-	WinnerID      int     `json:"winnerId"`
-	Player1TimeMs float64 `json:"player1TimeMs"`
-	Player2TimeMs float64 `json:"player2TimeMs"`
-	CorrectAnswer int     `json:"correctAnswer"`
+	WinnerID      int            `json:"winnerId"`
+	Results       []PlayerResult `json:"results"`
+	CorrectAnswer int            `json:"correctAnswer"`
+	TimedOut      bool           `json:"timedOut,omitempty"`
 }
 
 type MathRoundHistoryData struct {
-	RoundNumber   int     `json:"roundNumber"`
-	Player1TimeMs float64 `json:"player1TimeMs"`
-	Player2TimeMs float64 `json:"player2TimeMs"`
-	WinnerID      int     `json:"winnerId"`
-	Question      string  `json:"question"`
-	Answer        int     `json:"answer"`
+	RoundNumber int            `json:"roundNumber"`
+	Results     []PlayerResult `json:"results"`
+	WinnerID    int            `json:"winnerId"`
+	Question    string         `json:"question"`
+	Answer      int            `json:"answer"`
+	TimedOut    bool           `json:"timedOut,omitempty"`
 }
 
 type MathGameSummaryMessage struct {
-	Type           string                 `json:"type"`
-	Player1ID      int                    `json:"player1Id"`
-	Player1Name    string                 `json:"player1Name"`
-	Player1Score   int                    `json:"player1Score"`
-	Player1AvgTime float64                `json:"player1AvgTime"`
-	Player2ID      int                    `json:"player2Id"`
-	Player2Name    string                 `json:"player2Name"`
-	Player2Score   int                    `json:"player2Score"`
-	Player2AvgTime float64                `json:"player2AvgTime"`
-	WinnerID       int                    `json:"winnerId"`
-	RoundHistory   []MathRoundHistoryData `json:"roundHistory"`
+	Type         string                 `json:"type"`
+	Players      []PlayerStanding       `json:"players"` // Ordered by place
+	WinnerID     int                    `json:"winnerId"`
+	RoundHistory []MathRoundHistoryData `json:"roundHistory"`
 }
 
 // Click Speed messages
@@ -228,14 +315,16 @@ type ClickSpeedSubmitMessage struct {
 }
 
 type ClickSpeedStateMessage struct {
-	Type                string             `json:"type"`
-	TargetX             float64            `json:"targetX"`
-	TargetY             float64            `json:"targetY"`
-	Radius              float64            `json:"radius"`
-	State               string             `json:"state"`
-	Scores              []ClickSpeedScore  `json:"scores"`
-	RoundResult         *ClickSpeedResult  `json:"roundResult,omitempty"`
-	TargetAppearDelayMs int                `json:"targetAppearDelayMs,omitempty"` // Server-controlled delay in ms
+	Type                string            `json:"type"`
+	TargetX             float64           `json:"targetX"`
+	TargetY             float64           `json:"targetY"`
+	Radius              float64           `json:"radius"`
+	State               string            `json:"state"`
+	DeadlineMs          int64             `json:"deadlineMs,omitempty"` // Round deadline (Unix ms), only while playing
+	TimeLeftMs          int64             `json:"timeLeftMs,omitempty"` // Time left before the deadline when sent
+	Scores              []ClickSpeedScore `json:"scores"`
+	RoundResult         *ClickSpeedResult `json:"roundResult,omitempty"`
+	TargetAppearDelayMs int               `json:"targetAppearDelayMs,omitempty"` // Server-controlled delay in ms
 }
 
 type ClickSpeedScore struct {
@@ -246,28 +335,121 @@ type ClickSpeedScore struct {
 }
 
 type ClickSpeedResult struct {
-	WinnerID      int     `json:"winnerId"`
-	Player1TimeMs float64 `json:"player1TimeMs"`
-	Player2TimeMs float64 `json:"player2TimeMs"`
+	WinnerID int            `json:"winnerId"`
+	Results  []PlayerResult `json:"results"`
+	TimedOut bool           `json:"timedOut,omitempty"`
 }
 
 type ClickRoundHistoryData struct {
-	RoundNumber   int     `json:"roundNumber"`
-	Player1TimeMs float64 `json:"player1TimeMs"`
-	Player2TimeMs float64 `json:"player2TimeMs"`
-	WinnerID      int     `json:"winnerId"`
+	RoundNumber int            `json:"roundNumber"`
+	Results     []PlayerResult `json:"results"`
+	WinnerID    int            `json:"winnerId"`
+	TimedOut    bool           `json:"timedOut,omitempty"`
 }
 
 type ClickGameSummaryMessage struct {
-	Type           string                  `json:"type"`
-	Player1ID      int                     `json:"player1Id"`
-	Player1Name    string                  `json:"player1Name"`
-	Player1Score   int                     `json:"player1Score"`
-	Player1AvgTime float64                 `json:"player1AvgTime"`
-	Player2ID      int                     `json:"player2Id"`
-	Player2Name    string                  `json:"player2Name"`
-	Player2Score   int                     `json:"player2Score"`
-	Player2AvgTime float64                 `json:"player2AvgTime"`
-	WinnerID       int                     `json:"winnerId"`
-	RoundHistory   []ClickRoundHistoryData `json:"roundHistory"`
+	Type         string                  `json:"type"`
+	Players      []PlayerStanding        `json:"players"` // Ordered by place
+	WinnerID     int                     `json:"winnerId"`
+	RoundHistory []ClickRoundHistoryData `json:"roundHistory"`
+}
+
+// Leaderboard and player stats, served over HTTP from the match history
+
+// TimeStats sums up a player's submission times, in milliseconds, over every
+// round they submitted in
+type TimeStats struct {
+	Rounds int     `json:"rounds"`
+	BestMs float64 `json:"bestMs"`
+	AvgMs  float64 `json:"avgMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P90Ms  float64 `json:"p90Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+}
+
+// GameRecord is a player's record in one game type
+type GameRecord struct {
+	GameType string    `json:"gameType"`
+	Rating   int       `json:"rating"`
+	Games    int       `json:"games"`
+	Wins     int       `json:"wins"`
+	Losses   int       `json:"losses"` // Games somebody else won
+	Draws    int       `json:"draws"`  // Games with a tied top score
+	Times    TimeStats `json:"times"`
+}
+
+type LeaderboardEntry struct {
+	Rank int    `json:"rank"`
+	Name string `json:"name"`
+	GameRecord
+}
+
+type Leaderboard struct {
+	GameType string             `json:"gameType"`
+	Players  []LeaderboardEntry `json:"players"` // Highest rating first
+}
+
+// RecentMatch is one of a player's latest games
+type RecentMatch struct {
+	ID        int       `json:"id"`
+	GameType  string    `json:"gameType"`
+	RoomCode  string    `json:"roomCode"`
+	EndedAt   time.Time `json:"endedAt"`
+	Place     int       `json:"place"`
+	Players   int       `json:"players"`
+	Score     int       `json:"score"`
+	AvgTimeMs float64   `json:"avgTimeMs"`
+	Winner    string    `json:"winner"` // "" on a tie
+}
+
+type PlayerStats struct {
+	Name          string        `json:"name"`
+	Games         []GameRecord  `json:"games"`         // One per game type played
+	RecentMatches []RecentMatch `json:"recentMatches"` // Newest first
+}
+
+// AnnouncementMessage is a message from the server admin shown to everyone
+// connected
+type AnnouncementMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Admin API, served over HTTP to the server admin
+
+type AdminPlayer struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready,omitempty"` // Lobby players only
+	Connected bool   `json:"connected"`
+}
+
+type AdminLobby struct {
+	Code         string        `json:"code"`
+	SelectedGame string        `json:"selectedGame"`
+	Playlist     []string      `json:"playlist,omitempty"`
+	Tournament   string        `json:"tournament,omitempty"`
+	Players      []AdminPlayer `json:"players"`
+	Spectators   int           `json:"spectators"`
+}
+
+type AdminGameRoom struct {
+	ID         string        `json:"id"`
+	RoomCode   string        `json:"roomCode"`
+	GameType   string        `json:"gameType"`
+	Phase      string        `json:"phase"` // "" if the room didn't answer
+	Round      int           `json:"round"`
+	StartedAt  *time.Time    `json:"startedAt,omitempty"` // When round 1 started
+	Tournament string        `json:"tournament,omitempty"`
+	Players    []AdminPlayer `json:"players"`
+	Spectators int           `json:"spectators"`
+	Ended      bool          `json:"ended"`
+	Responding bool          `json:"responding"` // False if the room goroutine didn't answer in time
+}
+
+type AdminRooms struct {
+	Lobbies   []AdminLobby    `json:"lobbies"`   // By room code
+	GameRooms []AdminGameRoom `json:"gameRooms"` // By room ID
+	Queued    int             `json:"queued"`    // Players waiting for a quick match
+	Draining  bool            `json:"draining"`
 }
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"GoServerGames/internal/storage"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Account rules
+const (
+	MinUsernameLength = 2
+	MaxUsernameLength = 20
+	MinPasswordLength = 8
+)
+
+// Password hashing: PBKDF2 with HMAC-SHA256. The iteration count is kept in
+// every hash so it can be raised later without locking anyone out.
+const (
+	passwordIterations = 600000
+	passwordSaltBytes  = 16
+	passwordKeyBytes   = 32
+	passwordScheme     = "pbkdf2-sha256"
+)
+
+// ErrInvalidInviteCode is returned when registering without the invite code
+// the server requires
+var ErrInvalidInviteCode = errors.New("invalid invite code")
+
+// Accounts registers players and checks their passwords
+type Accounts struct {
+	store      storage.AccountStore
+	inviteCode string // Required to register if set
+	dummyHash  string // Checked for unknown usernames so they take as long as known ones
+}
+
+// NewAccounts manages accounts in the given store. If inviteCode isn't
+// empty, only people who know it can register.
+func NewAccounts(store storage.AccountStore, inviteCode string) (*Accounts, error) {
+	dummyHash, err := hashPassword("not a real password")
+	if err != nil {
+		return nil, err
+	}
+	return &Accounts{store: store, inviteCode: inviteCode, dummyHash: dummyHash}, nil
+}
+
+// InviteRequired reports whether registering needs an invite code
+func (a *Accounts) InviteRequired() bool {
+	return a.inviteCode != ""
+}
+
+// Register creates an account after checking the username, password and,
+// if the server requires one, the invite code
+func (a *Accounts) Register(username, password, inviteCode string) (*storage.Account, error) {
+	if a.inviteCode != "" && subtle.ConstantTimeCompare([]byte(inviteCode), []byte(a.inviteCode)) != 1 {
+		return nil, ErrInvalidInviteCode
+	}
+	return a.create(username, password)
+}
+
+// RegisterInvited creates an account for a guest holding an invite link,
+// which stands in for the invite code
+func (a *Accounts) RegisterInvited(username, password string) (*storage.Account, error) {
+	return a.create(username, password)
+}
+
+func (a *Accounts) create(username, password string) (*storage.Account, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if len(password) < MinPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	return a.store.CreateAccount(username, hash)
+}
+
+// Authenticate returns the account for a username and password, or false if
+// either is wrong
+func (a *Accounts) Authenticate(username, password string) (*storage.Account, bool) {
+	account, ok := a.store.AccountByUsername(strings.TrimSpace(username))
+	if !ok {
+		checkPassword(a.dummyHash, password)
+		return nil, false
+	}
+	if !checkPassword(account.PasswordHash, password) {
+		return nil, false
+	}
+	return account, true
+}
+
+// UsernameTaken reports whether an account already has the username
+func (a *Accounts) UsernameTaken(username string) bool {
+	_, ok := a.store.AccountByUsername(strings.TrimSpace(username))
+	return ok
+}
+
+// Account looks up an account by ID
+func (a *Accounts) Account(id int) (*storage.Account, bool) {
+	return a.store.Account(id)
+}
+
+// validateUsername allows letters, digits, spaces and _ - . in a name of
+// MinUsernameLength to MaxUsernameLength characters
+func validateUsername(username string) error {
+	if username != strings.TrimSpace(username) {
+		return fmt.Errorf("username can't start or end with a space")
+	}
+	length := len([]rune(username))
+	if length < MinUsernameLength || length > MaxUsernameLength {
+		return fmt.Errorf("username must be %d to %d characters", MinUsernameLength, MaxUsernameLength)
+	}
+	for _, r := range username {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" _-.", r) {
+			return fmt.Errorf("username can only use letters, digits, spaces and _ - .")
+		}
+	}
+	return nil
+}
+
+// hashPassword returns a salted hash of the password in the form
+// pbkdf2-sha256$<iterations>$<salt>$<key>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	key := pbkdf2SHA256([]byte(password), salt, passwordIterations, passwordKeyBytes)
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// checkPassword reports whether the password matches a hash from hashPassword
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got := pbkdf2SHA256([]byte(password), salt, iterations, len(want))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// pbkdf2SHA256 derives a key as in RFC 8018, section 5.2
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (keyLen + prf.Size() - 1) / prf.Size()
+	key := make([]byte, 0, blocks*prf.Size())
+
+	counter := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"GoServerGames/internal/logging"
+	"GoServerGames/internal/net"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// adminRoomTimeout is how long the admin API waits on a game room's
+// goroutine before deciding it is stuck
+const adminRoomTimeout = 500 * time.Millisecond
+
+// maxAnnouncementLength caps a server announcement, in characters
+const maxAnnouncementLength = 500
+
+// AdminRooms lists the lobbies and game rooms with their players. Game
+// rooms are asked for their state without holding the matchmaking lock, so
+// a stuck room can't hold up the rest of the server.
+func (m *Matchmaking) AdminRooms() net.AdminRooms {
+	m.mu.Lock()
+	rooms := net.AdminRooms{
+		Lobbies:  make([]net.AdminLobby, 0, len(m.lobbies)),
+		Queued:   len(m.queue),
+		Draining: !m.drainDeadline.IsZero(),
+	}
+	for _, lobby := range m.lobbies {
+		info := net.AdminLobby{
+			Code:         lobby.Code,
+			SelectedGame: lobby.SelectedGame,
+			Playlist:     lobby.Playlist,
+			Players:      make([]net.AdminPlayer, 0, len(lobby.Players)),
+			Spectators:   len(lobby.Spectators),
+		}
+		if lobby.Match != nil {
+			info.Tournament = lobby.Match.Tournament.Code
+		}
+		for _, lp := range lobby.Players {
+			info.Players = append(info.Players, net.AdminPlayer{
+				ID:        lp.PlayerID,
+				Name:      lp.Name,
+				Ready:     lp.Ready,
+				Connected: lp.Conn != nil,
+			})
+		}
+		rooms.Lobbies = append(rooms.Lobbies, info)
+	}
+	gameRooms := make([]*GameRoom, 0, len(m.gameRooms))
+	for _, room := range m.gameRooms {
+		gameRooms = append(gameRooms, room)
+	}
+	m.mu.Unlock()
+
+	rooms.GameRooms = make([]net.AdminGameRoom, 0, len(gameRooms))
+	for _, room := range gameRooms {
+		rooms.GameRooms = append(rooms.GameRooms, room.Inspect(adminRoomTimeout))
+	}
+	sort.Slice(rooms.Lobbies, func(i, j int) bool { return rooms.Lobbies[i].Code < rooms.Lobbies[j].Code })
+	sort.Slice(rooms.GameRooms, func(i, j int) bool { return rooms.GameRooms[i].ID < rooms.GameRooms[j].ID })
+	return rooms
+}
+
+// EndGameRoom force-ends a game room, sending its players back to the
+// lobby. A room that doesn't take the command is stuck: it is dropped, and
+// its players disconnected so they start over in a lobby. It reports false
+// if there is no such room or it has already ended.
+func (m *Matchmaking) EndGameRoom(roomID string) bool {
+	m.mu.Lock()
+	room := m.gameRooms[roomID]
+	m.mu.Unlock()
+	if room == nil || room.Ended() {
+		return false
+	}
+	if room.ForceEnd(adminRoomTimeout) {
+		return true
+	}
+	if room.Ended() {
+		return false
+	}
+
+	room.logger.Warn("Admin dropped room - it isn't responding")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.gameRooms, roomID)
+	for _, seat := range room.seats {
+		if conn := m.connections[seat.PlayerID]; conn != nil && conn.currentGameRoom() == room {
+			conn.setGameRoom(nil)
+			conn.shutdown("Game ended by an admin")
+		}
+	}
+	return true
+}
+
+// KickPlayer closes the connection of the player with the given ID, which
+// takes them out of their lobby or game room. Their session is left alone,
+// so other tabs stay logged in. It reports false if nobody has that ID.
+func (m *Matchmaking) KickPlayer(playerID int) (string, bool) {
+	m.mu.Lock()
+	conn := m.connections[playerID]
+	m.mu.Unlock()
+	if conn == nil {
+		return "", false
+	}
+	conn.logger().Info("Admin kicked player", "name", conn.session.PlayerName)
+	conn.shutdown("Removed by an admin")
+	return conn.session.PlayerName, true
+}
+
+// ClearLobby drops a room code's lobby and disconnects everyone in it, so
+// they reconnect to a fresh one. It reports false if there is no lobby.
+func (m *Matchmaking) ClearLobby(roomCode string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lobby := m.lobbies[roomCode]
+	if lobby == nil {
+		return false
+	}
+	slog.Info("Admin cleared lobby", logging.RoomCode(roomCode), "players", len(lobby.Players), "spectators", len(lobby.Spectators))
+	m.removeLobbyUnlocked(roomCode)
+	for _, lp := range lobby.Players {
+		if lp.Conn != nil {
+			lp.Conn.shutdown("Lobby cleared by an admin")
+		}
+	}
+	for _, sc := range lobby.Spectators {
+		sc.shutdown("Lobby cleared by an admin")
+	}
+	return true
+}
+
+// HandleAdmin serves the admin API. Every request needs the admin token in
+// an "Authorization: Bearer" header; with no token set the API is off.
+//
+//	GET    /api/admin/rooms                 lobbies and game rooms with their players
+//	POST   /api/admin/rooms/{roomID}/end    force-end a game room
+//	DELETE /api/admin/lobbies/{code}        clear a room code's lobby
+//	POST   /api/admin/players/{id}/kick     disconnect a player from their lobby or game
+//	POST   /api/admin/announce              {"message": "..."} to everyone connected
+//	GET    /api/admin/loglevel              the lowest level being logged
+//	PUT    /api/admin/loglevel              {"level": "debug"} to change it
+func HandleAdmin(mm *Matchmaking, sessionStore *SessionStore, limiter *Limiter, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Guessing the token is throttled like guessing passwords
+		ipKey := IPKey(limiter.ClientIP(r))
+		if wait, blocked := limiter.Blocked(ipKey); blocked {
+			TooManyRequests(w, wait)
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			slog.Warn("Admin API: bad token", "ip", ipKey)
+			if wait := limiter.Fail(ipKey); wait > 0 {
+				TooManyRequests(w, wait)
+				return
+			}
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin"), "/"), "/")
+		route := r.Method + " " + parts[0]
+		switch {
+		case route == "GET rooms" && len(parts) == 1:
+			writeJSON(w, mm.AdminRooms())
+
+		case route == "POST rooms" && len(parts) == 3 && parts[2] == "end":
+			if !mm.EndGameRoom(parts[1]) {
+				http.Error(w, "No running game room with that ID", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, map[string]interface{}{"success": true})
+
+		case route == "DELETE lobbies" && len(parts) == 2:
+			if !mm.ClearLobby(NormalizeRoomCode(parts[1])) {
+				http.Error(w, "No lobby with that room code", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, map[string]interface{}{"success": true})
+
+		case route == "POST players" && len(parts) == 3 && parts[2] == "kick":
+			playerID, err := strconv.Atoi(parts[1])
+			if err != nil {
+				http.Error(w, "Invalid player ID", http.StatusBadRequest)
+				return
+			}
+			name, ok := mm.KickPlayer(playerID)
+			if !ok {
+				http.Error(w, "No connected player with that ID", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, map[string]interface{}{"success": true, "name": name})
+
+		case route == "POST announce" && len(parts) == 1:
+			var req struct {
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+			message := strings.TrimSpace(req.Message)
+			if message == "" || utf8.RuneCountInString(message) > maxAnnouncementLength {
+				http.Error(w, fmt.Sprintf("Announcements must be 1 to %d characters", maxAnnouncementLength), http.StatusBadRequest)
+				return
+			}
+			sent := sessionStore.Broadcast(net.AnnouncementMessage{Type: "announcement", Message: message})
+			slog.Info("Admin announcement sent", "connections", sent, "message", message)
+			writeJSON(w, map[string]interface{}{"success": true, "sent": sent})
+
+		case route == "GET loglevel" && len(parts) == 1:
+			writeJSON(w, map[string]interface{}{"level": logging.Level().String()})
+
+		case route == "PUT loglevel" && len(parts) == 1:
+			var req struct {
+				Level string `json:"level"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+			level, err := logging.ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, "Level must be debug, info, warn or error", http.StatusBadRequest)
+				return
+			}
+			previous := logging.Level()
+			logging.SetLevel(level)
+			slog.Warn("Admin changed the log level", "from", previous.String(), "to", level.String())
+			writeJSON(w, map[string]interface{}{"success": true, "level": level.String()})
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
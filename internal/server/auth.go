@@ -1,40 +1,99 @@
 package server
 
 import (
+	"GoServerGames/internal/config"
+	"GoServerGames/internal/storage"
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
-	"os"
+	"errors"
+	"net/http"
 	"sync"
 	"time"
 )
 
 const (
-	SessionDuration = 1 * time.Hour
+	sessionTouchInterval = 1 * time.Minute // How often a connection refreshes its session at most
+	sessionForgetAfter   = 24 * time.Hour  // How long an ended session is still reported as expired
+	sessionCloseWait     = 2 * time.Second // How long Close waits for connections to close
+	sessionCleanupEvery  = 5 * time.Minute // How often ended sessions are forgotten
+)
+
+// Errors from GetSession
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired")
 )
 
 type Session struct {
 	ID         string
-	PlayerName string
-	RoomCode   string
+	AccountID  int
+	PlayerName string      // The account's username
+	RoomCode   string      // "" while looking for a quick match
+	QuickMatch *QuickMatch // Set when the player logged in to find a quick match instead of using a room code
 	CreatedAt  time.Time
 }
 
+// sessionEntry tracks a session's activity and the connections using it
+type sessionEntry struct {
+	session    *Session
+	limits     config.Session // How long the session lasts
+	lastActive time.Time
+	ended      time.Time // When the session expired or was logged out; zero while live
+	conns      map[*Connection]struct{}
+}
+
+// expiresAt returns when the session expires unless there is more activity
+func (e *sessionEntry) expiresAt() time.Time {
+	idle := e.lastActive.Add(e.limits.IdleTimeout)
+	if limit := e.session.CreatedAt.Add(e.limits.MaxLifetime); limit.Before(idle) {
+		return limit
+	}
+	return idle
+}
+
+// live reports whether the session can still be used, marking it ended if not
+func (e *sessionEntry) live(now time.Time) bool {
+	if e.ended.IsZero() && now.After(e.expiresAt()) {
+		e.ended = e.expiresAt()
+	}
+	return e.ended.IsZero()
+}
+
 type SessionStore struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
+	sessions map[string]*sessionEntry
+	limits   config.Session
+	stop     chan struct{} // Closed to stop the cleanup loop
+
+	cleanupRunning bool      // Whether the cleanup loop is still going
+	lastCleanup    time.Time // When the cleanup loop last ran, or started
+	mu             sync.Mutex
 }
 
-func NewSessionStore() *SessionStore {
+func NewSessionStore(limits config.Session) *SessionStore {
 	ss := &SessionStore{
-		sessions: make(map[string]*Session),
+		sessions: make(map[string]*sessionEntry),
+		limits:   limits,
+		stop:     make(chan struct{}),
+
+		cleanupRunning: true,
+		lastCleanup:    time.Now(),
 	}
 	// Cleanup expired sessions periodically
 	go ss.cleanupExpired()
 	return ss
 }
 
-func (ss *SessionStore) CreateSession(playerName, roomCode string) (*Session, error) {
+func (ss *SessionStore) CreateSession(account *storage.Account, roomCode string) (*Session, error) {
+	return ss.createSession(account, roomCode, nil)
+}
+
+// CreateQuickMatchSession starts a session for a player with no room code,
+// who joins the quick-match queue when they connect
+func (ss *SessionStore) CreateQuickMatchSession(account *storage.Account, choice QuickMatch) (*Session, error) {
+	return ss.createSession(account, "", &choice)
+}
+
+func (ss *SessionStore) createSession(account *storage.Account, roomCode string, choice *QuickMatch) (*Session, error) {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 
@@ -45,54 +104,230 @@ func (ss *SessionStore) CreateSession(playerName, roomCode string) (*Session, er
 
 	session := &Session{
 		ID:         sessionID,
-		PlayerName: playerName,
+		AccountID:  account.ID,
+		PlayerName: account.Username,
 		RoomCode:   roomCode,
+		QuickMatch: choice,
 		CreatedAt:  time.Now(),
 	}
 
-	ss.sessions[sessionID] = session
+	ss.sessions[sessionID] = &sessionEntry{
+		session:    session,
+		limits:     ss.limits,
+		lastActive: session.CreatedAt,
+		conns:      make(map[*Connection]struct{}),
+	}
 	return session, nil
 }
 
-func (ss *SessionStore) GetSession(sessionID string) (*Session, bool) {
-	ss.mu.RLock()
-	defer ss.mu.RUnlock()
+// GetSession returns a live session, ErrSessionExpired for one that timed
+// out or was logged out, or ErrSessionNotFound for an ID it never handed out
+func (ss *SessionStore) GetSession(sessionID string) (*Session, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 
-	session, exists := ss.sessions[sessionID]
+	entry, exists := ss.sessions[sessionID]
 	if !exists {
-		return nil, false
+		return nil, ErrSessionNotFound
+	}
+	if !entry.live(time.Now()) {
+		return nil, ErrSessionExpired
 	}
+	return entry.session, nil
+}
+
+// Touch records activity on a session, pushing back when it expires. It
+// reports false if the session is no longer live.
+func (ss *SessionStore) Touch(sessionID string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 
-	// Check if expired
-	if time.Since(session.CreatedAt) > SessionDuration {
+	entry, exists := ss.sessions[sessionID]
+	now := time.Now()
+	if !exists || !entry.live(now) {
+		return false
+	}
+	entry.lastActive = now
+	return true
+}
+
+// ExpiresAt returns when a live session expires unless there is more activity
+func (ss *SessionStore) ExpiresAt(sessionID string) (time.Time, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	entry, exists := ss.sessions[sessionID]
+	if !exists || !entry.live(time.Now()) {
+		return time.Time{}, false
+	}
+	return entry.expiresAt(), true
+}
+
+// SetRoomCode moves a session to another room code, e.g. a tournament
+// player heading to their next match. Live connections share the old
+// session, so it is replaced with an updated copy rather than changed.
+func (ss *SessionStore) SetRoomCode(sessionID, roomCode string) (*Session, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	entry, exists := ss.sessions[sessionID]
+	if !exists || !entry.live(time.Now()) {
 		return nil, false
 	}
 
+	moved := *entry.session
+	moved.RoomCode = roomCode
+	entry.session = &moved
+	return &moved, true
+}
+
+// sessionFromRequest returns the live session named by the request's
+// session cookie, counting the request as activity on it
+func sessionFromRequest(r *http.Request, ss *SessionStore) (*Session, bool) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, false
+	}
+	session, err := ss.GetSession(cookie.Value)
+	if err != nil {
+		return nil, false
+	}
+	ss.Touch(session.ID)
 	return session, true
 }
 
+// attach and detach track the connections using a session, so logging out
+// can close them. attach reports false if the session has already ended.
+func (ss *SessionStore) attach(sessionID string, c *Connection) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	entry, exists := ss.sessions[sessionID]
+	if !exists || !entry.live(time.Now()) {
+		return false
+	}
+	entry.conns[c] = struct{}{}
+	entry.lastActive = time.Now()
+	return true
+}
+
+func (ss *SessionStore) detach(sessionID string, c *Connection) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if entry, exists := ss.sessions[sessionID]; exists {
+		delete(entry.conns, c)
+	}
+}
+
+// DeleteSession logs a session out and closes every connection using it.
+// The session is reported as expired afterwards, so pages still holding its
+// cookie aren't mistaken for someone guessing session IDs.
 func (ss *SessionStore) DeleteSession(sessionID string) {
+	ss.EndSession(sessionID, "Logged out")
+}
+
+// EndSession is DeleteSession giving the connections a reason for closing
+func (ss *SessionStore) EndSession(sessionID, reason string) {
+	ss.mu.Lock()
+	entry, exists := ss.sessions[sessionID]
+	var conns []*Connection
+	if exists {
+		if entry.ended.IsZero() {
+			entry.ended = time.Now()
+		}
+		for c := range entry.conns {
+			conns = append(conns, c)
+		}
+		entry.conns = make(map[*Connection]struct{})
+	}
+	ss.mu.Unlock()
+
+	// Closing makes each connection's read loop exit and leave its room
+	for _, c := range conns {
+		c.shutdown(reason)
+	}
+}
+
+// Broadcast sends a message to every connection of every live session and
+// returns how many it went to
+func (ss *SessionStore) Broadcast(v interface{}) int {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
-	delete(ss.sessions, sessionID)
+	sent := 0
+	now := time.Now()
+	for _, entry := range ss.sessions {
+		if !entry.live(now) {
+			continue
+		}
+		for c := range entry.conns {
+			c.SendMessage(v)
+			sent++
+		}
+	}
+	return sent
+}
+
+// Close stops the cleanup loop and closes every connection still open, as
+// the HTTP server doesn't track upgraded WebSockets when it shuts down. It
+// gives the connections a moment to send what they have queued.
+func (ss *SessionStore) Close() {
+	ss.mu.Lock()
+	close(ss.stop)
+	var conns []*Connection
+	for _, entry := range ss.sessions {
+		for c := range entry.conns {
+			conns = append(conns, c)
+		}
+	}
+	ss.mu.Unlock()
+
+	for _, c := range conns {
+		c.shutdown("Server is shutting down")
+	}
+	wait := time.After(sessionCloseWait)
+	for _, c := range conns {
+		select {
+		case <-c.done:
+		case <-wait:
+			return
+		}
+	}
 }
 
 func (ss *SessionStore) cleanupExpired() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(sessionCleanupEvery)
 	defer ticker.Stop()
+	defer func() {
+		ss.mu.Lock()
+		ss.cleanupRunning = false
+		ss.mu.Unlock()
+	}()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-ss.stop:
+			return
+		}
 		ss.mu.Lock()
 		now := time.Now()
-		for id, session := range ss.sessions {
-			if now.Sub(session.CreatedAt) > SessionDuration {
+		for id, entry := range ss.sessions {
+			if !entry.live(now) && now.Sub(entry.ended) > sessionForgetAfter {
 				delete(ss.sessions, id)
 			}
 		}
+		ss.lastCleanup = now
 		ss.mu.Unlock()
 	}
 }
 
+// CleanupStatus reports whether the loop forgetting ended sessions is still
+// running, and when it last ran
+func (ss *SessionStore) CleanupStatus() (bool, time.Time) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.cleanupRunning, ss.lastCleanup
+}
+
 func generateSessionID() (string, error) {
 	bytes := make([]byte, 16)
 	if _, err := rand.Read(bytes); err != nil {
@@ -100,29 +335,3 @@ func generateSessionID() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
-
-func GetPassword() (string, error) {
-	password := os.Getenv("GAME_PASSWORD")
-	if password == "" {
-		return "", fmt.Errorf("GAME_PASSWORD environment variable not set")
-	}
-	return password, nil
-}
-
-func Authenticate(username, password string) (bool, error) {
-	expectedPassword, err := GetPassword()
-	if err != nil {
-		return false, err
-	}
-
-	if password != expectedPassword {
-		return false, nil
-	}
-
-	if username == "" {
-		return false, fmt.Errorf("username cannot be empty")
-	}
-
-	return true, nil
-}
-
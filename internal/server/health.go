@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// readyTickSlack is how far behind its schedule a game room's tick can fall
+// before the server stops reporting ready
+const readyTickSlack = time.Second
+
+// startedAt is roughly when the process started
+var startedAt = time.Now()
+
+// ReadyCheck is one condition /readyz looks at
+type ReadyCheck struct {
+	OK     bool       `json:"ok"`
+	Detail string     `json:"detail"`
+	Last   *time.Time `json:"last,omitempty"` // When the loop being checked last ran, the longest ago for game rooms
+}
+
+// HandleHealth serves /healthz, which answers whenever the process is up
+func HandleHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"status":     "ok",
+			"startedAt":  startedAt,
+			"uptime":     time.Since(startedAt).Round(time.Second).String(),
+			"goroutines": runtime.NumGoroutine(),
+		})
+	}
+}
+
+// HandleReady serves /readyz, which reports whether the server should get
+// new players: every game room is ticking on time, the session cleanup loop
+// is running and the server isn't shutting down. It answers 503 when not.
+func HandleReady(mm *Matchmaking, sessionStore *SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]ReadyCheck{
+			"roomTicks":      mm.tickCheck(),
+			"sessionCleanup": sessionStore.cleanupCheck(),
+			"draining":       mm.drainingCheck(),
+		}
+		ready := true
+		for _, check := range checks {
+			ready = ready && check.OK
+		}
+
+		status := "ready"
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			status = "not ready"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": checks})
+	}
+}
+
+// tickCheck looks at when each running game room last ticked. A room
+// whose goroutine is stuck handling a command stops ticking.
+func (m *Matchmaking) tickCheck() ReadyCheck {
+	m.mu.Lock()
+	rooms := make([]*GameRoom, 0, len(m.gameRooms))
+	for _, room := range m.gameRooms {
+		rooms = append(rooms, room)
+	}
+	m.mu.Unlock()
+
+	var oldest *GameRoom
+	var last time.Time
+	running := 0
+	for _, room := range rooms {
+		if room.Ended() {
+			continue
+		}
+		running++
+		if t := room.LastTick(); oldest == nil || t.Before(last) {
+			oldest, last = room, t
+		}
+	}
+	if oldest == nil {
+		return ReadyCheck{OK: true, Detail: "no game rooms running"}
+	}
+
+	since := time.Since(last)
+	check := ReadyCheck{
+		OK:     since <= oldest.tickInterval()+readyTickSlack,
+		Detail: fmt.Sprintf("game rooms running: %d, longest since a tick %s", running, since.Round(time.Millisecond)),
+		Last:   &last,
+	}
+	if !check.OK {
+		check.Detail = fmt.Sprintf("game room %s (%s) is stuck: last tick %s ago", oldest.ID, oldest.RoomCode, since.Round(time.Millisecond))
+	}
+	return check
+}
+
+func (ss *SessionStore) cleanupCheck() ReadyCheck {
+	running, last := ss.CleanupStatus()
+	since := time.Since(last)
+	check := ReadyCheck{Last: &last}
+	switch {
+	case !running:
+		check.Detail = "the session cleanup loop has stopped"
+	case since > 2*sessionCleanupEvery:
+		check.Detail = fmt.Sprintf("the session cleanup loop is stuck: last ran %s ago", since.Round(time.Second))
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("last ran %s ago", since.Round(time.Second))
+	}
+	return check
+}
+
+func (m *Matchmaking) drainingCheck() ReadyCheck {
+	m.mu.Lock()
+	deadline := m.drainDeadline
+	m.mu.Unlock()
+	if deadline.IsZero() {
+		return ReadyCheck{OK: true, Detail: "not shutting down"}
+	}
+	return ReadyCheck{Detail: fmt.Sprintf("shutting down: running games have until %s", deadline.Format(time.RFC3339))}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"GoServerGames/internal/config"
+	"GoServerGames/internal/game"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// readyz calls /readyz and decodes its answer
+func readyz(t *testing.T, mm *Matchmaking, ss *SessionStore) (int, string, map[string]ReadyCheck) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	HandleReady(mm, ss)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body struct {
+		Status string                `json:"status"`
+		Checks map[string]ReadyCheck `json:"checks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body.Status, body.Checks
+}
+
+func TestReadyzRoomTicks(t *testing.T) {
+	cfg := config.Default()
+	mm := NewMatchmaking(cfg)
+	ss := NewSessionStore(cfg.Session)
+	defer ss.Close()
+
+	g, err := game.New("speedtype", "room-1", "ABCD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := NewGameRoom(g, nil, RoomOptions{Settings: cfg.Game})
+	mm.gameRooms[room.ID] = room
+
+	code, status, checks := readyz(t, mm, ss)
+	if code != http.StatusOK || status != "ready" {
+		t.Fatalf("/readyz with a room that just ticked = %d %q, want 200 ready", code, status)
+	}
+
+	// The room's goroutine is stuck: it hasn't ticked in far longer than its interval
+	room.lastTick.Store(time.Now().Add(-room.tickInterval() - readyTickSlack - time.Second).UnixNano())
+
+	code, status, checks = readyz(t, mm, ss)
+	if code != http.StatusServiceUnavailable || status != "not ready" {
+		t.Errorf("/readyz with a stuck room = %d %q, want 503 not ready", code, status)
+	}
+	if checks["roomTicks"].OK {
+		t.Errorf("roomTicks check passed with a stuck room: %s", checks["roomTicks"].Detail)
+	}
+	if !checks["sessionCleanup"].OK || !checks["draining"].OK {
+		t.Errorf("other checks failed: %+v", checks)
+	}
+
+	// A room that has finished no longer counts
+	close(room.done)
+	if code, _, _ = readyz(t, mm, ss); code != http.StatusOK {
+		t.Errorf("/readyz once the stuck room ended = %d, want 200", code)
+	}
+}
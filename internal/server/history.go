@@ -0,0 +1,72 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/storage"
+	"time"
+)
+
+// finishGame records a finished game in the match history and, for a
+// tournament match, moves the winner on in the bracket
+func (m *Matchmaking) finishGame(room *GameRoom, summary *game.GameSummary, startedAt time.Time) {
+	m.mu.Lock()
+	store := m.store
+	m.mu.Unlock()
+
+	if store != nil {
+		record := matchRecord(room, summary, startedAt, time.Now())
+		if err := store.SaveMatch(record); err != nil {
+			room.logger.Error("Failed to record game", "error", err)
+		} else {
+			room.logger.Info("Recorded game", "matchID", record.ID)
+		}
+	}
+
+	if room.opts.Tournament != "" {
+		m.recordTournamentResult(room.RoomCode, summary)
+	}
+}
+
+// matchRecord converts a game summary to its match history form
+func matchRecord(room *GameRoom, summary *game.GameSummary, startedAt, endedAt time.Time) *storage.MatchRecord {
+	record := &storage.MatchRecord{
+		RoomCode:   room.RoomCode,
+		RoomID:     room.ID,
+		GameType:   room.GameType,
+		Tournament: room.opts.Tournament,
+		StartedAt:  startedAt,
+		EndedAt:    endedAt,
+		Players:    make([]storage.PlayerRecord, len(summary.Standings)),
+		WinnerID:   summary.WinnerID,
+		Rounds:     make([]storage.RoundRecord, len(summary.Rounds)),
+	}
+	for i, s := range summary.Standings {
+		record.Players[i] = storage.PlayerRecord{
+			PlayerID:  s.PlayerID,
+			Name:      s.Name,
+			Score:     s.Score,
+			AvgTimeMs: s.AvgTimeMs,
+			Place:     s.Place,
+		}
+	}
+	for i, round := range summary.Rounds {
+		results := make([]storage.ResultRecord, len(round.Results))
+		for j, p := range round.Results {
+			results[j] = storage.ResultRecord{
+				PlayerID: p.PlayerID,
+				TimeMs:   p.TimeMs,
+				Place:    p.Place,
+				Points:   p.Points,
+			}
+		}
+		record.Rounds[i] = storage.RoundRecord{
+			RoundNumber: round.RoundNumber,
+			WinnerID:    round.WinnerID,
+			TimedOut:    round.TimedOut,
+			Prompt:      round.Prompt,
+			Answer:      round.Answer,
+			Results:     results,
+		}
+	}
+	return record
+}
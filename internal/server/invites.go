@@ -0,0 +1,199 @@
+package server
+
+import (
+	"GoServerGames/internal/logging"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InviteDuration is how long an invite link works
+const InviteDuration = 24 * time.Hour
+
+// Errors from CheckInvite
+var (
+	ErrInvalidInvite = errors.New("invalid invite link")
+	ErrInviteExpired = errors.New("invite link has expired")
+	ErrInviteRevoked = errors.New("invite link has been revoked")
+)
+
+// Invite is what an invite token vouches for: whoever holds it may join the
+// room code's lobby without the server's invite code
+type Invite struct {
+	RoomCode  string    `json:"room"`
+	Host      string    `json:"host"` // Username of the player who sent it
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// Invites issues and checks invite tokens. A token is the invite itself
+// signed with HMAC-SHA256, so the server keeps no list of them; a host
+// revokes their outstanding invites to a room by moving a cutoff, and
+// tokens issued before it stop working.
+type Invites struct {
+	secret  []byte
+	revoked map[string]time.Time // Room code and host -> invites issued before this are revoked
+	mu      sync.Mutex
+}
+
+// NewInvites signs invites with the given secret. With an empty secret a
+// random one is made, and invites stop working when the server restarts.
+func NewInvites(secret string) (*Invites, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate invite secret: %w", err)
+		}
+	}
+	return &Invites{secret: key, revoked: make(map[string]time.Time)}, nil
+}
+
+// Issue returns a token inviting people to a room code on the host's behalf
+func (iv *Invites) Issue(roomCode, host string) (string, *Invite, error) {
+	now := time.Now()
+	invite := &Invite{
+		RoomCode:  roomCode,
+		Host:      host,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(InviteDuration),
+	}
+	payload, err := json.Marshal(invite)
+	if err != nil {
+		return "", nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(iv.sign(encoded)), invite, nil
+}
+
+// CheckInvite returns the invite a token stands for if its signature is
+// good and it hasn't expired or been revoked
+func (iv *Invites) CheckInvite(token string) (*Invite, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidInvite
+	}
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, iv.sign(encoded)) {
+		return nil, ErrInvalidInvite
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidInvite
+	}
+	var invite Invite
+	if err := json.Unmarshal(payload, &invite); err != nil || invite.RoomCode == "" {
+		return nil, ErrInvalidInvite
+	}
+
+	if time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInviteExpired
+	}
+	iv.mu.Lock()
+	cutoff, revoked := iv.revoked[inviteKey(invite.RoomCode, invite.Host)]
+	iv.mu.Unlock()
+	if revoked && !invite.IssuedAt.After(cutoff) {
+		return nil, ErrInviteRevoked
+	}
+	return &invite, nil
+}
+
+// Revoke cancels every invite the host has sent to a room code so far
+func (iv *Invites) Revoke(roomCode, host string) {
+	iv.mu.Lock()
+	defer iv.mu.Unlock()
+
+	now := time.Now()
+	iv.revoked[inviteKey(roomCode, host)] = now
+	// Cutoffs older than any invite that could still be valid aren't needed
+	for key, cutoff := range iv.revoked {
+		if now.Sub(cutoff) > InviteDuration {
+			delete(iv.revoked, key)
+		}
+	}
+}
+
+func (iv *Invites) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, iv.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+func inviteKey(roomCode, host string) string {
+	return roomCode + "\x00" + strings.ToLower(host)
+}
+
+// HandleRoomInvites serves invite links for the logged in player's room:
+//
+//	POST   /api/rooms/{code}/invite  issue an invite link
+//	DELETE /api/rooms/{code}/invite  revoke every invite the player sent to the room
+func HandleRoomInvites(invites *Invites, sessionStore *SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/rooms"), "/"), "/")
+		if len(parts) != 2 || parts[1] != "invite" {
+			http.NotFound(w, r)
+			return
+		}
+		roomCode := NormalizeRoomCode(parts[0])
+
+		session, loggedIn := sessionFromRequest(r, sessionStore)
+		if !loggedIn {
+			http.Error(w, "Not authenticated", http.StatusUnauthorized)
+			return
+		}
+		// Only players in the room can invite people to it
+		if roomCode == "" || session.RoomCode != roomCode {
+			http.Error(w, "You can only invite people to your own room", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			token, invite, err := invites.Issue(roomCode, session.PlayerName)
+			if err != nil {
+				http.Error(w, "Failed to create invite", http.StatusInternalServerError)
+				return
+			}
+			slog.Info("Invite created", logging.RoomCode(roomCode), "name", session.PlayerName)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"token":     token,
+				"path":      "/join/" + token,
+				"roomCode":  invite.RoomCode,
+				"expiresAt": invite.ExpiresAt,
+			})
+
+		case http.MethodDelete:
+			invites.Revoke(roomCode, session.PlayerName)
+			slog.Info("Invites revoked", logging.RoomCode(roomCode), "name", session.PlayerName)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+			})
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// NormalizeRoomCode uppercases a room code and drops anything but letters
+// and digits
+func NormalizeRoomCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedInvite makes a token for an invite as Issue would, so tests can pick
+// its times
+func signedInvite(t *testing.T, iv *Invites, invite Invite) string {
+	t.Helper()
+	payload, err := json.Marshal(invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(iv.sign(encoded))
+}
+
+func newTestInvites(t *testing.T, secret string) *Invites {
+	t.Helper()
+	iv, err := NewInvites(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iv
+}
+
+func TestInviteRoundTrip(t *testing.T) {
+	iv := newTestInvites(t, "secret")
+	token, issued, err := iv.Issue("ABCD", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite, err := iv.CheckInvite(token)
+	if err != nil {
+		t.Fatalf("CheckInvite of a fresh invite: %v", err)
+	}
+	if invite.RoomCode != "ABCD" || invite.Host != "alice" || !invite.ExpiresAt.Equal(issued.ExpiresAt) {
+		t.Errorf("invite = %+v, want %+v", invite, issued)
+	}
+
+	// The same secret checks invites issued before a restart
+	if _, err := newTestInvites(t, "secret").CheckInvite(token); err != nil {
+		t.Errorf("CheckInvite with the same secret: %v", err)
+	}
+}
+
+func TestInviteTampered(t *testing.T) {
+	iv := newTestInvites(t, "secret")
+	token, _, err := iv.Issue("ABCD", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+
+	// Point the invite at another room, keeping the signature
+	payload, _ := base64.RawURLEncoding.DecodeString(encoded)
+	otherRoom := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), "ABCD", "WXYZ", 1)))
+
+	// Flip a bit of the signature
+	sig, _ := base64.RawURLEncoding.DecodeString(signature)
+	sig[0] ^= 1
+	flipped := base64.RawURLEncoding.EncodeToString(sig)
+
+	tests := []struct {
+		name  string
+		iv    *Invites
+		token string
+	}{
+		{"payload changed", iv, otherRoom + "." + signature},
+		{"signature changed", iv, encoded + "." + flipped},
+		{"signature missing", iv, encoded},
+		{"signature not base64", iv, encoded + ".!!"},
+		{"signed with another secret", newTestInvites(t, "other"), token},
+		{"random secret", newTestInvites(t, ""), token},
+		{"signed garbage", iv, signedGarbage(iv)},
+		{"empty", iv, ""},
+	}
+	for _, tt := range tests {
+		if _, err := tt.iv.CheckInvite(tt.token); !errors.Is(err, ErrInvalidInvite) {
+			t.Errorf("%s: CheckInvite = %v, want %v", tt.name, err, ErrInvalidInvite)
+		}
+	}
+}
+
+// signedGarbage is a correctly signed token whose payload isn't an invite
+func signedGarbage(iv *Invites) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(iv.sign(encoded))
+}
+
+func TestInviteExpired(t *testing.T) {
+	iv := newTestInvites(t, "secret")
+	now := time.Now()
+
+	expired := signedInvite(t, iv, Invite{
+		RoomCode:  "ABCD",
+		Host:      "alice",
+		IssuedAt:  now.Add(-InviteDuration - time.Minute),
+		ExpiresAt: now.Add(-time.Minute),
+	})
+	if _, err := iv.CheckInvite(expired); !errors.Is(err, ErrInviteExpired) {
+		t.Errorf("CheckInvite of an expired invite = %v, want %v", err, ErrInviteExpired)
+	}
+
+	almost := signedInvite(t, iv, Invite{
+		RoomCode:  "ABCD",
+		Host:      "alice",
+		IssuedAt:  now.Add(-InviteDuration + time.Minute),
+		ExpiresAt: now.Add(time.Minute),
+	})
+	if _, err := iv.CheckInvite(almost); err != nil {
+		t.Errorf("CheckInvite of an invite a minute from expiring = %v, want nil", err)
+	}
+}
+
+func TestInviteRevoked(t *testing.T) {
+	iv := newTestInvites(t, "secret")
+	before, _, _ := iv.Issue("ABCD", "alice")
+	otherHost, _, _ := iv.Issue("ABCD", "bob")
+	otherRoom, _, _ := iv.Issue("WXYZ", "alice")
+
+	iv.Revoke("ABCD", "Alice") // Usernames are matched case-insensitively
+
+	if _, err := iv.CheckInvite(before); !errors.Is(err, ErrInviteRevoked) {
+		t.Errorf("CheckInvite of a revoked invite = %v, want %v", err, ErrInviteRevoked)
+	}
+	if _, err := iv.CheckInvite(otherHost); err != nil {
+		t.Errorf("another host's invite to the room: %v", err)
+	}
+	if _, err := iv.CheckInvite(otherRoom); err != nil {
+		t.Errorf("the host's invite to another room: %v", err)
+	}
+
+	after := signedInvite(t, iv, Invite{
+		RoomCode:  "ABCD",
+		Host:      "alice",
+		IssuedAt:  time.Now().Add(time.Second),
+		ExpiresAt: time.Now().Add(InviteDuration),
+	})
+	if _, err := iv.CheckInvite(after); err != nil {
+		t.Errorf("an invite issued after revoking: %v", err)
+	}
+}
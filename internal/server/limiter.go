@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Brute-force protection settings
+const (
+	limiterFreeFailures = 5                // Failures allowed before any backoff
+	limiterBaseBackoff  = 1 * time.Second  // Backoff after the first failure past the free ones, doubling after each
+	limiterMaxBackoff   = 15 * time.Minute // Longest lockout
+	limiterForgetAfter  = 30 * time.Minute // Failures are forgotten after this long without a new one
+)
+
+// Limiter throttles repeated failures, such as wrong passwords or made up
+// session cookies, by client IP and by username. After a few free failures
+// each further one blocks the key for twice as long as the last, up to a
+// temporary lockout.
+type Limiter struct {
+	failures map[string]*limiterEntry
+	ipHeader string           // Header a trusted proxy puts the client IP in, "" to use the remote address
+	now      func() time.Time // time.Now, swapped for a fake clock in tests
+	mu       sync.Mutex
+}
+
+type limiterEntry struct {
+	count        int
+	blockedUntil time.Time
+	lastFailure  time.Time
+}
+
+// NewLimiter creates a limiter. Behind a proxy that sets a client IP header,
+// e.g. Fly-Client-IP, pass its name to count failures by the real client
+// rather than the proxy; without a proxy pass "" so nobody can pick their
+// own IP with the header.
+func NewLimiter(ipHeader string) *Limiter {
+	l := &Limiter{
+		failures: make(map[string]*limiterEntry),
+		ipHeader: ipHeader,
+		now:      time.Now,
+	}
+	// Forget old failures periodically
+	go l.cleanupStale()
+	return l
+}
+
+// IPKey and UserKey name what a failure is counted against
+func IPKey(ip string) string { return "ip:" + ip }
+
+func UserKey(username string) string { return "user:" + strings.ToLower(strings.TrimSpace(username)) }
+
+// Blocked returns how long until the longest block on any of the keys ends,
+// or false if none of them are blocked
+func (l *Limiter) Blocked(keys ...string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	for _, key := range keys {
+		if entry := l.failures[key]; entry != nil && entry.blockedUntil.After(now) {
+			if left := entry.blockedUntil.Sub(now); left > wait {
+				wait = left
+			}
+		}
+	}
+	return wait, wait > 0
+}
+
+// Fail counts a failure against each key and returns how long the longest
+// resulting block lasts, 0 while failures are still free
+func (l *Limiter) Fail(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	for _, key := range keys {
+		entry := l.failures[key]
+		if entry == nil || now.Sub(entry.lastFailure) > limiterForgetAfter {
+			entry = &limiterEntry{}
+			l.failures[key] = entry
+		}
+		entry.count++
+		entry.lastFailure = now
+
+		if backoff := limiterBackoff(entry.count); backoff > 0 {
+			entry.blockedUntil = now.Add(backoff)
+			if backoff > wait {
+				wait = backoff
+			}
+		}
+	}
+	return wait
+}
+
+// Succeed clears the failures counted against each key
+func (l *Limiter) Succeed(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// limiterBackoff returns how long a key is blocked after its nth failure
+func limiterBackoff(failures int) time.Duration {
+	past := failures - limiterFreeFailures
+	if past <= 0 {
+		return 0
+	}
+	backoff := float64(limiterBaseBackoff) * math.Pow(2, float64(past-1))
+	if backoff > float64(limiterMaxBackoff) {
+		return limiterMaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+func (l *Limiter) cleanupStale() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.mu.Lock()
+		now := l.now()
+		for key, entry := range l.failures {
+			if now.Sub(entry.lastFailure) > limiterForgetAfter && now.After(entry.blockedUntil) {
+				delete(l.failures, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// TooManyRequests answers a throttled request with 429 and a Retry-After
+// header in whole seconds
+func TooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, fmt.Sprintf("Too many attempts, try again in %d seconds", seconds), http.StatusTooManyRequests)
+}
+
+// ClientIP returns the address a request came from
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.ipHeader != "" {
+		if ip := strings.TrimSpace(r.Header.Get(l.ipHeader)); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
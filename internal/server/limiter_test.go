@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestLimiter returns a limiter on a fake clock, without the cleanup loop
+func newTestLimiter() (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return &Limiter{failures: make(map[string]*limiterEntry), now: clock.Now}, clock
+}
+
+func TestLimiterFreeFailures(t *testing.T) {
+	l, _ := newTestLimiter()
+	key := IPKey("192.0.2.1")
+	for i := 1; i <= limiterFreeFailures; i++ {
+		if wait := l.Fail(key); wait != 0 {
+			t.Fatalf("failure %d blocked for %s, want free", i, wait)
+		}
+		if wait, blocked := l.Blocked(key); blocked {
+			t.Fatalf("blocked for %s after %d failures", wait, i)
+		}
+	}
+	if wait := l.Fail(key); wait != time.Second {
+		t.Errorf("failure %d blocked for %s, want 1s", limiterFreeFailures+1, wait)
+	}
+}
+
+func TestLimiterBackoffDoubles(t *testing.T) {
+	l, clock := newTestLimiter()
+	key := UserKey("alice")
+	for i := 0; i < limiterFreeFailures; i++ {
+		l.Fail(key)
+	}
+
+	want := []time.Duration{
+		1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, 64 * time.Second, 128 * time.Second,
+		256 * time.Second, 512 * time.Second, 15 * time.Minute, 15 * time.Minute,
+	}
+	for i, w := range want {
+		// Each failure comes once the last block has ended
+		wait := l.Fail(key)
+		if wait != w {
+			t.Fatalf("failure %d blocked for %s, want %s", limiterFreeFailures+i+1, wait, w)
+		}
+		if left, blocked := l.Blocked(key); !blocked || left != w {
+			t.Fatalf("Blocked after failure %d = %s, %v, want %s, true", limiterFreeFailures+i+1, left, blocked, w)
+		}
+		clock.Advance(wait)
+		if left, blocked := l.Blocked(key); blocked {
+			t.Fatalf("still blocked for %s once the %s block ended", left, w)
+		}
+	}
+}
+
+func TestLimiterBlockedCountsDown(t *testing.T) {
+	l, clock := newTestLimiter()
+	ip, user := IPKey("192.0.2.1"), UserKey("alice")
+	for i := 0; i < limiterFreeFailures+2; i++ {
+		l.Fail(ip)
+	}
+	for i := 0; i < limiterFreeFailures+1; i++ {
+		l.Fail(user)
+	}
+
+	clock.Advance(400 * time.Millisecond)
+	if wait, blocked := l.Blocked(user); !blocked || wait != 600*time.Millisecond {
+		t.Errorf("Blocked(user) = %s, %v, want 600ms, true", wait, blocked)
+	}
+	// The longest block of the keys wins
+	if wait, blocked := l.Blocked(ip, user); !blocked || wait != 1600*time.Millisecond {
+		t.Errorf("Blocked(ip, user) = %s, %v, want 1.6s, true", wait, blocked)
+	}
+	if wait, blocked := l.Blocked(UserKey("bob")); blocked {
+		t.Errorf("Blocked(bob) = %s, true, want not blocked", wait)
+	}
+
+	l.Succeed(user)
+	if wait, blocked := l.Blocked(user); blocked {
+		t.Errorf("Blocked(user) after Succeed = %s, true, want not blocked", wait)
+	}
+}
+
+func TestLimiterForgetsOldFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		quiet time.Duration
+		want  time.Duration
+	}{
+		{"within the window", limiterForgetAfter, 2 * time.Second},
+		{"past the window", limiterForgetAfter + time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter()
+			key := IPKey("192.0.2.1")
+			for i := 0; i < limiterFreeFailures+1; i++ {
+				l.Fail(key)
+			}
+			clock.Advance(tt.quiet)
+			if wait := l.Fail(key); wait != tt.want {
+				t.Errorf("failure after %s quiet blocked for %s, want %s", tt.quiet, wait, tt.want)
+			}
+		})
+	}
+}
+
+func TestTooManyRequestsRetryAfter(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{0, "1"},
+		{600 * time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{limiterMaxBackoff, "900"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		TooManyRequests(w, tt.wait)
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("TooManyRequests(%s) status = %d, want %d", tt.wait, w.Code, http.StatusTooManyRequests)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.want {
+			t.Errorf("TooManyRequests(%s) Retry-After = %q, want %q", tt.wait, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/net"
+)
+
+// A room code lobby holds as many players as a minigame room can seat,
+// and needs at least MinLobbyPlayers before a game can start
+const (
+	MinLobbyPlayers = game.MinPlayers
+	MaxLobbyPlayers = game.MaxPlayers
+)
+
+type LobbyPlayer struct {
+	PlayerID       int
+	Name           string
+	AccountID      int // A player reconnecting to the lobby gets their seat back by account
+	RoomCode       string
+	SessionID      string // Session of the player's current connection
+	Conn           *Connection
+	Ready          bool
+	Ratings        map[string]int // Skill rating keyed by game type, as of joining
+	ReconnectToken string         // Carried into the next game of a party series, "" for a new one
+}
+
+// Lobby holds the players waiting under one room code along with the
+// game they have picked. Every room code gets its own Lobby so selections
+// and ready states never leak between codes.
+type Lobby struct {
+	Code         string
+	Players      []*LobbyPlayer
+	SelectedGame string           // A registered game type, or "", the first game of a playlist
+	SelectedBy   *net.SelectedBy  // Who selected the game
+	Rules        game.MatchRules  // Rules for the next game started from this lobby
+	Spectators   []*Connection    // Read-only connections that arrived once the lobby was full
+	Match        *TournamentMatch // Set when the room code hosts a tournament match
+	Playlist     []string         // Games of a party series, nil for a single game
+}
+
+// NewLobby creates an empty lobby starting with the given match rules
+func NewLobby(code string, rules game.MatchRules) *Lobby {
+	return &Lobby{
+		Code:    code,
+		Players: make([]*LobbyPlayer, 0, MaxLobbyPlayers),
+		Rules:   rules,
+	}
+}
+
+// Player returns the lobby player with the given ID, or nil
+func (l *Lobby) Player(playerID int) *LobbyPlayer {
+	for _, lp := range l.Players {
+		if lp.PlayerID == playerID {
+			return lp
+		}
+	}
+	return nil
+}
+
+// PlayerByAccount returns the lobby player seated for the given account, or nil
+func (l *Lobby) PlayerByAccount(accountID int) *LobbyPlayer {
+	for _, lp := range l.Players {
+		if lp.AccountID == accountID {
+			return lp
+		}
+	}
+	return nil
+}
+
+// ActivePlayers returns the players that still have a connection
+func (l *Lobby) ActivePlayers() []*LobbyPlayer {
+	var active []*LobbyPlayer
+	for _, lp := range l.Players {
+		if lp.Conn != nil {
+			active = append(active, lp)
+		}
+	}
+	return active
+}
+
+func (l *Lobby) IsFull() bool {
+	return len(l.ActivePlayers()) >= MaxLobbyPlayers
+}
+
+func (l *Lobby) Add(lp *LobbyPlayer) {
+	lp.RoomCode = l.Code
+	l.Players = append(l.Players, lp)
+}
+
+func (l *Lobby) AddSpectator(conn *Connection) {
+	l.Spectators = append(l.Spectators, conn)
+}
+
+// RemoveSpectator drops a spectator connection, reporting whether it was there
+func (l *Lobby) RemoveSpectator(conn *Connection) bool {
+	for i, sc := range l.Spectators {
+		if sc == conn {
+			l.Spectators = append(l.Spectators[:i], l.Spectators[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty reports whether nobody, players or spectators, is left in the lobby
+func (l *Lobby) IsEmpty() bool {
+	return len(l.Players) == 0 && len(l.Spectators) == 0
+}
+
+// Remove takes a player out of the lobby. If that player picked the
+// current game, the selection and everyone's ready status are cleared.
+func (l *Lobby) Remove(playerID int) *LobbyPlayer {
+	for i, lp := range l.Players {
+		if lp.PlayerID != playerID {
+			continue
+		}
+		l.Players = append(l.Players[:i], l.Players[i+1:]...)
+		if l.SelectedBy != nil && l.SelectedBy.PlayerID == playerID {
+			l.ClearSelection()
+		}
+		return lp
+	}
+	return nil
+}
+
+// RemoveInactive drops players whose connection has gone away
+func (l *Lobby) RemoveInactive() []*LobbyPlayer {
+	var removed []*LobbyPlayer
+	for _, lp := range l.Players {
+		if lp.Conn == nil {
+			removed = append(removed, lp)
+		}
+	}
+	for _, lp := range removed {
+		l.Remove(lp.PlayerID)
+	}
+	return removed
+}
+
+// SelectGame records the game picked by a player and resets ready status
+func (l *Lobby) SelectGame(lp *LobbyPlayer, gameType string) {
+	l.SelectedGame = gameType
+	l.Playlist = nil
+	l.SelectedBy = &net.SelectedBy{
+		PlayerID: lp.PlayerID,
+		Name:     lp.Name,
+	}
+	l.resetReady()
+}
+
+// SetPlaylist picks a party series, starting with the playlist's first game
+func (l *Lobby) SetPlaylist(lp *LobbyPlayer, playlist []string) {
+	l.SelectGame(lp, playlist[0])
+	l.Playlist = playlist
+}
+
+// SetMatch sets the lobby up for a tournament match. The game and rules
+// come from the tournament and players can't change them.
+func (l *Lobby) SetMatch(match *TournamentMatch) {
+	l.Match = match
+	l.SelectedGame = match.Tournament.GameType
+	l.Rules = match.Tournament.Rules
+}
+
+// SetRules changes the match rules and resets ready status, since
+// players readied up for the old rules
+func (l *Lobby) SetRules(rules game.MatchRules) {
+	l.Rules = rules
+	l.resetReady()
+}
+
+func (l *Lobby) ClearSelection() {
+	l.SelectedGame = ""
+	l.Playlist = nil
+	l.SelectedBy = nil
+	l.resetReady()
+}
+
+func (l *Lobby) resetReady() {
+	for _, lp := range l.Players {
+		lp.Ready = false
+	}
+}
+
+// AllReady reports whether the lobby has enough players and every player is ready
+func (l *Lobby) AllReady() bool {
+	active := l.ActivePlayers()
+	if len(active) < MinLobbyPlayers {
+		return false
+	}
+	for _, lp := range active {
+		if !lp.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// CanStart reports whether a game has been selected and everyone is ready
+func (l *Lobby) CanStart() bool {
+	return l.SelectedGame != "" && l.AllReady()
+}
+
+// State builds the lobby state message sent to clients
+func (l *Lobby) State() *net.LobbyState {
+	active := l.ActivePlayers()
+	players := make([]net.LobbyPlayer, len(active))
+	for i, lp := range active {
+		players[i] = net.LobbyPlayer{
+			ID:      lp.PlayerID,
+			Name:    lp.Name,
+			Ready:   lp.Ready,
+			Ratings: lp.Ratings,
+		}
+	}
+
+	state := "waiting"
+	if len(players) >= MinLobbyPlayers && l.SelectedGame != "" {
+		if l.AllReady() {
+			state = "starting"
+		} else {
+			state = "ready"
+		}
+	}
+
+	lobbyState := &net.LobbyState{
+		Players:      players,
+		State:        state,
+		SelectedGame: l.SelectedGame,
+		SelectedBy:   l.SelectedBy,
+		Rules:        rulesMessage(l.Rules),
+		Spectators:   len(l.Spectators),
+		Playlist:     l.Playlist,
+	}
+	if l.Match != nil {
+		lobbyState.Tournament = l.Match.Tournament.Code
+	}
+	return lobbyState
+}
+
+// rulesMessage converts match rules to their protocol form
+func rulesMessage(rules game.MatchRules) *net.MatchRules {
+	return &net.MatchRules{
+		Mode:        rules.Mode,
+		Count:       rules.Count,
+		SuddenDeath: rules.SuddenDeath,
+	}
+}
+
+// Broadcast sends a message to every connected player and spectator in the lobby
+func (l *Lobby) Broadcast(v interface{}) {
+	for _, lp := range l.Players {
+		if lp.Conn != nil {
+			lp.Conn.SendMessage(v)
+		}
+	}
+	for _, sc := range l.Spectators {
+		sc.SendMessage(v)
+	}
+}
@@ -1,1203 +1,550 @@
 package server
 
 import (
+	"GoServerGames/internal/config"
 	"GoServerGames/internal/game"
+	"GoServerGames/internal/logging"
 	"GoServerGames/internal/net"
+	"GoServerGames/internal/storage"
+	"crypto/rand"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 )
 
 type Matchmaking struct {
-	lobby           []*LobbyPlayer
-	rooms           map[string]*game.Room
-	speedTypeRooms  map[string]*game.SpeedTypeRoom
-	mathSprintRooms map[string]*game.MathSprintRoom
-	clickSpeedRooms map[string]*game.ClickSpeedRoom
-	connections     map[int]*Connection // Map player ID to active connection
-	nextRoomID      int
-	nextPlayerID    int
-	selectedBy      *net.SelectedBy // Track who selected the game
-	mu              sync.Mutex
+	lobbies           map[string]*Lobby           // Lobbies keyed by room code
+	gameRooms         map[string]*GameRoom        // Minigame rooms keyed by room ID
+	tournaments       map[string]*Tournament      // Keyed by tournament code
+	tournamentMatches map[string]*TournamentMatch // Keyed by match room code
+	connections       map[int]*Connection         // Map player ID to active connection
+	queue             []*queueEntry               // Players waiting for a quick match, longest waiting first
+	nextRoomID        int
+	nextPlayerID      int
+	cfg               *config.Config     // Settings loaded at startup
+	store             storage.MatchStore // Where finished games are recorded, nil to keep none
+	ratings           *Ratings
+	drainDeadline     time.Time      // Set once the server is shutting down
+	saves             sync.WaitGroup // Finished games still being saved
+	stop              chan struct{}  // Closed to stop the matchmaker's loops
+	mu                sync.Mutex
+}
+
+func NewMatchmaking(cfg *config.Config) *Matchmaking {
+	return &Matchmaking{
+		lobbies:           make(map[string]*Lobby),
+		gameRooms:         make(map[string]*GameRoom),
+		tournaments:       make(map[string]*Tournament),
+		tournamentMatches: make(map[string]*TournamentMatch),
+		connections:       make(map[int]*Connection),
+		nextPlayerID:      1,
+		nextRoomID:        1,
+		cfg:               cfg,
+		ratings:           NewRatings(),
+		stop:              make(chan struct{}),
+	}
 }
 
-type LobbyPlayer struct {
-	PlayerID     int
-	Name         string
-	RoomCode     string
-	Conn         *Connection
-	Ready        bool
-	SelectedGame string // "speedtype", "game2", "game3", or ""
+// defaultRules are the match rules a new lobby or tournament starts with
+func (m *Matchmaking) defaultRules() game.MatchRules {
+	return game.DefaultMatchRules(m.cfg.Game.Rounds)
 }
 
-func NewMatchmaking() *Matchmaking {
-	return &Matchmaking{
-		lobby:           make([]*LobbyPlayer, 0),
-		rooms:           make(map[string]*game.Room),
-		speedTypeRooms:  make(map[string]*game.SpeedTypeRoom),
-		mathSprintRooms: make(map[string]*game.MathSprintRoom),
-		clickSpeedRooms: make(map[string]*game.ClickSpeedRoom),
-		connections:     make(map[int]*Connection),
-		nextPlayerID:    1,
-		nextRoomID:      1,
+// SetMatchStore records every game finished from now on in the given store,
+// and rates players from the games already in it
+func (m *Matchmaking) SetMatchStore(store storage.MatchStore) error {
+	records, err := store.Matches(storage.MatchFilter{})
+	if err != nil {
+		return fmt.Errorf("load match history: %w", err)
 	}
-}
+	m.ratings.Replay(records)
 
-// FindPlayerInGameRoom checks if a player with the given name is already in a speed type game room
-// Returns the room and player ID if found, nil and 0 otherwise
-func (m *Matchmaking) FindPlayerInGameRoom(name string) (*game.SpeedTypeRoom, int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
-	for _, room := range m.speedTypeRooms {
-		for _, player := range room.Players {
-			if player != nil && player.Name == name {
-				return room, player.ID
-			}
-		}
-	}
-	return nil, 0
+	m.store = store
+	return nil
 }
 
-func (m *Matchmaking) AddPlayer(name string, roomCode string, conn *Connection) int {
-	log.Printf("AddPlayer ENTRY: name='%s', roomCode='%s'", name, roomCode)
+// AddPlayer seats a connection in its room code's lobby, or in the game
+// running under the code if it brings the reconnect token of a seat there.
+// Anyone else arriving while the game runs watches it.
+func (m *Matchmaking) AddPlayer(name string, roomCode string, token string, conn *Connection) int {
 	m.mu.Lock()
-	defer func() {
-		m.mu.Unlock()
-		log.Printf("AddPlayer EXIT: name='%s', roomCode='%s'", name, roomCode)
-	}()
+	defer m.mu.Unlock()
 
 	// Validate room code is not empty
 	if roomCode == "" {
-		log.Printf("AddPlayer: Rejected player '%s' - empty room code", name)
+		slog.Warn("AddPlayer: Rejected player - empty room code", "name", name)
 		return 0
 	}
 
-	log.Printf("AddPlayer called for '%s' in room '%s'. speedTypeRooms=%d, mathSprintRooms=%d, clickSpeedRooms=%d, lobby=%d",
-		name, roomCode, len(m.speedTypeRooms), len(m.mathSprintRooms), len(m.clickSpeedRooms), len(m.lobby))
-	
+	slog.Debug("AddPlayer", logging.RoomCode(roomCode), "name", name, "gameRooms", len(m.gameRooms), "lobbies", len(m.lobbies))
+
 	// Clean up empty game rooms first
 	m.cleanupEmptyRoomsUnlocked()
 
-	// Check if player is in an active Speed Type game room (reconnection after redirect)
+	// Check if player is in an active game room (reconnection after redirect)
 	// Only reconnect if the room code matches - prevents cross-room contamination
-	log.Printf("AddPlayer: Checking %d speed type rooms for player %s (room code: %s)", len(m.speedTypeRooms), name, roomCode)
-	for roomID, room := range m.speedTypeRooms {
-		log.Printf("AddPlayer: Checking speed type room %s: GameEnded=%v, RoomCode=%s, Players=[%v, %v]", 
-			roomID, room.CheckGameEnd(), room.RoomCode,
-			func() string { if room.Players[0] != nil { return room.Players[0].Name } else { return "nil" } }(),
-			func() string { if room.Players[1] != nil { return room.Players[1].Name } else { return "nil" } }())
-		if room.CheckGameEnd() {
+	var running *GameRoom
+	for roomID, room := range m.gameRooms {
+		if room.Ended() {
 			continue
 		}
-		// Only reconnect if room code matches
 		if room.RoomCode != roomCode {
-			log.Printf("AddPlayer: Skipping room %s - room code mismatch (%s != %s)", roomID, room.RoomCode, roomCode)
+			slog.Debug("AddPlayer: Skipping room - room code mismatch", logging.RoomCode(roomCode), logging.RoomID(roomID), logging.GameType(room.GameType), "roomRoomCode", room.RoomCode)
 			continue
 		}
-		for _, player := range room.Players {
-			if player != nil && player.Name == name {
-				log.Printf("AddPlayer: Found player %s (ID %d) in speed type room %s - reconnecting", name, player.ID, room.ID)
-				conn.playerID = player.ID
-				conn.speedTypeRoom = room
-				m.connections[player.ID] = conn
-				player.Connected = true
-				
-				conn.SendWelcome(player.ID, room.ID, nil)
-				conn.SendMessage(room.GetState())
-				log.Printf("AddPlayer: Successfully reconnected player %s (ID %d) to speed type room %s", name, player.ID, room.ID)
-				return player.ID
-			}
+		running = room
+		if token == "" {
+			break
 		}
-	}
+		if playerID, ok := room.PlayerIDByToken(token, conn.session.ID); ok {
+			slog.Info("Player reconnecting to game room with reconnect token", logging.RoomCode(roomCode), logging.RoomID(roomID), logging.PlayerID(playerID), logging.GameType(room.GameType), "name", name)
+			conn.playerID = playerID
+			conn.setGameRoom(room)
+			m.connections[playerID] = conn
 
-	// Check if player is in an active Math Sprint game room (reconnection after redirect)
-	// Only reconnect if the room code matches - prevents cross-room contamination
-	for roomID, room := range m.mathSprintRooms {
-		log.Printf("  Checking math sprint room %s: GameEnded=%v, RoomCode=%s, Players=[%v, %v]",
-			roomID, room.GameEnded, room.RoomCode,
-			func() string { if room.Players[0] != nil { return room.Players[0].Name } else { return "nil" } }(),
-			func() string { if room.Players[1] != nil { return room.Players[1].Name } else { return "nil" } }())
-		if room.CheckGameEnd() {
-			continue
-		}
-		// Only reconnect if room code matches
-		if room.RoomCode != roomCode {
-			log.Printf("AddPlayer: Skipping math sprint room %s - room code mismatch (%s != %s)", roomID, room.RoomCode, roomCode)
-			continue
-		}
-		for _, player := range room.Players {
-			if player != nil && player.Name == name {
-				log.Printf("Reconnecting player %s (ID %d) to math sprint room %s", name, player.ID, room.ID)
-				conn.playerID = player.ID
-				conn.mathSprintRoom = room
-				m.connections[player.ID] = conn
-				player.Connected = true
-				
-				conn.SendWelcome(player.ID, room.ID, nil)
-				conn.SendMessage(room.GetState())
-				return player.ID
-			}
+			// The room sends the welcome and its current state
+			room.Join(playerID, conn)
+			return playerID
 		}
 	}
 
-	// Check if player is in an active Click Speed game room (reconnection after redirect)
-	// Only reconnect if the room code matches - prevents cross-room contamination
-	for roomID, room := range m.clickSpeedRooms {
-		log.Printf("  Checking click speed room %s: GameEnded=%v, RoomCode=%s, Players=[%v, %v]",
-			roomID, room.GameEnded, room.RoomCode,
-			func() string { if room.Players[0] != nil { return room.Players[0].Name } else { return "nil" } }(),
-			func() string { if room.Players[1] != nil { return room.Players[1].Name } else { return "nil" } }())
-		if room.CheckGameEnd() {
-			continue
-		}
-		// Only reconnect if room code matches
-		if room.RoomCode != roomCode {
-			log.Printf("AddPlayer: Skipping click speed room %s - room code mismatch (%s != %s)", roomID, room.RoomCode, roomCode)
-			continue
-		}
-		for _, player := range room.Players {
-			if player != nil && player.Name == name {
-				log.Printf("Reconnecting player %s (ID %d) to click speed room %s", name, player.ID, room.ID)
-				conn.playerID = player.ID
-				conn.clickSpeedRoom = room
-				m.connections[player.ID] = conn
-				player.Connected = true
-				
-				conn.SendWelcome(player.ID, room.ID, nil)
-				conn.SendMessage(room.GetState())
-				return player.ID
-			}
+	// Anyone else arriving while this room code's game runs watches it
+	if running != nil {
+		if token != "" {
+			slog.Warn("AddPlayer: Reconnect token doesn't match a seat", logging.RoomCode(roomCode), logging.RoomID(running.ID), logging.GameType(running.GameType), "name", name)
 		}
+		return m.addSpectatorUnlocked(conn, nil, running)
 	}
 
-	// Check if player already exists in lobby with same name and room code (reconnection case)
-	// If so, replace their connection and return their existing ID
-	for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode && lp.Name == name {
-			log.Printf("Player %s reconnecting to lobby in room '%s' - replacing connection (old ID: %d)", name, roomCode, lp.PlayerID)
-			// Replace connection
-			oldConn := lp.Conn
-			lp.Conn = conn
-			conn.lobbyPlayer = lp
-			conn.playerID = lp.PlayerID
-			// Clear any game room references
-			conn.speedTypeRoom = nil
-			conn.mathSprintRoom = nil
-			conn.clickSpeedRoom = nil
-			// Update connection map
-			m.connections[lp.PlayerID] = conn
-			// Reset ready status on reconnection
-			lp.Ready = false
-			// Close old connection if it exists and is different
-			if oldConn != nil && oldConn != conn {
-				oldConn.conn.Close()
-			}
-			// Send welcome and lobby state
-			lobbyState := m.GetLobbyStateUnlocked(roomCode)
-			conn.SendWelcome(lp.PlayerID, "", lobbyState)
-			m.broadcastLobbyUpdateUnlocked(roomCode)
-			log.Printf("Reconnected player %d (%s) to room '%s' lobby", lp.PlayerID, name, roomCode)
-			return lp.PlayerID
-		}
+	// No new lobbies while the server shuts down
+	if !m.drainDeadline.IsZero() {
+		slog.Info("AddPlayer: Rejected player - server is shutting down", logging.RoomCode(roomCode), "name", name)
+		return 0
 	}
 
-	// Count ACTIVE players in this room code's lobby (only those with valid connections)
-	playersInRoom := 0
-	for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode {
-			// Check if connection is still valid
-			if _, ok := m.connections[lp.PlayerID]; ok && lp.Conn != nil {
-				playersInRoom++
-			}
+	lobby := m.lobbies[roomCode]
+	if lobby == nil {
+		lobby = NewLobby(roomCode, m.defaultRules())
+		if match := m.tournamentMatches[roomCode]; match != nil {
+			lobby.SetMatch(match)
 		}
+		m.lobbies[roomCode] = lobby
 	}
 
-	// For lobby: Only allow 2 players max per room code
-	if playersInRoom >= 2 {
-		log.Printf("Room '%s' lobby is full (2 active players), rejecting new player: %s", roomCode, name)
-		return 0
+	// Check if the player's account already has a seat in this room code's lobby (reconnection case)
+	// If so, replace their connection and return their existing ID
+	if lp := lobby.PlayerByAccount(conn.session.AccountID); lp != nil {
+		slog.Debug("Player reconnecting to lobby - replacing connection", logging.RoomCode(roomCode), logging.PlayerID(lp.PlayerID), "name", name)
+		// Replace connection
+		oldConn := lp.Conn
+		lp.Conn = conn
+		lp.SessionID = conn.session.ID
+		conn.lobbyPlayer = lp
+		conn.playerID = lp.PlayerID
+		// Clear any game room reference
+		conn.setGameRoom(nil)
+		// Update connection map
+		m.connections[lp.PlayerID] = conn
+		// Reset ready status on reconnection
+		lp.Ready = false
+		// Pick up rating changes from games played since joining
+		lp.Ratings = m.ratings.For(name)
+		// Close old connection if it exists and is different
+		if oldConn != nil && oldConn != conn {
+			oldConn.conn.Close()
+		}
+		// Send welcome and lobby state
+		conn.SendWelcome(lp.PlayerID, "", lobby.State())
+		m.broadcastLobbyUpdateUnlocked(roomCode)
+		slog.Info("Player reconnected to lobby", logging.RoomCode(roomCode), logging.PlayerID(lp.PlayerID), "name", name)
+		return lp.PlayerID
 	}
 
 	// Clean up any stale lobby entries for this room code (players with invalid connections)
-	var cleanedLobby []*LobbyPlayer
-	for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode {
-			// Keep only entries with valid connections
-			if _, ok := m.connections[lp.PlayerID]; ok && lp.Conn != nil {
-				cleanedLobby = append(cleanedLobby, lp)
-			} else {
-				log.Printf("Cleaning up stale lobby entry for player %d (%s) in room '%s'", lp.PlayerID, lp.Name, roomCode)
-			}
-		} else {
-			cleanedLobby = append(cleanedLobby, lp)
+	for _, lp := range m.lobbyPlayers(lobby) {
+		if existing, ok := m.connections[lp.PlayerID]; !ok || existing != lp.Conn {
+			slog.Debug("Cleaning up stale lobby entry", logging.RoomCode(roomCode), logging.PlayerID(lp.PlayerID), "name", lp.Name)
+			lp.Conn = nil
 		}
 	}
-	m.lobby = cleanedLobby
+	lobby.RemoveInactive()
+
+	// For lobby: Only allow MaxLobbyPlayers active players per room code,
+	// later arrivals watch as spectators
+	if lobby.IsFull() {
+		slog.Info("Lobby is full, player joins as a spectator", logging.RoomCode(roomCode), "name", name, "activePlayers", MaxLobbyPlayers)
+		return m.addSpectatorUnlocked(conn, lobby, nil)
+	}
+
+	// Only the two players drawn into a tournament match get a seat
+	if lobby.Match != nil && !lobby.Match.Seats(name) {
+		slog.Info("Player isn't in this tournament match, joining as a spectator", logging.RoomCode(roomCode), "name", name)
+		return m.addSpectatorUnlocked(conn, lobby, nil)
+	}
 
 	// Create new player
 	playerID := m.nextPlayerID
 	m.nextPlayerID++
 
 	lp := &LobbyPlayer{
-		PlayerID: playerID,
-		Name:     name,
-		RoomCode: roomCode,
-		Conn:     conn,
-		Ready:    false,
+		PlayerID:  playerID,
+		Name:      name,
+		AccountID: conn.session.AccountID,
+		RoomCode:  roomCode,
+		SessionID: conn.session.ID,
+		Conn:      conn,
+		Ready:     false,
+		Ratings:   m.ratings.For(name),
 	}
 
-	m.lobby = append(m.lobby, lp)
+	lobby.Add(lp)
 	conn.lobbyPlayer = lp
 	conn.playerID = playerID
-	// Clear any game room references
-	conn.speedTypeRoom = nil
-	conn.mathSprintRoom = nil
-	conn.clickSpeedRoom = nil
+	// Clear any game room reference
+	conn.setGameRoom(nil)
 	m.connections[playerID] = conn
 
-	log.Printf("Added new player %d (%s) to room '%s' lobby (total: %d)", playerID, name, roomCode, len(m.lobby))
+	slog.Info("Player joined lobby", logging.RoomCode(roomCode), logging.PlayerID(playerID), "name", name, "players", len(lobby.Players))
 
-	// Send welcome message with current lobby state (filtered by room code)
-	lobbyState := m.GetLobbyStateUnlocked(roomCode)
-	conn.SendWelcome(playerID, "", lobbyState)
+	// Send welcome message with this room code's lobby state
+	conn.SendWelcome(playerID, "", lobby.State())
 	m.broadcastLobbyUpdateUnlocked(roomCode)
 
 	return playerID
 }
 
-// checkAndResetLobbyAfterGameUnlocked checks if both players from an ended game room are in the lobby
-// and resets their IDs to 1 and 2 if so. Returns the player ID if reset happened, 0 otherwise.
-// This function assumes the lock is already held.
-func (m *Matchmaking) checkAndResetLobbyAfterGameUnlocked(currentPlayerName string, endedGameRoom *game.SpeedTypeRoom) int {
-	// Only check if we have an ended room and exactly 2 players in lobby
-	if endedGameRoom == nil || len(m.lobby) != 2 {
-		return 0
-	}
-	
-	// Count how many players were in the ended room
-	var roomPlayerCount int = 0
-	for _, roomPlayer := range endedGameRoom.Players {
-		if roomPlayer != nil {
-			roomPlayerCount++
-		}
-	}
-	
-	// Only proceed if the ended room had exactly 2 players (a valid game room)
-	if roomPlayerCount != 2 {
-		return 0
-	}
-	
-	// Find both players from the ended room in the lobby
-	var p1FromRoom, p2FromRoom *LobbyPlayer
-	var foundCount int = 0
-	for _, roomPlayer := range endedGameRoom.Players {
-		if roomPlayer != nil {
-			// Find this player in the lobby by name
-			for _, lobbyPlayer := range m.lobby {
-				if lobbyPlayer.Name == roomPlayer.Name {
-					if p1FromRoom == nil {
-						p1FromRoom = lobbyPlayer
-					} else {
-						p2FromRoom = lobbyPlayer
-					}
-					foundCount++
-					break
-				}
-			}
-		}
-	}
-	
-	// Only reset if BOTH players from the ended room are in the lobby
-	if p1FromRoom == nil || p2FromRoom == nil || foundCount != 2 {
-		return 0
-	}
-	
-	log.Printf("RESET: Both players from ended room %s are in lobby - resetting IDs to 1 and 2", endedGameRoom.ID)
-	
-	// Remove old connections from map
-	oldID1 := p1FromRoom.PlayerID
-	oldID2 := p2FromRoom.PlayerID
-	if oldID1 != 1 {
-		delete(m.connections, oldID1)
-	}
-	if oldID2 != 2 {
-		delete(m.connections, oldID2)
-	}
-	
-	// CRITICAL: Verify connections exist before resetting
-	if p1FromRoom.Conn == nil || p2FromRoom.Conn == nil {
-		log.Printf("ERROR: Reset failed - connections are nil!")
-		return 0
-	}
-	
-	// Reassign IDs to 1 and 2
-	p1FromRoom.PlayerID = 1
-	p2FromRoom.PlayerID = 2
-	
-	// Update connection playerIDs
-	p1FromRoom.Conn.playerID = 1
-	p2FromRoom.Conn.playerID = 2
-	
-	// Force sync connections map (lobby is source of truth)
-	m.connections[1] = p1FromRoom.Conn
-	m.connections[2] = p2FromRoom.Conn
-	
-	// Update all connection references
-	p1FromRoom.Conn.lobbyPlayer = p1FromRoom
-	p2FromRoom.Conn.lobbyPlayer = p2FromRoom
-	p1FromRoom.Conn.speedTypeRoom = nil // Clear game room reference
-	p2FromRoom.Conn.speedTypeRoom = nil
-	
-	// Reset the player ID counter
-	m.nextPlayerID = 3
-	
-	// Clear all state completely
-	m.selectedBy = nil
-	p1FromRoom.Ready = false
-	p1FromRoom.SelectedGame = ""
-	p2FromRoom.Ready = false
-	p2FromRoom.SelectedGame = ""
-	
-	// Rebuild lobby with reset IDs
-	m.lobby = []*LobbyPlayer{p1FromRoom, p2FromRoom}
-	
-	log.Printf("RESET COMPLETE: Player 1 (%s) has ID 1, Player 2 (%s) has ID 2", p1FromRoom.Name, p2FromRoom.Name)
-	log.Printf("RESET VERIFIED: P1 Conn=%p (ID=%d), P2 Conn=%p (ID=%d)", 
-		p1FromRoom.Conn, p1FromRoom.Conn.playerID, 
-		p2FromRoom.Conn, p2FromRoom.Conn.playerID)
-	
-	// Delete the ended game room
-	delete(m.speedTypeRooms, endedGameRoom.ID)
-	
-	// Send updated lobby state to both players (using first player's room code)
-	roomCode := p1FromRoom.RoomCode
-	lobbyState := m.GetLobbyStateUnlocked(roomCode)
-	for _, lp := range m.lobby {
-		if lp.Conn != nil && lp.RoomCode == roomCode {
-			lp.Conn.SendWelcome(lp.PlayerID, "", lobbyState)
-			lp.Conn.SendLobbyUpdate(lobbyState)
+// addSpectatorUnlocked attaches a read-only connection to either a full lobby
+// or a running game room. Spectators get an ID for logging and messages but
+// never take a seat. Must be called with lock held.
+func (m *Matchmaking) addSpectatorUnlocked(conn *Connection, lobby *Lobby, room *GameRoom) int {
+	spectatorID := m.nextPlayerID
+	m.nextPlayerID++
+	conn.playerID = spectatorID
+	conn.spectator = true
+
+	if room != nil {
+		slog.Info("Spectator joining game room", logging.RoomCode(room.RoomCode), logging.RoomID(room.ID), logging.PlayerID(spectatorID), logging.GameType(room.GameType), "name", conn.session.PlayerName)
+		conn.setGameRoom(room)
+		// Lets a spectator arriving on the lobby page find the game page
+		conn.SendMessage(net.GameStartMessage{
+			Type:      "gameStart",
+			GameType:  room.GameType,
+			RoomID:    room.ID,
+			Spectator: true,
+		})
+		room.Watch(spectatorID, conn)
+		return spectatorID
+	}
+
+	lobby.AddSpectator(conn)
+	conn.SendSpectatorWelcome(spectatorID, lobby.State())
+	m.broadcastLobbyUpdateUnlocked(lobby.Code)
+	return spectatorID
+}
+
+// lobbyPlayers returns a copy of the lobby's player list that is safe to
+// iterate while the lobby is being modified
+func (m *Matchmaking) lobbyPlayers(lobby *Lobby) []*LobbyPlayer {
+	players := make([]*LobbyPlayer, len(lobby.Players))
+	copy(players, lobby.Players)
+	return players
+}
+
+// findLobbyPlayerUnlocked returns the lobby a player is waiting in, and the player
+// Must be called with lock held
+func (m *Matchmaking) findLobbyPlayerUnlocked(playerID int) (*Lobby, *LobbyPlayer) {
+	for _, lobby := range m.lobbies {
+		if lp := lobby.Player(playerID); lp != nil {
+			return lobby, lp
 		}
 	}
-	
-	// Return the ID assigned to the player that just connected (by name)
-	if p1FromRoom.Name == currentPlayerName {
-		return p1FromRoom.PlayerID
-	} else {
-		return p2FromRoom.PlayerID
-	}
+	return nil, nil
 }
 
 func (m *Matchmaking) SetReady(playerID int, ready bool) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Find player in lobby
-	var player *LobbyPlayer
-	for _, lp := range m.lobby {
-		if lp.PlayerID == playerID {
-			player = lp
-			break
-		}
-	}
-
+	lobby, player := m.findLobbyPlayerUnlocked(playerID)
 	if player == nil {
 		return false
 	}
 
 	player.Ready = ready
-	roomCode := player.RoomCode
-	log.Printf("Player %d (%s) in room '%s' ready status changed to: %v", playerID, player.Name, roomCode, ready)
-	
-	// Broadcast lobby update first
-	m.broadcastLobbyUpdateUnlocked(roomCode)
+	slog.Info("Player ready status changed", logging.RoomCode(lobby.Code), logging.PlayerID(playerID), "name", player.Name, "ready", ready)
 
-	// Check if we can start the game - need 2 players in same room, selected game, and both ready
-	// Count players in this room
-	var playersInRoom []*LobbyPlayer
-		for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode {
-			playersInRoom = append(playersInRoom, lp)
-		}
-	}
-	
-	log.Printf("Checking if game can start: room '%s' has %d players", roomCode, len(playersInRoom))
-	if len(playersInRoom) == 2 {
-		selectedGame := ""
-		for _, lp := range playersInRoom {
-			log.Printf("  Player %d (%s): SelectedGame='%s', Ready=%v", lp.PlayerID, lp.Name, lp.SelectedGame, lp.Ready)
-			if lp.SelectedGame != "" {
-				selectedGame = lp.SelectedGame
-			}
-		}
-		
-		if selectedGame != "" {
-		allReady := true
-			for _, lp := range playersInRoom {
-			if !lp.Ready {
-				allReady = false
-				break
-			}
-		}
+	// Broadcast lobby update first
+	m.broadcastLobbyUpdateUnlocked(lobby.Code)
 
-		if allReady {
-				log.Printf("All players in room '%s' ready! Starting game: %s", roomCode, selectedGame)
-				m.startSelectedGameUnlocked(selectedGame, roomCode)
-			return true
-			} else {
-				log.Printf("Not all players ready. Player 1 (%s) ready: %v, Player 2 (%s) ready: %v", 
-					playersInRoom[0].Name, playersInRoom[0].Ready, playersInRoom[1].Name, playersInRoom[1].Ready)
-		}
-		} else {
-			log.Printf("Game cannot start: No game selected (selectedBy: %v)", m.selectedBy)
-		}
-	} else {
-		log.Printf("Game cannot start: Only %d players in room '%s' (need 2)", len(playersInRoom), roomCode)
+	// Check if we can start the game - need a full lobby, a selected game, and everyone ready
+	if !lobby.CanStart() {
+		slog.Debug("Game cannot start yet", logging.RoomCode(lobby.Code), logging.GameType(lobby.SelectedGame), "players", len(lobby.ActivePlayers()))
+		return false
 	}
 
-	return false
+	slog.Info("All players ready, starting game", logging.RoomCode(lobby.Code), logging.GameType(lobby.SelectedGame))
+	m.startSelectedGameUnlocked(lobby.SelectedGame, lobby.Code)
+	return true
 }
 
 func (m *Matchmaking) SelectGame(playerID int, gameType string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	log.Printf("SelectGame called: playerID=%d, gameType=%s, lobby has %d players", playerID, gameType, len(m.lobby))
-
-	// Find player
-	var player *LobbyPlayer
-	for _, lp := range m.lobby {
-		if lp.PlayerID == playerID {
-			player = lp
-			break
-		}
+	if !game.IsRegistered(gameType) {
+		slog.Warn("SelectGame: Unknown game type", logging.PlayerID(playerID), logging.GameType(gameType))
+		return
 	}
 
+	lobby, player := m.findLobbyPlayerUnlocked(playerID)
 	if player == nil {
-		log.Printf("SelectGame: Player %d not found in lobby!", playerID)
+		slog.Warn("SelectGame: Player not found in any lobby", logging.PlayerID(playerID))
 		return
 	}
 
-	roomCode := player.RoomCode
-	log.Printf("SelectGame: Found player %d (%s) in room '%s', setting game to %s", playerID, player.Name, roomCode, gameType)
-
-	// Set selected game for all players in the same room
-	for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode {
-			lp.SelectedGame = gameType
-			lp.Ready = false // Reset ready status when game changes
-			log.Printf("  Set player %d (%s): SelectedGame='%s', Ready=false", lp.PlayerID, lp.Name, gameType)
-		}
+	if lobby.Match != nil {
+		slog.Warn("SelectGame: Players can't change the game of a tournament match", logging.RoomCode(lobby.Code), logging.PlayerID(playerID))
+		return
 	}
 
-	// Store who selected the game
-	m.selectedBy = &net.SelectedBy{
-		PlayerID: playerID,
-		Name:     player.Name,
-	}
+	slog.Info("Player selected a game", logging.RoomCode(lobby.Code), logging.PlayerID(playerID), logging.GameType(gameType), "name", player.Name)
+
+	// Selection resets ready status for everyone in this room code only
+	lobby.SelectGame(player, gameType)
 
 	// Broadcast game selection to players in the same room
-	for _, lp := range m.lobby {
-		if lp.Conn != nil && lp.RoomCode == roomCode {
-			lp.Conn.SendMessage(net.GameSelectedMessage{
-				Type:     "gameSelected",
-				GameType: gameType,
-				PlayerID: playerID,
-			})
-		}
-	}
+	lobby.Broadcast(net.GameSelectedMessage{
+		Type:     "gameSelected",
+		GameType: gameType,
+		PlayerID: playerID,
+	})
 
 	// Broadcast lobby update with selected game
-	m.broadcastLobbyUpdateUnlocked(roomCode)
-	log.Printf("SelectGame: Game selection complete and broadcasted to room '%s'", roomCode)
+	m.broadcastLobbyUpdateUnlocked(lobby.Code)
 }
 
-func (m *Matchmaking) startSelectedGame(gameType string, roomCode string) {
+// SetRules changes the match rules for the player's lobby
+func (m *Matchmaking) SetRules(playerID int, msg net.MatchRules) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.startSelectedGameUnlocked(gameType, roomCode)
-}
 
-func (m *Matchmaking) startSelectedGameUnlocked(gameType string, roomCode string) {
-	// This function assumes the lock is already held by the caller
-	// Find the 2 players in this room
-	var playersInRoom []*LobbyPlayer
-	for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode {
-			playersInRoom = append(playersInRoom, lp)
-		}
+	rules := game.MatchRules{
+		Mode:        msg.Mode,
+		Count:       msg.Count,
+		SuddenDeath: msg.SuddenDeath,
 	}
-	
-	if len(playersInRoom) != 2 {
-		log.Printf("Cannot start game in room '%s': expected 2 players, got %d", roomCode, len(playersInRoom))
+	if err := rules.Validate(); err != nil {
+		slog.Warn("SetRules: Invalid rules", logging.PlayerID(playerID), "err", err)
 		return
 	}
 
-	p1 := playersInRoom[0]
-	p2 := playersInRoom[1]
-	
-	roomID := m.generateRoomID()
-
-	log.Printf("Starting game %s in room %s with players: %s (%d) vs %s (%d)", 
-		gameType, roomID, p1.Name, p1.PlayerID, p2.Name, p2.PlayerID)
-
-	// Use connections directly from lobby players - these are guaranteed to be current
-	conn1 := p1.Conn
-	conn2 := p2.Conn
-	
-	if conn1 == nil {
-		log.Printf("ERROR: Player 1 (%d, %s) connection is nil!", p1.PlayerID, p1.Name)
+	lobby, player := m.findLobbyPlayerUnlocked(playerID)
+	if player == nil {
+		slog.Warn("SetRules: Player not found in any lobby", logging.PlayerID(playerID))
 		return
 	}
-	if conn2 == nil {
-		log.Printf("ERROR: Player 2 (%d, %s) connection is nil!", p2.PlayerID, p2.Name)
+
+	if lobby.Match != nil {
+		slog.Warn("SetRules: Players can't change the rules of a tournament match", logging.RoomCode(lobby.Code), logging.PlayerID(playerID))
 		return
 	}
 
-	// Update connections map to match (in case of any mismatch)
-	m.connections[p1.PlayerID] = conn1
-	m.connections[p2.PlayerID] = conn2
-
-	log.Printf("Using connections: P1 (%d, %s) conn=%p, P2 (%d, %s) conn=%p", 
-		p1.PlayerID, p1.Name, conn1, p2.PlayerID, p2.Name, conn2)
-
-	switch gameType {
-	case "speedtype":
-		room := game.NewSpeedTypeRoom(roomID, roomCode)
-		room.AddPlayer(p1.PlayerID, p1.Name)
-		room.AddPlayer(p2.PlayerID, p2.Name)
-
-		m.speedTypeRooms[roomID] = room
-		conn1.speedTypeRoom = room
-		conn2.speedTypeRoom = room
-		
-		for _, player := range room.Players {
-			if player != nil {
-				player.Connected = true
-			}
-		}
-
-		gameStartMsg := net.GameStartMessage{
-			Type:     "gameStart",
-			GameType: gameType,
-			RoomID:   roomID,
-		}
-		
-		log.Printf("Sending gameStart to P1 (%d, %s) and P2 (%d, %s)", p1.PlayerID, p1.Name, p2.PlayerID, p2.Name)
-		conn1.SendMessage(gameStartMsg)
-		conn2.SendMessage(gameStartMsg)
-		log.Printf("gameStart messages sent to both players")
-		
-		time.Sleep(200 * time.Millisecond)
-		m.selectedBy = nil
-		m.removePlayersFromLobby(roomCode)
-
-		log.Printf("Starting speed type game for room %s", roomID)
-		go m.startSpeedTypeGame(room, p1, p2)
-
-	case "mathsprint":
-		room := game.NewMathSprintRoom(roomID, roomCode)
-		room.AddPlayer(p1.PlayerID, p1.Name)
-		room.AddPlayer(p2.PlayerID, p2.Name)
-
-		m.mathSprintRooms[roomID] = room
-		conn1.mathSprintRoom = room
-		conn2.mathSprintRoom = room
-		
-		for _, player := range room.Players {
-			if player != nil {
-				player.Connected = true
-			}
-		}
+	slog.Info("Player set the match rules", logging.RoomCode(lobby.Code), logging.PlayerID(playerID), "name", player.Name, "rules", rules.String())
+	lobby.SetRules(rules)
+	m.broadcastLobbyUpdateUnlocked(lobby.Code)
+}
 
-		gameStartMsg := net.GameStartMessage{
-			Type:     "gameStart",
-			GameType: gameType,
-			RoomID:   roomID,
-		}
-		
-		log.Printf("Sending gameStart to P1 (%d, %s) and P2 (%d, %s)", p1.PlayerID, p1.Name, p2.PlayerID, p2.Name)
-		conn1.SendMessage(gameStartMsg)
-		conn2.SendMessage(gameStartMsg)
-		log.Printf("gameStart messages sent to both players")
-		
-		// Broadcast initial ready state so reconnecting players see it
-		m.broadcastMathSprintState(room)
-		
-		time.Sleep(200 * time.Millisecond)
-		m.selectedBy = nil
-		m.removePlayersFromLobby(roomCode)
-
-		log.Printf("Starting math sprint game for room %s", roomID)
-		go m.startMathSprintGame(room, p1, p2)
-
-	case "clickspeed":
-		room := game.NewClickSpeedRoom(roomID, roomCode)
-		room.AddPlayer(p1.PlayerID, p1.Name)
-		room.AddPlayer(p2.PlayerID, p2.Name)
-
-		m.clickSpeedRooms[roomID] = room
-		conn1.clickSpeedRoom = room
-		conn2.clickSpeedRoom = room
-		
-		for _, player := range room.Players {
-			if player != nil {
-				player.Connected = true
-			}
-		}
+// SetPlaylist picks a party series for the player's lobby
+func (m *Matchmaking) SetPlaylist(playerID int, playlist []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		gameStartMsg := net.GameStartMessage{
-			Type:     "gameStart",
-			GameType: gameType,
-			RoomID:   roomID,
-		}
-		
-		log.Printf("Sending gameStart to P1 (%d, %s) and P2 (%d, %s)", p1.PlayerID, p1.Name, p2.PlayerID, p2.Name)
-		conn1.SendMessage(gameStartMsg)
-		conn2.SendMessage(gameStartMsg)
-		log.Printf("gameStart messages sent to both players")
-		
-		time.Sleep(200 * time.Millisecond)
-		m.selectedBy = nil
-		m.removePlayersFromLobby(roomCode)
-
-		log.Printf("Starting click speed game for room %s", roomID)
-		go m.startClickSpeedGame(room, p1, p2)
-
-	default:
-		log.Printf("Unknown game type: %s", gameType)
+	if err := validatePlaylist(playlist); err != nil {
+		slog.Warn("SetPlaylist: Invalid playlist", logging.PlayerID(playerID), "err", err)
+		return
 	}
-}
 
-// removePlayersFromLobby removes all players with the given room code from the lobby
-func (m *Matchmaking) removePlayersFromLobby(roomCode string) {
-	var newLobby []*LobbyPlayer
-	for _, lp := range m.lobby {
-		if lp.RoomCode != roomCode {
-			newLobby = append(newLobby, lp)
-		}
+	lobby, player := m.findLobbyPlayerUnlocked(playerID)
+	if player == nil {
+		slog.Warn("SetPlaylist: Player not found in any lobby", logging.PlayerID(playerID))
+		return
 	}
-	m.lobby = newLobby
-	log.Printf("Removed players from room '%s', lobby now has %d players", roomCode, len(m.lobby))
-}
-
-func (m *Matchmaking) startSpeedTypeGame(room *game.SpeedTypeRoom, p1, p2 *LobbyPlayer) {
-	// Wait for both players to reconnect after redirecting
-	time.Sleep(2 * time.Second)
-
-	log.Printf("Game loop starting for room %s", room.ID)
-
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-
-	maxRounds := 5
-
-	// Start rounds
-	for round := 1; round <= maxRounds; round++ {
-		// Check if room still exists and game hasn't ended
-		m.mu.Lock()
-		if _, exists := m.speedTypeRooms[room.ID]; !exists || room.GameEnded {
-			m.mu.Unlock()
-			log.Printf("Game loop exiting: room %s no longer exists or game ended", room.ID)
-			return
-		}
-		
-		// Check if there are any active connections before starting round
-		activeConns := m.getRoomConnectionsUnlocked(room)
-		if len(activeConns) == 0 {
-			m.mu.Unlock()
-			log.Printf("Game loop exiting: no active connections for room %s", room.ID)
-			return
-		}
-		m.mu.Unlock()
-		
-		room.StartRound()
-		log.Printf("Started round %d/%d for room %s", round, maxRounds, room.ID)
-
-		// Send round state to all connected players
-		m.broadcastSpeedTypeState(room)
-
-		// Wait for both players to submit (state changes to "results")
-		for room.State != "results" {
-			// Check before each broadcast if we should continue
-			m.mu.Lock()
-			if _, exists := m.speedTypeRooms[room.ID]; !exists || room.GameEnded {
-				m.mu.Unlock()
-				log.Printf("Game loop exiting: room %s no longer exists or game ended", room.ID)
-				return
-			}
-			
-			activeConns := m.getRoomConnectionsUnlocked(room)
-			if len(activeConns) == 0 {
-				m.mu.Unlock()
-				log.Printf("Game loop exiting: no active connections for room %s", room.ID)
-				return
-			}
-			m.mu.Unlock()
-			
-			<-ticker.C
-			m.broadcastSpeedTypeState(room)
-		}
-
-		log.Printf("Round %d complete", round)
-		
-		// Broadcast final results state
-		m.broadcastSpeedTypeState(room)
-
-		// If this was the last round, send summary and exit
-		if round >= maxRounds {
-			log.Printf("Game complete after %d rounds", maxRounds)
-			m.sendGameSummary(room)
-			return
-		}
-
-		// Wait before next round
-		time.Sleep(3 * time.Second)
-		room.ResetReadyForNext()
-		room.State = "ready"
+	if lobby.Match != nil {
+		slog.Warn("SetPlaylist: Players can't change the game of a tournament match", logging.RoomCode(lobby.Code), logging.PlayerID(playerID))
+		return
 	}
-	
-	log.Printf("Game loop ended for room %s", room.ID)
+
+	slog.Info("Player picked a party series", logging.RoomCode(lobby.Code), logging.PlayerID(playerID), "name", player.Name, "playlist", playlist)
+	lobby.SetPlaylist(player, playlist)
+	lobby.Broadcast(net.GameSelectedMessage{
+		Type:     "gameSelected",
+		GameType: lobby.SelectedGame,
+		PlayerID: playerID,
+	})
+	m.broadcastLobbyUpdateUnlocked(lobby.Code)
 }
 
-func (m *Matchmaking) sendGameSummary(room *game.SpeedTypeRoom) {
-	summary := room.GetGameSummary()
-	if summary == nil {
-		log.Printf("ERROR: GetGameSummary returned nil!")
+func (m *Matchmaking) startSelectedGameUnlocked(gameType string, roomCode string) {
+	// This function assumes the lock is already held by the caller
+	lobby := m.lobbies[roomCode]
+	if lobby == nil {
+		slog.Warn("Cannot start game: no lobby", logging.RoomCode(roomCode), logging.GameType(gameType))
 		return
 	}
-	
-	summaryMsg := &net.GameSummaryMessage{
-		Type:           "gameSummary",
-		Player1ID:      summary.Player1ID,
-		Player1Name:    summary.Player1Name,
-		Player1Score:   summary.Player1Score,
-		Player1AvgTime: summary.Player1AvgTime,
-		Player2ID:      summary.Player2ID,
-		Player2Name:    summary.Player2Name,
-		Player2Score:   summary.Player2Score,
-		Player2AvgTime: summary.Player2AvgTime,
-		WinnerID:       summary.WinnerID,
-		RoundHistory:   make([]net.RoundHistoryData, len(summary.RoundHistory)),
-	}
-	for i, rh := range summary.RoundHistory {
-		summaryMsg.RoundHistory[i] = net.RoundHistoryData{
-			RoundNumber:   rh.RoundNumber,
-			Player1TimeMs: rh.Player1TimeMs,
-			Player2TimeMs: rh.Player2TimeMs,
-			WinnerID:      rh.WinnerID,
-			Word:          rh.Word,
-		}
-	}
-	
-	m.mu.Lock()
-	conns := m.getRoomConnectionsUnlocked(room)
-	m.mu.Unlock()
-	
-	log.Printf("Sending game summary to %d connections", len(conns))
-	for _, conn := range conns {
-		conn.SendMessage(summaryMsg)
+	if !m.drainDeadline.IsZero() {
+		slog.Info("Cannot start game: server is shutting down", logging.RoomCode(roomCode), logging.GameType(gameType))
+		return
 	}
-	
-	// Mark the room as ended - players will click button to leave
-	room.GameEnded = true
-	log.Printf("Speed type room %s marked as ended", room.ID)
-}
 
-// Math Sprint game functions
-
-func (m *Matchmaking) startMathSprintGame(room *game.MathSprintRoom, p1, p2 *LobbyPlayer) {
-	// Broadcast initial state periodically while waiting for players to reconnect
-	// This ensures reconnecting players get the correct state
-	broadcastTicker := time.NewTicker(200 * time.Millisecond)
-	stopBroadcasting := make(chan bool)
-	go func() {
-		for {
-				select {
-			case <-broadcastTicker.C:
-				m.mu.Lock()
-				if _, exists := m.mathSprintRooms[room.ID]; !exists || room.GameEnded {
-					m.mu.Unlock()
-					stopBroadcasting <- true
-					return
-				}
-				activeConns := m.getMathRoomConnectionsUnlocked(room)
-				if len(activeConns) > 0 {
-					m.broadcastMathSprintStateUnlocked(room)
-				}
-				m.mu.Unlock()
-			case <-stopBroadcasting:
-				return
-			}
-		}
-	}()
-	
-	time.Sleep(2 * time.Second)
-	broadcastTicker.Stop()
-	stopBroadcasting <- true
-	
-	log.Printf("Math sprint game loop starting for room %s", room.ID)
-
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-
-	maxRounds := 5
-	
-	for round := 1; round <= maxRounds; round++ {
-		// Check if room still exists and game hasn't ended
-		m.mu.Lock()
-		if _, exists := m.mathSprintRooms[room.ID]; !exists || room.GameEnded {
-			m.mu.Unlock()
-			log.Printf("Math sprint game loop exiting: room %s no longer exists or game ended", room.ID)
-			return
-		}
-		
-		// Check if there are any active connections before starting round
-		activeConns := m.getMathRoomConnectionsUnlocked(room)
-		if len(activeConns) == 0 {
-			m.mu.Unlock()
-			log.Printf("Math sprint game loop exiting: no active connections for room %s", room.ID)
-			return
-		}
-		m.mu.Unlock()
-		
-		room.StartRound()
-		log.Printf("Math sprint round %d/%d for room %s", round, maxRounds, room.ID)
-
-		m.broadcastMathSprintState(room)
-
-		for room.State != "results" {
-			// Check before each broadcast if we should continue
-			m.mu.Lock()
-			if _, exists := m.mathSprintRooms[room.ID]; !exists || room.GameEnded {
-				m.mu.Unlock()
-				log.Printf("Math sprint game loop exiting: room %s no longer exists or game ended", room.ID)
-				return
-			}
-			
-			activeConns := m.getMathRoomConnectionsUnlocked(room)
-			if len(activeConns) == 0 {
-				m.mu.Unlock()
-				log.Printf("Math sprint game loop exiting: no active connections for room %s", room.ID)
-				return
-			}
-			m.mu.Unlock()
-			
-			<-ticker.C
-			m.broadcastMathSprintState(room)
-		}
-
-		log.Printf("Math sprint round %d complete", round)
-		m.broadcastMathSprintState(room)
-
-		if round >= maxRounds {
-			log.Printf("Math sprint game complete after %d rounds", maxRounds)
-			m.sendMathGameSummary(room)
-			return
-		}
-
-		time.Sleep(3 * time.Second)
-					room.ResetReadyForNext()
-					room.State = "ready"
+	// ActivePlayers only returns players with a connection - these are guaranteed to be current
+	playersInRoom := lobby.ActivePlayers()
+	if len(playersInRoom) < MinLobbyPlayers || len(playersInRoom) > MaxLobbyPlayers {
+		slog.Warn("Cannot start game: wrong number of players", logging.RoomCode(roomCode), logging.GameType(gameType),
+			"players", len(playersInRoom), "min", MinLobbyPlayers, "max", MaxLobbyPlayers)
+		return
 	}
-	
-	log.Printf("Math sprint game loop ended for room %s", room.ID)
-}
 
-func (m *Matchmaking) sendMathGameSummary(room *game.MathSprintRoom) {
-	summary := room.GetGameSummary()
-	if summary == nil {
-		log.Printf("ERROR: GetGameSummary returned nil for math sprint!")
+	roomID := m.generateRoomID()
+	minigame, err := game.New(gameType, roomID, roomCode)
+	if err != nil {
+		slog.Error("Cannot start game", logging.RoomCode(roomCode), logging.GameType(gameType), "err", err)
 		return
 	}
-	
-	summaryMsg := &net.MathGameSummaryMessage{
-		Type:           "mathGameSummary",
-		Player1ID:      summary.Player1ID,
-		Player1Name:    summary.Player1Name,
-		Player1Score:   summary.Player1Score,
-		Player1AvgTime: summary.Player1AvgTime,
-		Player2ID:      summary.Player2ID,
-		Player2Name:    summary.Player2Name,
-		Player2Score:   summary.Player2Score,
-		Player2AvgTime: summary.Player2AvgTime,
-		WinnerID:       summary.WinnerID,
-		RoundHistory:   make([]net.MathRoundHistoryData, len(summary.RoundHistory)),
+
+	names := make([]string, len(playersInRoom))
+	for i, lp := range playersInRoom {
+		names[i] = fmt.Sprintf("%s (%d)", lp.Name, lp.PlayerID)
 	}
-	for i, rh := range summary.RoundHistory {
-		summaryMsg.RoundHistory[i] = net.MathRoundHistoryData{
-			RoundNumber:   rh.RoundNumber,
-			Player1TimeMs: rh.Player1TimeMs,
-			Player2TimeMs: rh.Player2TimeMs,
-			WinnerID:      rh.WinnerID,
-			Question:      rh.Question,
-			Answer:        rh.Answer,
-		}
+	slog.Info("Starting game", logging.RoomCode(roomCode), logging.RoomID(roomID), logging.GameType(gameType), "players", names)
+
+	opts := RoomOptions{
+		Settings: m.cfg.Game,
+		Rules:    lobby.Rules,
+		Ratings:  m.ratings,
+		OnFinish: func(room *GameRoom, summary *game.GameSummary, startedAt time.Time) {
+			// The room goroutine must not wait on the matchmaking lock or the disk
+			m.saves.Add(1)
+			go func() {
+				defer m.saves.Done()
+				m.finishGame(room, summary, startedAt)
+			}()
+		},
 	}
-	
-	m.mu.Lock()
-	conns := m.getMathRoomConnectionsUnlocked(room)
-	m.mu.Unlock()
-	
-	log.Printf("Sending math game summary to %d connections", len(conns))
-	for _, conn := range conns {
-		conn.SendMessage(summaryMsg)
+	if match := lobby.Match; match != nil {
+		opts.Tournament = match.Tournament.Code
+		match.RoomID = roomID
 	}
-	
-	room.GameEnded = true
-	log.Printf("Math sprint room %s marked as ended", room.ID)
-}
-
-func (m *Matchmaking) broadcastMathSprintState(room *game.MathSprintRoom) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.broadcastMathSprintStateUnlocked(room)
-}
-
-func (m *Matchmaking) broadcastMathSprintStateUnlocked(room *game.MathSprintRoom) {
-	state := room.GetState()
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil {
-				if conn.mathSprintRoom == room {
-					conn.SendMessage(state)
-				}
-			}
-		}
+	if len(lobby.Playlist) > 0 {
+		opts.Series = newSeries(lobby.Playlist, playersInRoom)
 	}
-}
+	room := NewGameRoom(minigame, playersInRoom, opts)
+	m.gameRooms[roomID] = room
 
-func (m *Matchmaking) getMathRoomConnectionsUnlocked(room *game.MathSprintRoom) []*Connection {
-	var conns []*Connection
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil && conn.mathSprintRoom == room {
-				conns = append(conns, conn)
-			}
-		}
+	gameStartMsg := net.GameStartMessage{
+		Type:     "gameStart",
+		GameType: gameType,
+		RoomID:   roomID,
+	}
+	for _, lp := range playersInRoom {
+		// Update connections map to match (in case of any mismatch)
+		m.connections[lp.PlayerID] = lp.Conn
+		lp.Conn.setGameRoom(room)
+		// Each player gets the token their game page takes their seat with
+		playerMsg := gameStartMsg
+		playerMsg.ReconnectToken = room.reconnectToken(lp.PlayerID)
+		lp.Conn.SendMessage(playerMsg)
 	}
-	return conns
-}
 
-// Click Speed game functions
-
-func (m *Matchmaking) startClickSpeedGame(room *game.ClickSpeedRoom, p1, p2 *LobbyPlayer) {
-	time.Sleep(2 * time.Second)
-	
-	log.Printf("Click speed game loop starting for room %s", room.ID)
-
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-
-	maxRounds := 5
-	
-	for round := 1; round <= maxRounds; round++ {
-		// Check if room still exists and game hasn't ended
-		m.mu.Lock()
-		if _, exists := m.clickSpeedRooms[room.ID]; !exists || room.GameEnded {
-			m.mu.Unlock()
-			log.Printf("Click speed game loop exiting: room %s no longer exists or game ended", room.ID)
-			return
-		}
-		
-		// Check if there are any active connections before starting round
-		activeConns := m.getClickRoomConnectionsUnlocked(room)
-		if len(activeConns) == 0 {
-			m.mu.Unlock()
-			log.Printf("Click speed game loop exiting: no active connections for room %s", room.ID)
-			return
-		}
-		m.mu.Unlock()
-		
-		room.StartRound()
-		log.Printf("Click speed round %d/%d for room %s", round, maxRounds, room.ID)
-
-		m.broadcastClickSpeedState(room)
-
-		for room.State != "results" {
-			// Check before each broadcast if we should continue
-			m.mu.Lock()
-			if _, exists := m.clickSpeedRooms[room.ID]; !exists || room.GameEnded {
-				m.mu.Unlock()
-				log.Printf("Click speed game loop exiting: room %s no longer exists or game ended", room.ID)
-				return
-			}
-			
-			activeConns := m.getClickRoomConnectionsUnlocked(room)
-			if len(activeConns) == 0 {
-				m.mu.Unlock()
-				log.Printf("Click speed game loop exiting: no active connections for room %s", room.ID)
-				return
-			}
-			m.mu.Unlock()
-			
-			<-ticker.C
-			m.broadcastClickSpeedState(room)
-		}
+	// Spectators follow along to the game page and reconnect there
+	gameStartMsg.Spectator = true
+	for _, sc := range lobby.Spectators {
+		sc.SendMessage(gameStartMsg)
+	}
 
-		log.Printf("Click speed round %d complete", round)
-		m.broadcastClickSpeedState(room)
+	m.removeLobbyUnlocked(roomCode)
 
-		if round >= maxRounds {
-			log.Printf("Click speed game complete after %d rounds", maxRounds)
-			m.sendClickGameSummary(room)
-			return
-		}
+	go room.run()
+	if opts.Series != nil {
+		go m.continueSeries(room)
+	}
 
-		time.Sleep(3 * time.Second)
-			room.ResetReadyForNext()
-			room.State = "ready"
+	if lobby.Match != nil {
+		m.broadcastBracketUnlocked(lobby.Match.Tournament)
 	}
-	
-	log.Printf("Click speed game loop ended for room %s", room.ID)
 }
 
-func (m *Matchmaking) sendClickGameSummary(room *game.ClickSpeedRoom) {
-	summary := room.GetGameSummary()
-	if summary == nil {
-		log.Printf("ERROR: GetGameSummary returned nil for click speed!")
+// continueSeries waits for a party series game to finish and, if the room
+// handed the series on, starts the next game for the same players and sends
+// them straight to it
+func (m *Matchmaking) continueSeries(prev *GameRoom) {
+	<-prev.done
+
+	series := prev.opts.Series
+	gameType, ok := series.takeNext()
+	if !ok {
 		return
 	}
-	
-	summaryMsg := &net.ClickGameSummaryMessage{
-		Type:           "clickGameSummary",
-		Player1ID:      summary.Player1ID,
-		Player1Name:    summary.Player1Name,
-		Player1Score:   summary.Player1Score,
-		Player1AvgTime: summary.Player1AvgTime,
-		Player2ID:      summary.Player2ID,
-		Player2Name:    summary.Player2Name,
-		Player2Score:   summary.Player2Score,
-		Player2AvgTime: summary.Player2AvgTime,
-		WinnerID:       summary.WinnerID,
-		RoundHistory:   make([]net.ClickRoundHistoryData, len(summary.RoundHistory)),
-	}
-	for i, rh := range summary.RoundHistory {
-		summaryMsg.RoundHistory[i] = net.ClickRoundHistoryData{
-			RoundNumber:   rh.RoundNumber,
-			Player1TimeMs: rh.Player1TimeMs,
-			Player2TimeMs: rh.Player2TimeMs,
-			WinnerID:      rh.WinnerID,
-		}
-	}
-	
-	m.mu.Lock()
-	conns := m.getClickRoomConnectionsUnlocked(room)
-	m.mu.Unlock()
-	
-	log.Printf("Sending click game summary to %d connections", len(conns))
-	for _, conn := range conns {
-		conn.SendMessage(summaryMsg)
-	}
-	
-	room.GameEnded = true
-	log.Printf("Click speed room %s marked as ended", room.ID)
-}
 
-func (m *Matchmaking) broadcastClickSpeedState(room *game.ClickSpeedRoom) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	state := room.GetState()
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil {
-				if conn.clickSpeedRoom == room {
-					conn.SendMessage(state)
-				}
-			}
-		}
+	if !m.drainDeadline.IsZero() {
+		slog.Info("Not continuing party series: server is shutting down", logging.RoomCode(prev.RoomCode), logging.RoomID(prev.ID))
+		return
 	}
-}
 
-func (m *Matchmaking) getClickRoomConnectionsUnlocked(room *game.ClickSpeedRoom) []*Connection {
-	var conns []*Connection
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil && conn.clickSpeedRoom == room {
-				conns = append(conns, conn)
-			}
-		}
+	roomID := m.generateRoomID()
+	minigame, err := game.New(gameType, roomID, prev.RoomCode)
+	if err != nil {
+		slog.Error("Cannot continue party series", logging.RoomCode(prev.RoomCode), logging.RoomID(prev.ID), logging.GameType(gameType), "err", err)
+		return
 	}
-	return conns
-}
 
-func (m *Matchmaking) restartSpeedTypeGame(room *game.SpeedTypeRoom) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	
-	log.Printf("Game ended for room %s - redirecting players to login", room.ID)
-	
-	// Get both players' connections
-	var conns []*Connection
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil {
-				conns = append(conns, conn)
-			}
+	// The players are still connected to the previous game's page, and keep
+	// their reconnect tokens for the next one
+	players := make([]*LobbyPlayer, len(prev.seats))
+	for i, seat := range prev.seats {
+		players[i] = &LobbyPlayer{
+			PlayerID:       seat.PlayerID,
+			Name:           seat.Name,
+			AccountID:      seat.AccountID,
+			RoomCode:       prev.RoomCode,
+			SessionID:      seat.SessionID,
+			Conn:           m.connections[seat.PlayerID],
+			ReconnectToken: seat.Token,
 		}
 	}
-	
-	// Delete the game room
-	delete(m.speedTypeRooms, room.ID)
-	
-	// Send redirect message to all players
-	redirectMsg := map[string]interface{}{
-		"type": "redirect",
-		"url":  "/",
+	room := NewGameRoom(minigame, players, prev.opts)
+	m.gameRooms[roomID] = room
+
+	redirect := net.RedirectMessage{
+		Type: "redirect",
+		URL:  "/" + gameType + ".html",
 	}
-	
-	for _, conn := range conns {
-		conn.SendMessage(redirectMsg)
-		// Remove player from connections and lobby
-		if conn.playerID > 0 {
-			delete(m.connections, conn.playerID)
-		}
-		// Remove from lobby if present
-		for i, lp := range m.lobby {
-			if lp.PlayerID == conn.playerID {
-				m.lobby = append(m.lobby[:i], m.lobby[i+1:]...)
-				break
-			}
+	for _, lp := range players {
+		if lp.Conn != nil {
+			lp.Conn.setGameRoom(room)
+			lp.Conn.SendMessage(redirect)
 		}
 	}
-	
-	// Reset everything for next game
-	m.lobby = make([]*LobbyPlayer, 0)
-	m.nextPlayerID = 1
-	m.selectedBy = nil
-	
-	log.Printf("All players redirected to login. Server reset for next game.")
-}
-
-func (m *Matchmaking) startGame() {
-	if len(m.lobby) != 2 {
-		return
+	// The previous room has finished, so its spectators are safe to read
+	for conn := range prev.spectators {
+		conn.SendMessage(redirect)
 	}
 
-	p1 := m.lobby[0]
-	p2 := m.lobby[1]
+	slog.Info("Starting next game of party series", logging.RoomCode(prev.RoomCode), logging.RoomID(roomID), logging.GameType(gameType), "game", series.current+1)
+	go room.run()
+	go m.continueSeries(room)
+}
 
-	// Create room
-	roomID := m.generateRoomID()
-	room := game.NewRoom(roomID)
-	room.AddPlayer(p1.PlayerID, p1.Name)
-	room.AddPlayer(p2.PlayerID, p2.Name)
-	m.rooms[roomID] = room
-
-	// Assign players to room
-	p1.Conn.room = room
-	p1.Conn.playerIdx = 0
-	p2.Conn.room = room
-	p2.Conn.playerIdx = 1
-
-	// Clear lobby
-	m.lobby = make([]*LobbyPlayer, 0)
-
-	// Send welcome messages
-	p1.Conn.SendWelcome(p1.PlayerID, roomID, nil)
-	p2.Conn.SendWelcome(p2.PlayerID, roomID, nil)
+// removeLobbyUnlocked drops the lobby for the given room code along with its players
+// Must be called with lock held
+func (m *Matchmaking) removeLobbyUnlocked(roomCode string) {
+	delete(m.lobbies, roomCode)
+	slog.Debug("Removed lobby", logging.RoomCode(roomCode), "lobbies", len(m.lobbies))
 }
 
 func (m *Matchmaking) GetLobbyState(roomCode string) *net.LobbyState {
@@ -1207,141 +554,32 @@ func (m *Matchmaking) GetLobbyState(roomCode string) *net.LobbyState {
 }
 
 func (m *Matchmaking) GetLobbyStateUnlocked(roomCode string) *net.LobbyState {
-	// Filter players by room code and ensure they have valid connections
-	var filteredPlayers []*LobbyPlayer
-	for _, lp := range m.lobby {
-		if lp.RoomCode == roomCode {
-			// Only include players with valid connections
-			if _, ok := m.connections[lp.PlayerID]; ok && lp.Conn != nil {
-				filteredPlayers = append(filteredPlayers, lp)
-			}
-		}
-	}
-	
-	log.Printf("GetLobbyState for room '%s': %d players (total lobby: %d)", roomCode, len(filteredPlayers), len(m.lobby))
-	players := make([]net.LobbyPlayer, len(filteredPlayers))
-	selectedGame := ""
-	
-	for i, lp := range filteredPlayers {
-		players[i] = net.LobbyPlayer{
-			ID:    lp.PlayerID,
-			Name:  lp.Name,
-			Ready: lp.Ready,
-		}
-		if lp.SelectedGame != "" {
-			selectedGame = lp.SelectedGame
-		}
-		log.Printf("  Player %d: %s (ready: %v)", lp.PlayerID, lp.Name, lp.Ready)
-	}
-
-	state := "waiting"
-	if len(players) == 2 && selectedGame != "" {
-		allReady := true
-		for _, p := range players {
-			if !p.Ready {
-				allReady = false
-				break
-			}
-		}
-		if allReady {
-			state = "starting"
-		} else {
-			state = "ready"
-		}
-	}
-
-	return &net.LobbyState{
-		Players:      players,
-		State:        state,
-		SelectedGame: selectedGame,
-		SelectedBy:   m.selectedBy,
-	}
-}
-
-func (m *Matchmaking) broadcastSpeedTypeState(room *game.SpeedTypeRoom) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// Send state to all players in the room
-	// Only send if connection is still associated with this room
-	state := room.GetState()
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil {
-				// Only send if connection is still in this room (not returned to lobby)
-				if conn.speedTypeRoom == room {
-					conn.SendMessage(state)
-				}
-			}
-		}
-	}
-	
-	// Check if game ended and send summary if needed
-	// This handles the case where both players submit quickly and the round ends
-	// before the ticker loop can check for game end
-	if room.State == "results" && room.CheckGameEnd() {
-		log.Printf("Game ended after %d rounds (from broadcastSpeedTypeState). Sending summary...", room.RoundNumber)
-		summary := room.GetGameSummary()
-		if summary != nil {
-			summaryMsg := &net.GameSummaryMessage{
-				Type:          "gameSummary",
-				Player1ID:      summary.Player1ID,
-				Player1Name:   summary.Player1Name,
-				Player1Score:  summary.Player1Score,
-				Player1AvgTime: summary.Player1AvgTime,
-				Player2ID:      summary.Player2ID,
-				Player2Name:    summary.Player2Name,
-				Player2Score:   summary.Player2Score,
-				Player2AvgTime: summary.Player2AvgTime,
-				WinnerID:       summary.WinnerID,
-				RoundHistory:   make([]net.RoundHistoryData, len(summary.RoundHistory)),
-			}
-			for i, rh := range summary.RoundHistory {
-				summaryMsg.RoundHistory[i] = net.RoundHistoryData{
-					RoundNumber:   rh.RoundNumber,
-					Player1TimeMs: rh.Player1TimeMs,
-					Player2TimeMs: rh.Player2TimeMs,
-					WinnerID:      rh.WinnerID,
-					Word:          rh.Word,
-				}
-			}
-			conns := m.getRoomConnectionsUnlocked(room)
-			log.Printf("Sending game summary to %d connections (from broadcastSpeedTypeState)", len(conns))
-			for _, conn := range conns {
-				conn.SendMessage(summaryMsg)
-			}
-		} else {
-			log.Printf("ERROR: GetGameSummary returned nil in broadcastSpeedTypeState!")
-		}
-	}
-}
-
-// getRoomConnectionsUnlocked returns active connections for players in a speed type room
-// Must be called with lock held
-func (m *Matchmaking) getRoomConnectionsUnlocked(room *game.SpeedTypeRoom) []*Connection {
-	var conns []*Connection
-	for _, player := range room.Players {
-		if player != nil {
-			if conn, ok := m.connections[player.ID]; ok && conn != nil && conn.speedTypeRoom == room {
-				conns = append(conns, conn)
-			}
-		}
+	lobby := m.lobbies[roomCode]
+	if lobby == nil {
+		return NewLobby(roomCode, m.defaultRules()).State()
 	}
-	return conns
+	state := lobby.State()
+	slog.Debug("GetLobbyState", logging.RoomCode(roomCode), "players", len(state.Players), "state", state.State)
+	return state
 }
 
-
 func (m *Matchmaking) broadcastLobbyUpdateUnlocked(roomCode string) {
 	// This function assumes the lock is already held by the caller
-	// Only broadcast to players in the same room
-	lobbyState := m.GetLobbyStateUnlocked(roomCode)
-	log.Printf("Broadcasting lobby update to room '%s': %d players", roomCode, len(lobbyState.Players))
-	for _, lp := range m.lobby {
-		if lp.Conn != nil && lp.RoomCode == roomCode {
-			log.Printf("Sending lobby update to player %d (%s)", lp.PlayerID, lp.Name)
+	// Only broadcast to players and spectators in the same room
+	lobby := m.lobbies[roomCode]
+	if lobby == nil {
+		return
+	}
+	lobbyState := lobby.State()
+	slog.Debug("Broadcasting lobby update", logging.RoomCode(roomCode), "players", len(lobbyState.Players), "spectators", lobbyState.Spectators)
+	for _, lp := range lobby.Players {
+		if lp.Conn != nil {
 			lp.Conn.SendLobbyUpdate(lobbyState)
 		}
 	}
+	for _, sc := range lobby.Spectators {
+		sc.SendLobbyUpdate(lobbyState)
+	}
 }
 
 func (m *Matchmaking) BroadcastLobbyUpdate(roomCode string) {
@@ -1357,75 +595,60 @@ func (m *Matchmaking) generateRoomID() string {
 	return fmt.Sprintf("room%d", id)
 }
 
-func (m *Matchmaking) GetRoom(roomID string) *game.Room {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	return m.rooms[roomID]
-}
+// roomCodeAlphabet leaves out letters and digits that are easy to mix up
+const roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
 
-// cleanupEmptyRoomsUnlocked removes game rooms that have no active connections
-// This function assumes the lock is already held
-func (m *Matchmaking) cleanupEmptyRoomsUnlocked() {
-	// Clean up speed type rooms that have ended AND have no active players
-	// Don't clean up active games even if connections temporarily drop (e.g., during redirect)
-	for roomID, room := range m.speedTypeRooms {
-		if !room.GameEnded {
-			continue // Don't clean up rooms that are still active
-		}
-		hasActivePlayer := false
-		for _, player := range room.Players {
-			if player != nil {
-				if _, ok := m.connections[player.ID]; ok {
-					hasActivePlayer = true
-					break
-				}
-			}
-		}
-		if !hasActivePlayer {
-			delete(m.speedTypeRooms, roomID)
-			log.Printf("Cleaned up ended speed type room %s (no active players)", roomID)
+// generateRoomCodeUnlocked picks a random room code that no lobby, game
+// room or tournament is using
+// Must be called with lock held
+func (m *Matchmaking) generateRoomCodeUnlocked() string {
+	for {
+		b := make([]byte, 5)
+		rand.Read(b)
+		for i := range b {
+			b[i] = roomCodeAlphabet[int(b[i])%len(roomCodeAlphabet)]
+		}
+		code := string(b)
+		if m.roomCodeInUseUnlocked(code) {
+			continue
 		}
+		return code
 	}
-	
-	// Clean up math sprint rooms that have ended AND have no active players
-	// Don't clean up active games even if connections temporarily drop (e.g., during redirect)
-	for roomID, room := range m.mathSprintRooms {
-		if !room.GameEnded {
-			continue // Don't clean up rooms that are still active
-		}
-		hasActivePlayer := false
-		for _, player := range room.Players {
-			if player != nil {
-				if _, ok := m.connections[player.ID]; ok {
-					hasActivePlayer = true
-					break
-				}
-			}
-		}
-		if !hasActivePlayer {
-			delete(m.mathSprintRooms, roomID)
-			log.Printf("Cleaned up ended math sprint room %s (no active players)", roomID)
+}
+
+// roomCodeInUseUnlocked reports whether anything is using the room code
+// Must be called with lock held
+func (m *Matchmaking) roomCodeInUseUnlocked(code string) bool {
+	if m.lobbies[code] != nil || m.tournaments[code] != nil || m.tournamentMatches[code] != nil {
+		return true
+	}
+	for _, room := range m.gameRooms {
+		if room.RoomCode == code {
+			return true
 		}
 	}
-	
-	// Clean up click speed rooms that have ended AND have no active players
+	return false
+}
+
+// cleanupEmptyRoomsUnlocked removes game rooms that have no active connections
+// This function assumes the lock is already held
+func (m *Matchmaking) cleanupEmptyRoomsUnlocked() {
+	// Clean up rooms that have ended AND have no active players
 	// Don't clean up active games even if connections temporarily drop (e.g., during redirect)
-	for roomID, room := range m.clickSpeedRooms {
-		if !room.GameEnded {
+	for roomID, room := range m.gameRooms {
+		if !room.Ended() {
 			continue // Don't clean up rooms that are still active
 		}
 		hasActivePlayer := false
-		for _, player := range room.Players {
-			if player != nil {
-				if _, ok := m.connections[player.ID]; ok {
-					hasActivePlayer = true
-					break
-				}
+		for _, playerID := range room.PlayerIDs() {
+			if _, ok := m.connections[playerID]; ok {
+				hasActivePlayer = true
+				break
 			}
 		}
 		if !hasActivePlayer {
-			delete(m.clickSpeedRooms, roomID)
-			log.Printf("Cleaned up ended click speed room %s (no active players)", roomID)
+			delete(m.gameRooms, roomID)
+			slog.Debug("Cleaned up ended game room with no active players", logging.RoomCode(room.RoomCode), logging.RoomID(roomID), logging.GameType(room.GameType))
 		}
 	}
 }
@@ -1437,135 +660,61 @@ func (m *Matchmaking) RemovePlayer(playerID int, conn *Connection) {
 	// Clean up empty rooms when a player leaves
 	defer m.cleanupEmptyRoomsUnlocked()
 
+	if conn.spectator {
+		m.removeSpectatorUnlocked(conn)
+		return
+	}
+	m.leaveQueueUnlocked(conn)
+
 	// CRITICAL: Only remove from connections if this is the CURRENT connection
 	// This prevents old connections from removing new ones after redirect
 	if existingConn, ok := m.connections[playerID]; ok && existingConn == conn {
 		delete(m.connections, playerID)
-		log.Printf("Removed connection for player %d", playerID)
-		
-		// Check if this player was in a game room, and mark room as ended if no active connections remain
-		// BUT only if the game has actually started (not in "waiting" or "ready" state)
-		// This prevents marking rooms as ended during the redirect phase when players temporarily disconnect
-		if conn.speedTypeRoom != nil && !conn.speedTypeRoom.GameEnded {
-			room := conn.speedTypeRoom
-			hasActiveConnections := false
-			for _, p := range room.Players {
-				if p != nil {
-					if _, ok := m.connections[p.ID]; ok {
-						hasActiveConnections = true
-			break
-		}
-				}
-			}
-			// Only mark as ended if no active connections AND game has started (past "waiting"/"ready")
-			// This allows players to reconnect after redirect without the room being prematurely ended
-			if !hasActiveConnections && room.State != "waiting" && room.State != "ready" {
-				room.GameEnded = true
-				log.Printf("Marked speed type room %s as ended - all players disconnected (state: %s)", room.ID, room.State)
-			} else if !hasActiveConnections {
-				log.Printf("Not marking speed type room %s as ended - waiting for reconnection (state: %s)", room.ID, room.State)
-			}
-		}
-		if conn.mathSprintRoom != nil && !conn.mathSprintRoom.GameEnded {
-			room := conn.mathSprintRoom
-			hasActiveConnections := false
-			for _, p := range room.Players {
-				if p != nil {
-					if _, ok := m.connections[p.ID]; ok {
-						hasActiveConnections = true
-						break
-					}
-				}
-			}
-			// Only mark as ended if no active connections AND game has started (past "waiting"/"ready")
-			// This allows players to reconnect after redirect without the room being prematurely ended
-			if !hasActiveConnections && room.State != "waiting" && room.State != "ready" {
-				room.GameEnded = true
-				log.Printf("Marked math sprint room %s as ended - all players disconnected (state: %s)", room.ID, room.State)
-			} else if !hasActiveConnections {
-				log.Printf("Not marking math sprint room %s as ended - waiting for reconnection (state: %s)", room.ID, room.State)
-			}
-		}
-		if conn.clickSpeedRoom != nil && !conn.clickSpeedRoom.GameEnded {
-			room := conn.clickSpeedRoom
-			hasActiveConnections := false
-			for _, p := range room.Players {
-				if p != nil {
-					if _, ok := m.connections[p.ID]; ok {
-						hasActiveConnections = true
-						break
-					}
-				}
-			}
-			// Only mark as ended if no active connections AND game has started (past "waiting"/"ready")
-			// This allows players to reconnect after redirect without the room being prematurely ended
-			if !hasActiveConnections && room.State != "waiting" && room.State != "ready" {
-				room.GameEnded = true
-				log.Printf("Marked click speed room %s as ended - all players disconnected (state: %s)", room.ID, room.State)
-			} else if !hasActiveConnections {
-				log.Printf("Not marking click speed room %s as ended - waiting for reconnection (state: %s)", room.ID, room.State)
-			}
+		slog.Debug("Removed connection", logging.RoomCode(conn.session.RoomCode), logging.PlayerID(playerID))
+
+		// Let the player's game room know; it decides whether the game is over
+		if room := conn.currentGameRoom(); room != nil {
+			room.Leave(playerID, conn)
 		}
 	} else {
-		log.Printf("Skipping connection removal for player %d - connection already replaced", playerID)
+		slog.Debug("Skipping connection removal - connection already replaced", logging.RoomCode(conn.session.RoomCode), logging.PlayerID(playerID))
 	}
-	
-	// Find and remove player from lobby
-	// Also remove by playerID even if connection doesn't match (handles connection replacement)
-	removedFromLobby := false
-	var roomCode string
-	for i, lp := range m.lobby {
-		if lp.PlayerID == playerID {
-			roomCode = lp.RoomCode
-			m.lobby = append(m.lobby[:i], m.lobby[i+1:]...)
-			log.Printf("Player %d removed from lobby (room '%s'), %d players remaining", playerID, roomCode, len(m.lobby))
-			removedFromLobby = true
-			
-			// Clear selected game if the player who selected it left
-			if m.selectedBy != nil && m.selectedBy.PlayerID == playerID {
-				m.selectedBy = nil
-				for _, remaining := range m.lobby {
-					if remaining.RoomCode == roomCode {
-						remaining.SelectedGame = ""
-						remaining.Ready = false
-					}
-				}
-				}
-				break
-			}
-		}
 
-	// Broadcast lobby update if player was removed
-	if removedFromLobby && roomCode != "" {
-		m.broadcastLobbyUpdateUnlocked(roomCode)
-		
-		// Reset player ID counter if lobby is empty
-		if len(m.lobby) == 0 {
-			m.nextPlayerID = 1
-			log.Printf("Reset player ID counter to 1 (lobby empty)")
+	// Find and remove player from their room code's lobby
+	// Only the player's current connection may remove them (an old connection
+	// closing after a reload must not kick the new one out)
+	if lobby, lp := m.findLobbyPlayerUnlocked(playerID); lp != nil && lp.Conn == conn {
+		lobby.Remove(playerID)
+		slog.Info("Player left lobby", logging.RoomCode(lobby.Code), logging.PlayerID(playerID), "players", len(lobby.Players))
+		if lobby.IsEmpty() {
+			m.removeLobbyUnlocked(lobby.Code)
+		} else {
+			m.broadcastLobbyUpdateUnlocked(lobby.Code)
 		}
 	}
 
 	// Cleanup is now handled by cleanupEmptyRoomsUnlocked() which is deferred
-	
-	// Reset player IDs only when lobby is empty AND no active game rooms exist
-	// This prevents resetting during the game start transition when connections temporarily drop
-	activeGames := len(m.speedTypeRooms) + len(m.mathSprintRooms) + len(m.clickSpeedRooms)
-	if len(m.lobby) == 0 && len(m.connections) == 0 && activeGames == 0 {
+
+	// Reset player IDs only when no lobbies, connections or game rooms remain
+	// Resetting any earlier could hand out IDs still in use in another room code
+	if len(m.lobbies) == 0 && len(m.connections) == 0 && len(m.gameRooms) == 0 {
 		m.nextPlayerID = 1
-		log.Printf("Reset player ID counter to 1")
+		slog.Debug("Reset player ID counter to 1")
 	}
 }
 
-func (m *Matchmaking) StartRoomTicks() {
-	ticker := time.NewTicker(game.TickDuration)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		m.mu.Lock()
-		for _, room := range m.rooms {
-			room.ProcessTick()
+// removeSpectatorUnlocked detaches a spectator from whatever it was watching
+// Must be called with lock held
+func (m *Matchmaking) removeSpectatorUnlocked(conn *Connection) {
+	if room := conn.currentGameRoom(); room != nil {
+		room.Unwatch(conn)
+	}
+	if lobby := m.lobbies[conn.session.RoomCode]; lobby != nil && lobby.RemoveSpectator(conn) {
+		slog.Info("Spectator left lobby", logging.RoomCode(lobby.Code), logging.PlayerID(conn.playerID))
+		if lobby.IsEmpty() {
+			m.removeLobbyUnlocked(lobby.Code)
+		} else {
+			m.broadcastLobbyUpdateUnlocked(lobby.Code)
 		}
-		m.mu.Unlock()
 	}
 }
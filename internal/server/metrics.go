@@ -0,0 +1,74 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/metrics"
+	"net/http"
+)
+
+// Metrics holds everything served at /metrics
+var Metrics = metrics.NewRegistry()
+
+var (
+	connectionsOpen = Metrics.Gauge("goservergames_connections",
+		"Open WebSocket connections")
+	gamesStarted = Metrics.CounterVec("goservergames_games_started_total",
+		"Games whose first round started, rematches and party series games included", "game_type", game.Types()...)
+	gamesFinished = Metrics.CounterVec("goservergames_games_finished_total",
+		"Games played to the end", "game_type", game.Types()...)
+	messagesDropped = Metrics.Counter("goservergames_messages_dropped_total",
+		"Messages dropped because a connection's send buffer was full")
+	roomCommandSeconds = Metrics.Histogram("goservergames_room_command_seconds",
+		"Time a game room's goroutine takes to handle one command or tick",
+		[]float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25})
+	logins = Metrics.CounterVec("goservergames_logins_total",
+		"Login attempts by result", "result", LoginSuccess, LoginFailure, LoginThrottled)
+)
+
+// Login results counted by CountLogin
+const (
+	LoginSuccess   = "success"
+	LoginFailure   = "failure"
+	LoginThrottled = "throttled"
+)
+
+// CountLogin records the result of a login attempt
+func CountLogin(result string) {
+	logins.With(result).Inc()
+}
+
+// HandleMetrics serves the metrics in the Prometheus text format, along with
+// the matchmaker's lobbies and rooms as they are when scraped. It must only
+// be called once.
+func HandleMetrics(mm *Matchmaking) http.Handler {
+	Metrics.GaugeFunc("goservergames_lobby_players",
+		"Players in each room code's lobby", "room_code", mm.lobbyPlayerCounts)
+	Metrics.GaugeFunc("goservergames_active_rooms",
+		"Game rooms still running by game type", "game_type", mm.activeRoomCounts)
+	return Metrics
+}
+
+func (m *Matchmaking) lobbyPlayerCounts() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]float64, len(m.lobbies))
+	for roomCode, lobby := range m.lobbies {
+		counts[roomCode] = float64(len(lobby.Players))
+	}
+	return counts
+}
+
+func (m *Matchmaking) activeRoomCounts() map[string]float64 {
+	counts := make(map[string]float64)
+	for _, gameType := range game.Types() {
+		counts[gameType] = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, room := range m.gameRooms {
+		if !room.Ended() {
+			counts[room.GameType]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/logging"
+	"GoServerGames/internal/net"
+	"log/slog"
+	"time"
+)
+
+// Quick-match settings. Players matching by skill start out only paired with
+// someone close to their rating, and the range widens the longer they wait
+// so nobody waits forever.
+const (
+	quickMatchInterval    = 1 * time.Second // How often the queue is matched and players told how it's going
+	quickMatchBaseRange   = 100             // Rating gap allowed straight away
+	quickMatchRangeGrowth = 50              // Added to the gap for every quickMatchRangeStep waited
+	quickMatchRangeStep   = 5 * time.Second
+)
+
+// QuickMatch is what a player without a room code is looking for
+type QuickMatch struct {
+	GameType string
+	ByRating bool // Only pair with players near their rating in the game
+}
+
+// queueEntry is a player waiting for a quick match
+type queueEntry struct {
+	playerID int
+	name     string
+	conn     *Connection
+	choice   QuickMatch
+	rating   int
+	joinedAt time.Time
+}
+
+// ratingRange returns how far from their rating an opponent may be, or -1
+// if the player takes anyone
+func (e *queueEntry) ratingRange(now time.Time) int {
+	if !e.choice.ByRating {
+		return -1
+	}
+	return quickMatchBaseRange + quickMatchRangeGrowth*int(now.Sub(e.joinedAt)/quickMatchRangeStep)
+}
+
+// accepts reports whether the player is happy to play the other one
+func (e *queueEntry) accepts(other *queueEntry, now time.Time) bool {
+	if e.choice.GameType != other.choice.GameType || e.name == other.name {
+		return false
+	}
+	limit := e.ratingRange(now)
+	return limit < 0 || abs(e.rating-other.rating) <= limit
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// JoinQueue puts a player looking for a quick match in the queue and
+// returns their player ID, or 0 if the game type is unknown
+func (m *Matchmaking) JoinQueue(name string, choice QuickMatch, conn *Connection) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !game.IsRegistered(choice.GameType) {
+		slog.Warn("JoinQueue: Rejected player - unknown game type", logging.GameType(choice.GameType), "name", name)
+		return 0
+	}
+	if !m.drainDeadline.IsZero() {
+		slog.Info("JoinQueue: Rejected player - server is shutting down", logging.GameType(choice.GameType), "name", name)
+		return 0
+	}
+
+	// A reload replaces the player's place in the queue rather than adding a second one
+	for _, e := range m.queue {
+		if e.name == name {
+			slog.Debug("JoinQueue: Player rejoining the queue - replacing old connection", logging.PlayerID(e.playerID), logging.GameType(e.choice.GameType), "name", name)
+			m.leaveQueueUnlocked(e.conn)
+			e.conn.conn.Close()
+			break
+		}
+	}
+
+	playerID := m.nextPlayerID
+	m.nextPlayerID++
+	conn.playerID = playerID
+	conn.setGameRoom(nil)
+	m.connections[playerID] = conn
+
+	m.queue = append(m.queue, &queueEntry{
+		playerID: playerID,
+		name:     name,
+		conn:     conn,
+		choice:   choice,
+		rating:   m.ratings.For(name)[choice.GameType],
+		joinedAt: time.Now(),
+	})
+	slog.Info("Player joined the quick-match queue", logging.PlayerID(playerID), logging.GameType(choice.GameType), "name", name, "byRating", choice.ByRating, "waiting", len(m.queue))
+
+	m.matchQueueUnlocked()
+	m.sendQueueStatusUnlocked()
+	return playerID
+}
+
+// leaveQueueUnlocked takes a connection out of the queue, if it is in it
+// Must be called with lock held
+func (m *Matchmaking) leaveQueueUnlocked(conn *Connection) bool {
+	for i, e := range m.queue {
+		if e.conn == conn {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			slog.Info("Player left the quick-match queue", logging.PlayerID(e.playerID), logging.GameType(e.choice.GameType), "name", e.name)
+			return true
+		}
+	}
+	return false
+}
+
+// StartQuickMatch keeps matching the queue as rating ranges widen
+func (m *Matchmaking) StartQuickMatch() {
+	ticker := time.NewTicker(quickMatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+		m.mu.Lock()
+		if len(m.queue) > 0 {
+			m.matchQueueUnlocked()
+			m.sendQueueStatusUnlocked()
+		}
+		m.mu.Unlock()
+	}
+}
+
+// matchQueueUnlocked pairs waiting players, longest waiting first, each with
+// the closest rated player who wants the same game and accepts them
+// Must be called with lock held
+func (m *Matchmaking) matchQueueUnlocked() {
+	now := time.Now()
+	for i := 0; i < len(m.queue); i++ {
+		first := m.queue[i]
+		best := -1
+		for j := i + 1; j < len(m.queue); j++ {
+			other := m.queue[j]
+			if !first.accepts(other, now) || !other.accepts(first, now) {
+				continue
+			}
+			if best < 0 || abs(other.rating-first.rating) < abs(m.queue[best].rating-first.rating) {
+				best = j
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		second := m.queue[best]
+		m.queue = append(m.queue[:best], m.queue[best+1:]...)
+		m.queue = append(m.queue[:i], m.queue[i+1:]...)
+		i--
+		m.startQuickMatchUnlocked(first.choice.GameType, []*queueEntry{first, second})
+	}
+}
+
+// startQuickMatchUnlocked gives matched players a fresh room code and starts
+// their game straight away, as if they had readied up in its lobby
+// Must be called with lock held
+func (m *Matchmaking) startQuickMatchUnlocked(gameType string, entries []*queueEntry) {
+	roomCode := m.generateRoomCodeUnlocked()
+	lobby := NewLobby(roomCode, m.defaultRules())
+	for _, e := range entries {
+		lp := &LobbyPlayer{
+			PlayerID:  e.playerID,
+			Name:      e.name,
+			AccountID: e.conn.session.AccountID,
+			RoomCode:  roomCode,
+			SessionID: e.conn.session.ID,
+			Conn:      e.conn,
+			Ratings:   m.ratings.For(e.name),
+		}
+		lobby.Add(lp)
+		e.conn.lobbyPlayer = lp
+
+		// The game page connects with the session, so it has to know the room code
+		if _, ok := e.conn.sessions.SetRoomCode(e.conn.session.ID, roomCode); !ok {
+			slog.Info("Quick match: Session ended while matching", logging.RoomCode(roomCode), logging.PlayerID(e.playerID), "name", e.name)
+		}
+	}
+	lobby.SelectGame(lobby.Players[0], gameType)
+	m.lobbies[roomCode] = lobby
+
+	slog.Info("Quick match: Players paired", logging.RoomCode(roomCode), logging.GameType(gameType), "players", []string{entries[0].name, entries[1].name})
+	m.startSelectedGameUnlocked(gameType, roomCode)
+}
+
+// sendQueueStatusUnlocked tells every waiting player how their search is going
+// Must be called with lock held
+func (m *Matchmaking) sendQueueStatusUnlocked() {
+	now := time.Now()
+	waiting := make(map[string]int)
+	for _, e := range m.queue {
+		waiting[e.choice.GameType]++
+	}
+	for _, e := range m.queue {
+		e.conn.SendMessage(net.QueueStatusMessage{
+			Type:        "queueStatus",
+			PlayerID:    e.playerID,
+			GameType:    e.choice.GameType,
+			Waiting:     waiting[e.choice.GameType],
+			Rating:      e.rating,
+			ByRating:    e.choice.ByRating,
+			RatingRange: e.ratingRange(now),
+			WaitedMs:    now.Sub(e.joinedAt).Milliseconds(),
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"GoServerGames/internal/config"
+	"GoServerGames/internal/storage"
+	"testing"
+	"time"
+)
+
+// quickMatchTest is a matchmaker with players looking for quick matches
+type quickMatchTest struct {
+	t        *testing.T
+	mm       *Matchmaking
+	sessions *SessionStore
+	conns    map[string]*Connection
+	nextID   int
+}
+
+func newQuickMatchTest(t *testing.T) *quickMatchTest {
+	cfg := config.Default()
+	qt := &quickMatchTest{
+		t:        t,
+		mm:       NewMatchmaking(cfg),
+		sessions: NewSessionStore(cfg.Session),
+		conns:    make(map[string]*Connection),
+	}
+	t.Cleanup(func() {
+		// Ends the games the pairings started
+		qt.mm.Shutdown(100 * time.Millisecond)
+		qt.sessions.Close()
+	})
+	return qt
+}
+
+// join logs a player in looking for a quick match and queues them
+func (qt *quickMatchTest) join(name, gameType string, byRating bool) int {
+	qt.t.Helper()
+	qt.nextID++
+	choice := QuickMatch{GameType: gameType, ByRating: byRating}
+	session, err := qt.sessions.CreateQuickMatchSession(&storage.Account{ID: qt.nextID, Username: name}, choice)
+	if err != nil {
+		qt.t.Fatal(err)
+	}
+	conn := &Connection{
+		send:     make(chan []byte, 256),
+		session:  session,
+		sessions: qt.sessions,
+		done:     make(chan struct{}),
+		closing:  make(chan string, 1),
+	}
+	qt.conns[name] = conn
+	playerID := qt.mm.JoinQueue(name, choice, conn)
+	if playerID == 0 {
+		qt.t.Fatalf("JoinQueue(%s, %s) rejected the player", name, gameType)
+	}
+	return playerID
+}
+
+// waiting returns the names still in the queue
+func (qt *quickMatchTest) waiting() []string {
+	qt.mm.mu.Lock()
+	defer qt.mm.mu.Unlock()
+	var names []string
+	for _, e := range qt.mm.queue {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// roomCode returns the room code a player's session was given on pairing
+func (qt *quickMatchTest) roomCode(name string) string {
+	qt.t.Helper()
+	session, err := qt.sessions.GetSession(qt.conns[name].session.ID)
+	if err != nil {
+		qt.t.Fatal(err)
+	}
+	return session.RoomCode
+}
+
+func TestQuickMatchPairsSameGame(t *testing.T) {
+	qt := newQuickMatchTest(t)
+	qt.join("alice", "speedtype", false)
+	qt.join("bob", "mathsprint", false)
+	if got := qt.waiting(); len(got) != 2 {
+		t.Fatalf("queue before a match = %v, want alice and bob", got)
+	}
+
+	qt.join("carol", "speedtype", false)
+	if got := qt.waiting(); len(got) != 1 || got[0] != "bob" {
+		t.Fatalf("queue after carol joined = %v, want [bob]", got)
+	}
+
+	code := qt.roomCode("alice")
+	if code == "" || qt.roomCode("carol") != code {
+		t.Fatalf("room codes after pairing: alice %q, carol %q, want the same one", code, qt.roomCode("carol"))
+	}
+	if qt.roomCode("bob") != "" {
+		t.Errorf("bob was given room code %q while still waiting", qt.roomCode("bob"))
+	}
+
+	qt.mm.mu.Lock()
+	defer qt.mm.mu.Unlock()
+	if len(qt.mm.gameRooms) != 1 {
+		t.Fatalf("game rooms = %d, want 1", len(qt.mm.gameRooms))
+	}
+	for _, room := range qt.mm.gameRooms {
+		if room.GameType != "speedtype" || room.RoomCode != code || len(room.PlayerIDs()) != 2 {
+			t.Errorf("game room = %s in %s with %d players, want speedtype in %s with 2", room.GameType, room.RoomCode, len(room.PlayerIDs()), code)
+		}
+	}
+}
+
+func TestQuickMatchCancel(t *testing.T) {
+	qt := newQuickMatchTest(t)
+	aliceID := qt.join("alice", "speedtype", false)
+
+	// Leaving the queue page closes the connection
+	qt.mm.RemovePlayer(aliceID, qt.conns["alice"])
+	if got := qt.waiting(); len(got) != 0 {
+		t.Fatalf("queue after alice left = %v, want empty", got)
+	}
+
+	qt.join("bob", "speedtype", false)
+	if got := qt.waiting(); len(got) != 1 || got[0] != "bob" {
+		t.Errorf("queue after bob joined = %v, want [bob] with nobody to play", got)
+	}
+	if len(qt.mm.gameRooms) != 0 {
+		t.Errorf("game rooms = %d, want none", len(qt.mm.gameRooms))
+	}
+}
+
+func TestQueueEntryAccepts(t *testing.T) {
+	now := time.Now()
+	entry := func(name, gameType string, byRating bool, rating int, waited time.Duration) *queueEntry {
+		return &queueEntry{name: name, choice: QuickMatch{GameType: gameType, ByRating: byRating}, rating: rating, joinedAt: now.Add(-waited)}
+	}
+	tests := []struct {
+		name string
+		a, b *queueEntry
+		want bool
+	}{
+		{"same game", entry("alice", "speedtype", false, 1200, 0), entry("bob", "speedtype", false, 1600, 0), true},
+		{"other game", entry("alice", "speedtype", false, 1200, 0), entry("bob", "mathsprint", false, 1200, 0), false},
+		{"same player", entry("alice", "speedtype", false, 1200, 0), entry("alice", "speedtype", false, 1200, 0), false},
+		{"close rating", entry("alice", "speedtype", true, 1200, 0), entry("bob", "speedtype", true, 1300, 0), true},
+		{"far rating", entry("alice", "speedtype", true, 1200, 0), entry("bob", "speedtype", true, 1350, 0), false},
+		{"range widened by waiting", entry("alice", "speedtype", true, 1200, 5*time.Second), entry("bob", "speedtype", true, 1350, 6*time.Second), true},
+		{"only one has waited", entry("alice", "speedtype", true, 1200, 5*time.Second), entry("bob", "speedtype", true, 1350, 0), false},
+		{"only one cares about rating", entry("alice", "speedtype", false, 1200, 0), entry("bob", "speedtype", true, 1500, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.accepts(tt.b, now) && tt.b.accepts(tt.a, now); got != tt.want {
+			t.Errorf("%s: matched = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/storage"
+	"math"
+	"sync"
+)
+
+// Elo settings for skill ratings
+const (
+	InitialRating = 1200
+	ratingK       = 32 // Most a player's rating can move in one game
+)
+
+// Ratings keeps an Elo rating for every player name in every game type.
+// A game with more than two players counts as a match between every pair
+// of players, decided by their final places, with each pair's share of the
+// K factor scaled down so a game moves a rating no more than a duel does.
+type Ratings struct {
+	ratings map[string]map[string]float64 // Game type -> player name -> rating
+	mu      sync.Mutex
+}
+
+func NewRatings() *Ratings {
+	return &Ratings{ratings: make(map[string]map[string]float64)}
+}
+
+// ratedPlayer is a player's finish in a game being rated
+type ratedPlayer struct {
+	PlayerID int
+	Name     string
+	Place    int
+}
+
+// Record updates the ratings with a finished game and returns every
+// player's new rating keyed by player ID
+func (r *Ratings) Record(gameType string, summary *game.GameSummary) map[int]game.RatingChange {
+	players := make([]ratedPlayer, len(summary.Standings))
+	for i, s := range summary.Standings {
+		players[i] = ratedPlayer{PlayerID: s.PlayerID, Name: s.Name, Place: s.Place}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.updateUnlocked(gameType, players)
+}
+
+// Replay rebuilds the ratings from the match history, oldest game first
+func (r *Ratings) Replay(records []storage.MatchRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range records {
+		players := make([]ratedPlayer, len(record.Players))
+		for i, p := range record.Players {
+			players[i] = ratedPlayer{PlayerID: p.PlayerID, Name: p.Name, Place: p.Place}
+		}
+		r.updateUnlocked(record.GameType, players)
+	}
+}
+
+// For returns a player's rating in every game type, rounded for display
+func (r *Ratings) For(name string) map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ratings := make(map[string]int)
+	for _, gameType := range game.Types() {
+		rating := float64(InitialRating)
+		if rated, ok := r.ratings[gameType][name]; ok {
+			rating = rated
+		}
+		ratings[gameType] = int(math.Round(rating))
+	}
+	return ratings
+}
+
+// Must be called with lock held
+func (r *Ratings) updateUnlocked(gameType string, players []ratedPlayer) map[int]game.RatingChange {
+	table := r.ratings[gameType]
+	if table == nil {
+		table = make(map[string]float64)
+		r.ratings[gameType] = table
+	}
+
+	before := make([]float64, len(players))
+	for i, p := range players {
+		before[i] = InitialRating
+		if rating, ok := table[p.Name]; ok {
+			before[i] = rating
+		}
+	}
+
+	changes := make(map[int]game.RatingChange, len(players))
+	if len(players) < 2 {
+		return changes
+	}
+	k := float64(ratingK) / float64(len(players)-1)
+	for i, p := range players {
+		delta := 0.0
+		for j, other := range players {
+			if i == j {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (before[j]-before[i])/400))
+			actual := 0.5
+			if p.Place < other.Place {
+				actual = 1
+			} else if p.Place > other.Place {
+				actual = 0
+			}
+			delta += k * (actual - expected)
+		}
+
+		after := before[i] + delta
+		table[p.Name] = after
+		changes[p.PlayerID] = game.RatingChange{
+			Rating: int(math.Round(after)),
+			Change: int(math.Round(after)) - int(math.Round(before[i])),
+		}
+	}
+	return changes
+}
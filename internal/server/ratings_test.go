@@ -0,0 +1,65 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/storage"
+	"reflect"
+	"testing"
+)
+
+// recordedGame is a recorded game with players named in finishing order,
+// alongside their places
+func recordedGame(gameType string, names []string, places []int) storage.MatchRecord {
+	record := storage.MatchRecord{GameType: gameType}
+	for i, name := range names {
+		record.Players = append(record.Players, storage.PlayerRecord{PlayerID: i + 1, Name: name, Place: places[i]})
+	}
+	return record
+}
+
+func TestRatingsReplay(t *testing.T) {
+	history := []storage.MatchRecord{
+		recordedGame("speedtype", []string{"alice", "bob"}, []int{1, 2}),
+		recordedGame("speedtype", []string{"bob", "alice"}, []int{1, 2}),
+		// Each pair of three players shares K/2
+		recordedGame("speedtype", []string{"alice", "carol", "bob"}, []int{1, 2, 3}),
+		// Dave and carol tie for first
+		recordedGame("speedtype", []string{"dave", "carol", "alice", "bob"}, []int{1, 1, 3, 4}),
+		// A tie between equal ratings moves nothing
+		recordedGame("clickspeed", []string{"alice", "bob"}, []int{1, 1}),
+	}
+	r := NewRatings()
+	r.Replay(history)
+
+	tests := []struct {
+		name string
+		want map[string]int
+	}{
+		{"alice", map[string]int{"clickspeed": 1200, "mathsprint": 1200, "speedtype": 1208}},
+		{"bob", map[string]int{"clickspeed": 1200, "mathsprint": 1200, "speedtype": 1170}},
+		{"carol", map[string]int{"clickspeed": 1200, "mathsprint": 1200, "speedtype": 1211}},
+		{"dave", map[string]int{"clickspeed": 1200, "mathsprint": 1200, "speedtype": 1211}},
+		{"erin", map[string]int{"clickspeed": 1200, "mathsprint": 1200, "speedtype": 1200}},
+	}
+	for _, tt := range tests {
+		if got := r.For(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRatingsRecord(t *testing.T) {
+	r := NewRatings()
+	summary := &game.GameSummary{Standings: []game.Standing{
+		{PlayerID: 3, Name: "alice", Place: 1},
+		{PlayerID: 4, Name: "bob", Place: 2},
+	}}
+	got := r.Record("speedtype", summary)
+	want := map[int]game.RatingChange{
+		3: {Rating: 1216, Change: 16},
+		4: {Rating: 1184, Change: -16},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Record = %v, want %v", got, want)
+	}
+}
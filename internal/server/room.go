@@ -0,0 +1,684 @@
+package server
+
+import (
+	"GoServerGames/internal/config"
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/logging"
+	"GoServerGames/internal/net"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const roomCommandBuffer = 64
+
+// RoomOptions holds the per-room settings chosen when a game starts
+type RoomOptions struct {
+	Settings   config.Game // Tick rate, round time limit and the delays between rounds
+	Rules      game.MatchRules
+	Tournament string   // Code of the tournament this game is a match in, "" for a friendly game
+	Series     *Series  // Party series this game is part of, nil for a single game
+	Ratings    *Ratings // Rated with the results of every finished game, nil to leave ratings alone
+
+	// Called from the room goroutine with the final standings of every
+	// finished game and when its first round started; it must not block
+	// on the room
+	OnFinish func(room *GameRoom, summary *game.GameSummary, startedAt time.Time)
+}
+
+type roomCommandKind int
+
+const (
+	cmdJoin roomCommandKind = iota
+	cmdLeave
+	cmdSubmit
+	cmdReadyForNewGame
+	cmdWatch
+	cmdUnwatch
+	cmdTick
+	cmdDrain
+	cmdInspect
+	cmdForceEnd
+)
+
+type roomCommand struct {
+	kind     roomCommandKind
+	playerID int
+	conn     *Connection
+	payload  []byte
+	ready    bool
+	deadline time.Time
+	reply    chan<- net.AdminGameRoom
+}
+
+// roomSeat is a player slot in a game room. Seats are fixed when the room
+// is created, so they can be read from any goroutine.
+type roomSeat struct {
+	PlayerID  int
+	Name      string
+	AccountID int
+	Token     string // Secret the player's game page reconnects with
+	SessionID string // Session the seat belongs to; the token only works with it
+}
+
+// GameRoom runs one minigame in its own goroutine. That goroutine owns the
+// game state and the room's connections; everything else talks to it by
+// sending commands (join, leave, submit, tick) over its channel, so a busy
+// room never holds up the rest of the server.
+type GameRoom struct {
+	ID         string
+	RoomCode   string
+	GameType   string
+	SubmitType string
+	seats      []roomSeat
+	opts       RoomOptions
+	logger     *slog.Logger // Tags every line with the room
+
+	game       game.MiniGame            // Owned by the run goroutine
+	conns      map[int]*Connection      // Owned by the run goroutine
+	spectators map[*Connection]struct{} // Owned by the run goroutine
+	round      int                      // Owned by the run goroutine
+	startedAt  time.Time                // When round 1 of the current game started; owned by the run goroutine
+
+	// Set once the summary is sent and players are deciding on a rematch,
+	// or, without a rematch, until everyone moves on
+	rematchDeadline time.Time // Owned by the run goroutine
+
+	// Set when the server is shutting down: the game may finish, but no
+	// rematch or next game follows, and it is ended at the deadline
+	draining      bool             // Owned by the run goroutine
+	drainDeadline <-chan time.Time // Owned by the run goroutine
+
+	lastTick atomic.Int64 // Unix nanoseconds of the run goroutine's latest tick, read from anywhere
+
+	cmds chan roomCommand
+	done chan struct{}
+}
+
+// NewGameRoom creates a room for the given players. The lobby connections
+// are attached straight away so they receive the first state broadcast.
+func NewGameRoom(g game.MiniGame, players []*LobbyPlayer, opts RoomOptions) *GameRoom {
+	r := &GameRoom{
+		ID:         g.GetID(),
+		RoomCode:   g.GetRoomCode(),
+		GameType:   g.GameType(),
+		SubmitType: g.SubmitType(),
+		opts:       opts,
+		logger:     slog.With(logging.RoomCode(g.GetRoomCode()), logging.RoomID(g.GetID()), logging.GameType(g.GameType())),
+		game:       g,
+		conns:      make(map[int]*Connection),
+		spectators: make(map[*Connection]struct{}),
+		cmds:       make(chan roomCommand, roomCommandBuffer),
+		done:       make(chan struct{}),
+	}
+	for _, lp := range players {
+		g.AddPlayer(lp.PlayerID, lp.Name)
+		r.seats = append(r.seats, newSeat(lp))
+		if lp.Conn != nil {
+			r.conns[lp.PlayerID] = lp.Conn
+		}
+	}
+	r.lastTick.Store(time.Now().UnixNano())
+	return r
+}
+
+// PlayerIDs returns the IDs of the players seated in the room
+func (r *GameRoom) PlayerIDs() []int {
+	ids := make([]int, len(r.seats))
+	for i, seat := range r.seats {
+		ids[i] = seat.PlayerID
+	}
+	return ids
+}
+
+// newSeat seats a lobby player, keeping the reconnect token they already
+// hold from the previous game of a party series
+func newSeat(lp *LobbyPlayer) roomSeat {
+	seat := roomSeat{
+		PlayerID:  lp.PlayerID,
+		Name:      lp.Name,
+		AccountID: lp.AccountID,
+		Token:     lp.ReconnectToken,
+		SessionID: lp.SessionID,
+	}
+	if seat.Token == "" {
+		seat.Token = newReconnectToken()
+	}
+	return seat
+}
+
+// newReconnectToken returns a random secret for a seat
+func newReconnectToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// PlayerIDByToken returns the ID of the seat a reconnect token belongs to.
+// The token has to come from the session the seat was taken with, so a
+// leaked token is no use on its own.
+func (r *GameRoom) PlayerIDByToken(token, sessionID string) (int, bool) {
+	for _, seat := range r.seats {
+		if subtle.ConstantTimeCompare([]byte(seat.Token), []byte(token)) == 1 && seat.SessionID == sessionID {
+			return seat.PlayerID, true
+		}
+	}
+	return 0, false
+}
+
+// reconnectToken returns a player's reconnect token, "" for a spectator
+func (r *GameRoom) reconnectToken(playerID int) string {
+	for _, seat := range r.seats {
+		if seat.PlayerID == playerID {
+			return seat.Token
+		}
+	}
+	return ""
+}
+
+// tickInterval is the time between the room's ticks at the configured tick
+// rate
+func (r *GameRoom) tickInterval() time.Duration {
+	return time.Second / time.Duration(r.opts.Settings.TickRate)
+}
+
+// LastTick returns when the room's goroutine last ticked, or when the room
+// was created if it hasn't ticked yet
+func (r *GameRoom) LastTick() time.Time {
+	return time.Unix(0, r.lastTick.Load())
+}
+
+// Ended reports whether the room's goroutine has finished
+func (r *GameRoom) Ended() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Join attaches a (re)connecting player's connection to the room
+func (r *GameRoom) Join(playerID int, conn *Connection) {
+	r.send(roomCommand{kind: cmdJoin, playerID: playerID, conn: conn})
+}
+
+// Leave detaches a player's connection if it is still the current one
+func (r *GameRoom) Leave(playerID int, conn *Connection) {
+	r.send(roomCommand{kind: cmdLeave, playerID: playerID, conn: conn})
+}
+
+// Submit passes a raw client submission message to the game
+func (r *GameRoom) Submit(playerID int, payload []byte) {
+	r.send(roomCommand{kind: cmdSubmit, playerID: playerID, payload: payload})
+}
+
+// Watch attaches a read-only spectator connection
+func (r *GameRoom) Watch(spectatorID int, conn *Connection) {
+	r.send(roomCommand{kind: cmdWatch, playerID: spectatorID, conn: conn})
+}
+
+// Unwatch detaches a spectator connection
+func (r *GameRoom) Unwatch(conn *Connection) {
+	r.send(roomCommand{kind: cmdUnwatch, conn: conn})
+}
+
+// ReadyForNewGame records a player's answer to a rematch after the summary
+func (r *GameRoom) ReadyForNewGame(playerID int, ready bool) {
+	r.send(roomCommand{kind: cmdReadyForNewGame, playerID: playerID, ready: ready})
+}
+
+// Drain lets the game in play finish but no more after it, and ends it if
+// it is still going at the deadline. It reports false if the room has
+// finished or didn't take the command before the deadline.
+func (r *GameRoom) Drain(deadline time.Time) bool {
+	return r.sendBefore(roomCommand{kind: cmdDrain, deadline: deadline}, time.After(time.Until(deadline)))
+}
+
+// Inspect reports the room's state for the admin API. A room that doesn't
+// answer in time, or has finished, is described from its seats alone.
+func (r *GameRoom) Inspect(timeout time.Duration) net.AdminGameRoom {
+	expired := time.After(timeout)
+	reply := make(chan net.AdminGameRoom, 1)
+	if r.sendBefore(roomCommand{kind: cmdInspect, reply: reply}, expired) {
+		select {
+		case info := <-reply:
+			return info
+		case <-r.done:
+		case <-expired:
+		}
+	}
+
+	info := net.AdminGameRoom{
+		ID:         r.ID,
+		RoomCode:   r.RoomCode,
+		GameType:   r.GameType,
+		Tournament: r.opts.Tournament,
+		Ended:      r.Ended(),
+	}
+	for _, seat := range r.seats {
+		info.Players = append(info.Players, net.AdminPlayer{ID: seat.PlayerID, Name: seat.Name})
+	}
+	return info
+}
+
+// ForceEnd ends the game and sends everyone back to the lobby. It reports
+// false if the room has finished or didn't take the command in time.
+func (r *GameRoom) ForceEnd(timeout time.Duration) bool {
+	return r.sendBefore(roomCommand{kind: cmdForceEnd}, time.After(timeout))
+}
+
+// send queues a command, dropping it if the room has already finished
+func (r *GameRoom) send(cmd roomCommand) bool {
+	select {
+	case r.cmds <- cmd:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// sendBefore is send that also gives up once expired fires
+func (r *GameRoom) sendBefore(cmd roomCommand, expired <-chan time.Time) bool {
+	select {
+	case r.cmds <- cmd:
+		return true
+	case <-r.done:
+		return false
+	case <-expired:
+		return false
+	}
+}
+
+// run is the room's goroutine. It exits once the game has ended.
+func (r *GameRoom) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.tickInterval())
+	defer ticker.Stop()
+
+	// Wait for the players to reconnect after redirecting
+	next := time.NewTimer(r.opts.Settings.StartDelay)
+	defer next.Stop()
+
+	r.logger.Info("Game room started")
+
+	for !r.game.Ended() {
+		select {
+		case cmd := <-r.cmds:
+			r.handle(cmd, next)
+		case <-ticker.C:
+			r.lastTick.Store(time.Now().UnixNano())
+			r.handle(roomCommand{kind: cmdTick}, next)
+		case <-next.C:
+			r.advance(next)
+		case <-r.drainDeadline:
+			r.logger.Info("Ending game room - server is shutting down")
+			r.game.End()
+		}
+	}
+
+	r.logger.Info("Game room finished")
+}
+
+func (r *GameRoom) handle(cmd roomCommand, next *time.Timer) {
+	start := time.Now()
+	defer func() { roomCommandSeconds.Observe(time.Since(start).Seconds()) }()
+
+	switch cmd.kind {
+	case cmdJoin:
+		r.conns[cmd.playerID] = cmd.conn
+		r.game.SetConnected(cmd.playerID, true)
+		cmd.conn.SendGameWelcome(cmd.playerID, r.ID, rulesMessage(r.opts.Rules), r.opts.Tournament, r.reconnectToken(cmd.playerID))
+		if r.awaitingRematch() {
+			// Reloaded the summary page
+			if summaryMsg := r.game.SummaryMessage(); summaryMsg != nil {
+				cmd.conn.SendMessage(summaryMsg)
+			}
+			if r.offersRematch() {
+				cmd.conn.SendMessage(r.rematchStatus())
+			}
+		} else {
+			cmd.conn.SendMessage(r.game.StateMessage())
+		}
+		r.sendSeriesProgress(cmd.conn)
+		r.logger.Info("Player joined game room", logging.PlayerID(cmd.playerID))
+
+	case cmdLeave:
+		if r.conns[cmd.playerID] != cmd.conn {
+			return
+		}
+		delete(r.conns, cmd.playerID)
+		wasPlaying := r.game.Phase() == "playing"
+		r.game.SetConnected(cmd.playerID, false)
+
+		// Only end the game once it has actually started (not in "waiting" or "ready" state)
+		// This allows players to reconnect after redirect without the room being prematurely ended
+		if len(r.conns) == 0 {
+			if phase := r.game.Phase(); phase != "waiting" && phase != "ready" {
+				r.logger.Info("Ending game room - all players disconnected", "phase", phase)
+				r.game.End()
+			} else {
+				r.logger.Debug("Not ending game room - waiting for reconnection", "phase", phase)
+			}
+			return
+		}
+		// Everyone still connected may already have submitted
+		if wasPlaying && r.game.Phase() == "results" {
+			r.broadcastState()
+			r.finishRound(next)
+		}
+
+	case cmdSubmit:
+		if r.game.Phase() != "playing" {
+			return
+		}
+		if !r.game.Submit(cmd.playerID, cmd.payload) {
+			return
+		}
+		r.broadcastState()
+		if r.game.Phase() == "results" {
+			r.finishRound(next)
+		}
+
+	case cmdWatch:
+		r.spectators[cmd.conn] = struct{}{}
+		cmd.conn.SendGameWelcome(cmd.playerID, r.ID, rulesMessage(r.opts.Rules), r.opts.Tournament, "")
+		if r.awaitingRematch() {
+			if summaryMsg := r.game.SummaryMessage(); summaryMsg != nil {
+				cmd.conn.SendMessage(summaryMsg)
+			}
+		} else {
+			cmd.conn.SendMessage(r.game.SpectatorStateMessage())
+		}
+		r.sendSeriesProgress(cmd.conn)
+		r.logger.Info("Spectator watching game room", logging.PlayerID(cmd.playerID), "spectators", len(r.spectators))
+
+	case cmdUnwatch:
+		delete(r.spectators, cmd.conn)
+
+	case cmdReadyForNewGame:
+		if !r.awaitingRematch() || !r.offersRematch() {
+			return
+		}
+		if !r.game.SetReadyForNewGame(cmd.playerID, cmd.ready) {
+			return
+		}
+		if !cmd.ready {
+			r.logger.Info("Player declined a rematch", logging.PlayerID(cmd.playerID))
+			r.returnToLobby()
+			return
+		}
+		r.logger.Info("Player is ready for a rematch", logging.PlayerID(cmd.playerID))
+		if r.game.AllReadyForNewGame() {
+			r.startRematch(next)
+			return
+		}
+		r.broadcast(r.rematchStatus())
+
+	case cmdDrain:
+		r.draining = true
+		r.drainDeadline = time.After(time.Until(cmd.deadline))
+		r.broadcast(shutdownMessage(cmd.deadline))
+		if r.awaitingRematch() {
+			// The game is already over and saved
+			r.game.End()
+		}
+
+	case cmdInspect:
+		cmd.reply <- r.adminInfo()
+
+	case cmdForceEnd:
+		r.logger.Warn("Admin ended game room")
+		r.returnToLobby()
+
+	case cmdTick:
+		// Keep rebroadcasting while players may be reconnecting or still playing
+		switch r.game.Phase() {
+		case "waiting", "ready", "playing":
+			r.broadcastState()
+		}
+	}
+}
+
+// advance runs when the room's timer fires: it either expires the round in
+// play or starts the next one
+func (r *GameRoom) advance(next *time.Timer) {
+	if r.awaitingRematch() {
+		if series := r.opts.Series; series != nil && !series.Last() {
+			// The matchmaker starts the next game once this room has finished
+			series.moveOn()
+			r.logger.Info("Party series moving on", "next", series.Current())
+			r.game.End()
+			return
+		}
+		if r.offersRematch() {
+			r.logger.Info("Rematch offer expired")
+		}
+		r.returnToLobby()
+		return
+	}
+
+	if r.game.Phase() == "playing" {
+		r.logger.Info("Round timed out", "round", r.round)
+		r.game.ExpireRound()
+		r.broadcastState()
+		r.finishRound(next)
+		return
+	}
+
+	if len(r.conns) == 0 {
+		r.logger.Info("Ending game room - no active connections")
+		r.game.End()
+		return
+	}
+
+	if r.game.Phase() == "results" {
+		r.game.NextRound()
+	}
+	if r.opts.Rules.SuddenDeathRound(r.round) {
+		r.logger.Info("Scores tied - playing a sudden death round", "round", r.round+1)
+	}
+	if r.round == 0 {
+		r.startedAt = time.Now()
+		gamesStarted.With(r.GameType).Inc()
+	}
+	r.round++
+	r.game.StartRound(r.opts.Settings.RoundTimeLimit)
+	r.logger.Info("Started round", "round", r.round, "rules", r.opts.Rules.String())
+	r.broadcastState()
+
+	// Wake up at the deadline so a missing submission can't stall the room
+	if deadline := r.game.Deadline(); !deadline.IsZero() {
+		resetTimer(next, time.Until(deadline))
+	}
+}
+
+// finishRound either sends the summary once the match rules say the game
+// is over or schedules the next round
+func (r *GameRoom) finishRound(next *time.Timer) {
+	r.logger.Debug("Round complete", "round", r.round)
+	if r.opts.Rules.Over(r.round, r.game.Scores()) {
+		r.logger.Info("Game complete", "rounds", r.round, "rules", r.opts.Rules.String())
+		gamesFinished.With(r.GameType).Inc()
+		summary := r.game.GetGameSummary()
+		if summary != nil && r.opts.Ratings != nil {
+			r.game.SetRatings(r.opts.Ratings.Record(r.GameType, summary))
+		}
+		r.sendSummary()
+		if summary != nil && r.opts.OnFinish != nil {
+			r.opts.OnFinish(r, summary, r.startedAt)
+		}
+		if r.draining {
+			r.logger.Info("Not waiting for a rematch - server is shutting down")
+			r.game.End()
+			return
+		}
+
+		if series := r.opts.Series; series != nil {
+			if summary != nil {
+				series.Record(summary)
+			}
+			r.broadcast(series.StatusMessage())
+			if series.Last() {
+				r.logger.Info("Party series complete")
+				r.broadcast(series.SummaryMessage())
+				r.rematchDeadline = time.Now().Add(r.opts.Settings.RematchWait)
+				resetTimer(next, r.opts.Settings.RematchWait)
+				return
+			}
+		}
+
+		if !r.offersRematch() {
+			// Show the summary, then head to the bracket or the series' next game
+			r.rematchDeadline = time.Now().Add(r.opts.Settings.SummaryWait)
+			resetTimer(next, r.opts.Settings.SummaryWait)
+			return
+		}
+
+		// Keep the room open so the players can ask for a rematch
+		r.rematchDeadline = time.Now().Add(r.opts.Settings.RematchWait)
+		r.broadcast(r.rematchStatus())
+		resetTimer(next, r.opts.Settings.RematchWait)
+		return
+	}
+	resetTimer(next, r.opts.Settings.ResultsDelay)
+}
+
+func (r *GameRoom) awaitingRematch() bool {
+	return !r.rematchDeadline.IsZero()
+}
+
+// offersRematch reports whether players may replay the game once it ends.
+// A tournament match is decided by a single game, and a party series moves
+// on to its next game instead.
+func (r *GameRoom) offersRematch() bool {
+	return r.opts.Tournament == "" && r.opts.Series == nil
+}
+
+// sendSeriesProgress tells a joining connection where the party series stands,
+// along with the series summary if it is over
+func (r *GameRoom) sendSeriesProgress(conn *Connection) {
+	series := r.opts.Series
+	if series == nil {
+		return
+	}
+	conn.SendMessage(series.StatusMessage())
+	if r.awaitingRematch() && series.Last() {
+		conn.SendMessage(series.SummaryMessage())
+	}
+}
+
+// startRematch replays the game in the same room with the same players
+func (r *GameRoom) startRematch(next *time.Timer) {
+	r.logger.Info("Starting rematch")
+	r.rematchDeadline = time.Time{}
+	r.round = 0
+	r.game.ResetGame()
+	r.broadcastState()
+	resetTimer(next, r.opts.Settings.StartDelay)
+}
+
+// returnToLobby ends the game and sends everyone back to their room code's
+// lobby, or to the bracket for a tournament match
+func (r *GameRoom) returnToLobby() {
+	url := "/lobby.html"
+	if r.opts.Tournament != "" {
+		url = "/tournament.html?code=" + r.opts.Tournament
+	}
+	r.broadcast(net.RedirectMessage{
+		Type: "redirect",
+		URL:  url,
+	})
+	r.game.End()
+}
+
+// adminInfo describes the room for the admin API
+func (r *GameRoom) adminInfo() net.AdminGameRoom {
+	info := net.AdminGameRoom{
+		ID:         r.ID,
+		RoomCode:   r.RoomCode,
+		GameType:   r.GameType,
+		Phase:      r.game.Phase(),
+		Round:      r.round,
+		Tournament: r.opts.Tournament,
+		Spectators: len(r.spectators),
+		Ended:      r.game.Ended(),
+		Responding: true,
+	}
+	if !r.startedAt.IsZero() {
+		startedAt := r.startedAt
+		info.StartedAt = &startedAt
+	}
+	for _, seat := range r.seats {
+		info.Players = append(info.Players, net.AdminPlayer{
+			ID:        seat.PlayerID,
+			Name:      seat.Name,
+			Connected: r.conns[seat.PlayerID] != nil,
+		})
+	}
+	return info
+}
+
+func (r *GameRoom) rematchStatus() net.RematchStatusMessage {
+	msg := net.RematchStatusMessage{
+		Type:        "rematchStatus",
+		ExpiresInMs: time.Until(r.rematchDeadline).Milliseconds(),
+	}
+	for _, seat := range r.seats {
+		msg.ReadyStatus = append(msg.ReadyStatus, net.ReadyStatus{
+			PlayerID: seat.PlayerID,
+			Ready:    r.game.IsReadyForNewGame(seat.PlayerID),
+		})
+	}
+	return msg
+}
+
+// resetTimer re-arms t, draining a pending fire so it can't be seen twice
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+func (r *GameRoom) sendSummary() {
+	summaryMsg := r.game.SummaryMessage()
+	if summaryMsg == nil {
+		r.logger.Error("No game summary")
+		return
+	}
+	r.logger.Debug("Sending game summary", "connections", len(r.conns))
+	r.broadcast(summaryMsg)
+}
+
+// broadcastState sends the current state to players, and the spectator
+// version of it to spectators
+func (r *GameRoom) broadcastState() {
+	stateMsg := r.game.StateMessage()
+	for _, conn := range r.conns {
+		conn.SendMessage(stateMsg)
+	}
+	if len(r.spectators) > 0 {
+		spectatorMsg := r.game.SpectatorStateMessage()
+		for conn := range r.spectators {
+			conn.SendMessage(spectatorMsg)
+		}
+	}
+}
+
+// broadcast sends a message to players and spectators alike
+func (r *GameRoom) broadcast(v interface{}) {
+	for _, conn := range r.conns {
+		conn.SendMessage(v)
+	}
+	for conn := range r.spectators {
+		conn.SendMessage(v)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"GoServerGames/internal/config"
+	"GoServerGames/internal/game"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// testConn is a connection the room can queue messages on without a socket
+func testConn(roomCode string) *Connection {
+	return &Connection{
+		send:    make(chan []byte, 256),
+		session: &Session{RoomCode: roomCode},
+		done:    make(chan struct{}),
+	}
+}
+
+// startedSpeedType is a two player speedtype room in its first round, with
+// no round time limit so only submissions can end the round
+func startedSpeedType(t *testing.T) (*GameRoom, *game.SpeedTypeRoom, map[int]*Connection, *time.Timer) {
+	t.Helper()
+	g, err := game.New("speedtype", "room-1", "ABCD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := map[int]*Connection{1: testConn("ABCD"), 2: testConn("ABCD")}
+	players := []*LobbyPlayer{
+		{PlayerID: 1, Name: "alice", Conn: conns[1]},
+		{PlayerID: 2, Name: "bob", Conn: conns[2]},
+	}
+	r := NewGameRoom(g, players, RoomOptions{
+		Settings: config.Game{TickRate: 2, ResultsDelay: time.Hour, RematchWait: time.Hour, SummaryWait: time.Hour},
+		Rules:    game.DefaultMatchRules(3),
+	})
+	next := time.NewTimer(time.Hour)
+	t.Cleanup(func() { next.Stop() })
+
+	r.advance(next)
+	if r.game.Phase() != "playing" {
+		t.Fatalf("phase after starting = %q, want playing", r.game.Phase())
+	}
+	return r, g.(*game.SpeedTypeRoom), conns, next
+}
+
+func submitWord(t *testing.T, r *GameRoom, st *game.SpeedTypeRoom, playerID int, next *time.Timer) {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]interface{}{"type": "speedTypeSubmit", "word": st.CurrentWord, "timeMs": 500})
+	r.handle(roomCommand{kind: cmdSubmit, playerID: playerID, payload: payload}, next)
+}
+
+func TestLeaveEndsRoundWaitingOnlyOnLeaver(t *testing.T) {
+	r, st, conns, next := startedSpeedType(t)
+
+	submitWord(t, r, st, 1, next)
+	if r.game.Phase() != "playing" {
+		t.Fatalf("phase after one of two submissions = %q, want playing", r.game.Phase())
+	}
+
+	r.handle(roomCommand{kind: cmdLeave, playerID: 2, conn: conns[2]}, next)
+	if r.game.Phase() != "results" {
+		t.Fatalf("phase after the last player yet to submit left = %q, want results", r.game.Phase())
+	}
+	if st.RoundTimedOut {
+		t.Error("round marked as timed out, want ended by submissions")
+	}
+	if st.RoundWinner != 1 {
+		t.Errorf("round winner = %d, want 1", st.RoundWinner)
+	}
+	if r.game.Ended() {
+		t.Error("game ended with a player still connected")
+	}
+}
+
+func TestLeaveKeepsRoundWithSubmissionsOutstanding(t *testing.T) {
+	r, _, conns, next := startedSpeedType(t)
+
+	r.handle(roomCommand{kind: cmdLeave, playerID: 2, conn: conns[2]}, next)
+	if r.game.Phase() != "playing" {
+		t.Errorf("phase after a player left with no submissions = %q, want playing", r.game.Phase())
+	}
+
+	r.handle(roomCommand{kind: cmdLeave, playerID: 1, conn: conns[1]}, next)
+	if !r.game.Ended() {
+		t.Error("game still running after every player left")
+	}
+	if r.round != 1 || len(r.game.(*game.SpeedTypeRoom).RoundHistory) != 0 {
+		t.Error("round was scored after every player left")
+	}
+}
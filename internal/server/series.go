@@ -0,0 +1,150 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/net"
+	"fmt"
+	"sort"
+)
+
+// MaxPlaylistGames caps how many games a party series can chain
+const MaxPlaylistGames = 10
+
+// Series is a party playlist: the same players play several minigames back
+// to back without going through the lobby, and the final standings of every
+// game add up to a series score. Each game gets its own game room; only one
+// runs at a time and it owns the series until it finishes.
+type Series struct {
+	Playlist   []string
+	current    int // Index into Playlist of the game being played
+	games      []net.SeriesGame
+	standings  []*net.SeriesStanding // In seat order
+	continuing bool                  // Set when a room ends so the next game can start
+}
+
+// validatePlaylist checks a party playlist only names registered games
+func validatePlaylist(playlist []string) error {
+	if len(playlist) < 2 || len(playlist) > MaxPlaylistGames {
+		return fmt.Errorf("a party series needs between 2 and %d games", MaxPlaylistGames)
+	}
+	for _, gameType := range playlist {
+		if !game.IsRegistered(gameType) {
+			return fmt.Errorf("unknown game type: %s", gameType)
+		}
+	}
+	return nil
+}
+
+func newSeries(playlist []string, players []*LobbyPlayer) *Series {
+	s := &Series{Playlist: playlist}
+	for _, lp := range players {
+		s.standings = append(s.standings, &net.SeriesStanding{PlayerID: lp.PlayerID, Name: lp.Name})
+	}
+	return s
+}
+
+// Current returns the game type being played
+func (s *Series) Current() string {
+	return s.Playlist[s.current]
+}
+
+// Last reports whether the current game is the last of the playlist
+func (s *Series) Last() bool {
+	return s.current == len(s.Playlist)-1
+}
+
+// Record adds a finished game to the series score. Every player scores a
+// point for each player they finished ahead of, as they do in a round.
+func (s *Series) Record(summary *game.GameSummary) {
+	players := make([]net.PlayerStanding, len(summary.Standings))
+	for i, standing := range summary.Standings {
+		players[i] = net.PlayerStanding{
+			PlayerID:  standing.PlayerID,
+			Name:      standing.Name,
+			Score:     standing.Score,
+			AvgTimeMs: standing.AvgTimeMs,
+			Place:     standing.Place,
+		}
+
+		behind := 0
+		for _, other := range summary.Standings {
+			if other.Place > standing.Place {
+				behind++
+			}
+		}
+		for _, total := range s.standings {
+			if total.PlayerID == standing.PlayerID {
+				total.Points += behind
+				if standing.PlayerID == summary.WinnerID {
+					total.GamesWon++
+				}
+			}
+		}
+	}
+	s.games = append(s.games, net.SeriesGame{
+		GameType: s.Current(),
+		WinnerID: summary.WinnerID,
+		Players:  players,
+	})
+}
+
+// moveOn hands the series to the next game once the current room has ended
+func (s *Series) moveOn() {
+	s.current++
+	s.continuing = true
+}
+
+// takeNext returns the game to start after a room of the series ended, or
+// false if the room ended for good, e.g. because everyone left
+func (s *Series) takeNext() (string, bool) {
+	if !s.continuing {
+		return "", false
+	}
+	s.continuing = false
+	return s.Current(), true
+}
+
+// ranked returns the series standings ordered by points, with shared places
+// on a tie, and the leader's ID if nobody shares first place
+func (s *Series) ranked() ([]net.SeriesStanding, int) {
+	ranked := make([]net.SeriesStanding, len(s.standings))
+	for i, standing := range s.standings {
+		ranked[i] = *standing
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Points > ranked[j].Points
+	})
+	for i := range ranked {
+		if i > 0 && ranked[i].Points == ranked[i-1].Points {
+			ranked[i].Place = ranked[i-1].Place
+		} else {
+			ranked[i].Place = i + 1
+		}
+	}
+
+	leaderID := 0
+	if len(ranked) == 1 || (len(ranked) > 1 && ranked[1].Place > 1) {
+		leaderID = ranked[0].PlayerID
+	}
+	return ranked, leaderID
+}
+
+func (s *Series) StatusMessage() net.SeriesStatusMessage {
+	players, _ := s.ranked()
+	return net.SeriesStatusMessage{
+		Type:     "seriesStatus",
+		Playlist: s.Playlist,
+		Game:     s.current,
+		Players:  players,
+	}
+}
+
+func (s *Series) SummaryMessage() net.SeriesSummaryMessage {
+	players, winnerID := s.ranked()
+	return net.SeriesSummaryMessage{
+		Type:     "seriesSummary",
+		Players:  players,
+		WinnerID: winnerID,
+		Games:    s.games,
+	}
+}
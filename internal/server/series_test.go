@@ -0,0 +1,147 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/net"
+	"reflect"
+	"testing"
+)
+
+// seriesPlayers seats alice, bob and carol as players 1 to 3
+func seriesPlayers() []*LobbyPlayer {
+	return []*LobbyPlayer{
+		{PlayerID: 1, Name: "alice"},
+		{PlayerID: 2, Name: "bob"},
+		{PlayerID: 3, Name: "carol"},
+	}
+}
+
+// placed is a game summary with the players at the given places, keyed by
+// player ID; the winner is whoever alone has first place
+func placed(places map[int]int) *game.GameSummary {
+	names := map[int]string{1: "alice", 2: "bob", 3: "carol"}
+	summary := &game.GameSummary{}
+	firsts := 0
+	for place := 1; place <= len(places); place++ {
+		for _, id := range []int{1, 2, 3} {
+			if p, ok := places[id]; ok && p == place {
+				summary.Standings = append(summary.Standings, game.Standing{PlayerID: id, Name: names[id], Place: place})
+				if place == 1 {
+					firsts++
+					summary.WinnerID = id
+				}
+			}
+		}
+	}
+	if firsts > 1 {
+		summary.WinnerID = 0
+	}
+	return summary
+}
+
+func TestValidatePlaylist(t *testing.T) {
+	tests := []struct {
+		playlist []string
+		ok       bool
+	}{
+		{[]string{"speedtype", "mathsprint"}, true},
+		{[]string{"speedtype", "speedtype", "clickspeed"}, true},
+		{[]string{"speedtype"}, false},
+		{[]string{"speedtype", "chess"}, false},
+		{make([]string, MaxPlaylistGames+1), false},
+	}
+	for _, tt := range tests {
+		if err := validatePlaylist(tt.playlist); (err == nil) != tt.ok {
+			t.Errorf("validatePlaylist(%v) = %v, want ok %v", tt.playlist, err, tt.ok)
+		}
+	}
+}
+
+func TestSeriesAdvance(t *testing.T) {
+	s := newSeries([]string{"speedtype", "mathsprint", "clickspeed"}, seriesPlayers())
+
+	if _, ok := s.takeNext(); ok {
+		t.Error("takeNext before any game finished = true, want false")
+	}
+	for i, want := range s.Playlist {
+		if got := s.Current(); got != want {
+			t.Fatalf("game %d: Current() = %q, want %q", i+1, got, want)
+		}
+		if got := s.Last(); got != (i == len(s.Playlist)-1) {
+			t.Errorf("game %d: Last() = %v", i+1, got)
+		}
+		if status := s.StatusMessage(); status.Game != i {
+			t.Errorf("game %d: status game index = %d, want %d", i+1, status.Game, i)
+		}
+		if s.Last() {
+			break
+		}
+
+		s.moveOn()
+		next, ok := s.takeNext()
+		if !ok || next != s.Playlist[i+1] {
+			t.Fatalf("takeNext after game %d = %q, %v, want %q, true", i+1, next, ok, s.Playlist[i+1])
+		}
+		if _, ok := s.takeNext(); ok {
+			t.Errorf("takeNext twice after game %d = true, want false", i+1)
+		}
+	}
+}
+
+func TestSeriesStandings(t *testing.T) {
+	s := newSeries([]string{"speedtype", "mathsprint", "clickspeed"}, seriesPlayers())
+
+	s.Record(placed(map[int]int{1: 1, 2: 2, 3: 3}))
+	s.moveOn()
+	s.takeNext()
+	s.Record(placed(map[int]int{3: 1, 2: 2, 1: 3}))
+	s.moveOn()
+	s.takeNext()
+	// alice and carol tie for second, ahead of nobody
+	s.Record(placed(map[int]int{2: 1, 1: 2, 3: 2}))
+
+	summary := s.SummaryMessage()
+	want := []net.SeriesStanding{
+		{PlayerID: 2, Name: "bob", Points: 4, GamesWon: 1, Place: 1},
+		{PlayerID: 1, Name: "alice", Points: 2, GamesWon: 1, Place: 2},
+		{PlayerID: 3, Name: "carol", Points: 2, GamesWon: 1, Place: 2},
+	}
+	if !reflect.DeepEqual(summary.Players, want) {
+		t.Errorf("standings = %+v, want %+v", summary.Players, want)
+	}
+	if summary.WinnerID != 2 {
+		t.Errorf("series winner = %d, want 2", summary.WinnerID)
+	}
+
+	var gameTypes []string
+	var winners []int
+	for _, g := range summary.Games {
+		gameTypes = append(gameTypes, g.GameType)
+		winners = append(winners, g.WinnerID)
+	}
+	if !reflect.DeepEqual(gameTypes, s.Playlist) {
+		t.Errorf("games recorded = %v, want %v", gameTypes, s.Playlist)
+	}
+	if !reflect.DeepEqual(winners, []int{1, 3, 2}) {
+		t.Errorf("game winners = %v, want [1 3 2]", winners)
+	}
+}
+
+func TestSeriesTiedForFirst(t *testing.T) {
+	s := newSeries([]string{"speedtype", "mathsprint"}, seriesPlayers()[:2])
+
+	s.Record(placed(map[int]int{1: 1, 2: 2}))
+	s.moveOn()
+	s.takeNext()
+	s.Record(placed(map[int]int{2: 1, 1: 2}))
+
+	summary := s.SummaryMessage()
+	if summary.WinnerID != 0 {
+		t.Errorf("series winner with a tied total = %d, want 0", summary.WinnerID)
+	}
+	for _, standing := range summary.Players {
+		if standing.Place != 1 || standing.Points != 1 {
+			t.Errorf("%s: place %d with %d points, want shared first with 1", standing.Name, standing.Place, standing.Points)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"GoServerGames/internal/logging"
+	"GoServerGames/internal/net"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// shutdownGrace is how long after the drain deadline Shutdown still waits
+// for rooms to wind down and save their results
+const shutdownGrace = 5 * time.Second
+
+func shutdownMessage(deadline time.Time) net.ServerShutdownMessage {
+	return net.ServerShutdownMessage{
+		Type:     "serverShutdown",
+		Deadline: deadline,
+		InMs:     time.Until(deadline).Milliseconds(),
+	}
+}
+
+// Draining reports whether the server is shutting down and no longer
+// starting lobbies or games
+func (m *Matchmaking) Draining() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.drainDeadline.IsZero()
+}
+
+// Shutdown stops new lobbies, quick matches and games, warns everyone with
+// a serverShutdown message, and gives the games already running until the
+// timeout to finish. It returns once they have ended and their results are
+// saved, and stops the matchmaker's loops.
+func (m *Matchmaking) Shutdown(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	msg := shutdownMessage(deadline)
+
+	m.mu.Lock()
+	m.drainDeadline = deadline
+	for _, lobby := range m.lobbies {
+		lobby.Broadcast(msg)
+	}
+	for _, e := range m.queue {
+		e.conn.SendMessage(msg)
+	}
+	m.queue = nil
+
+	var rooms []*GameRoom
+	for _, room := range m.gameRooms {
+		if !room.Ended() {
+			rooms = append(rooms, room)
+		}
+	}
+	m.mu.Unlock()
+
+	// A busy room can take a while to accept the command, so don't hold the lock
+	for _, room := range rooms {
+		if !room.Drain(deadline) && !room.Ended() {
+			slog.Warn("Shutting down: room didn't take the drain before the deadline", logging.RoomCode(room.RoomCode), logging.RoomID(room.ID), logging.GameType(room.GameType))
+		}
+	}
+
+	slog.Info("Shutting down: waiting for running games", "timeout", timeout.String(), "games", len(rooms))
+	ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(shutdownGrace))
+	defer cancel()
+	for _, room := range rooms {
+		select {
+		case <-room.done:
+		case <-ctx.Done():
+			slog.Warn("Shutting down: room still hasn't finished", logging.RoomCode(room.RoomCode), logging.RoomID(room.ID), logging.GameType(room.GameType))
+		}
+	}
+
+	// Finished games are saved off the room goroutines
+	m.saves.Wait()
+	close(m.stop)
+	slog.Info("Shutting down: all games drained")
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/net"
+	"GoServerGames/internal/storage"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// How many of a player's latest games their stats list
+const recentMatchLimit = 10
+
+// ErrNoMatchStore is returned for stats when no match history is kept
+var ErrNoMatchStore = fmt.Errorf("no match history is kept")
+
+// gameTally adds up one player's games of one game type
+type gameTally struct {
+	record net.GameRecord
+	times  []float64 // Every submitted round time
+}
+
+func (t *gameTally) add(record *storage.MatchRecord, name string) {
+	playerID := 0
+	for _, p := range record.Players {
+		if p.Name == name {
+			playerID = p.PlayerID
+		}
+	}
+
+	t.record.Games++
+	switch record.WinnerID {
+	case playerID:
+		t.record.Wins++
+	case 0:
+		t.record.Draws++
+	default:
+		t.record.Losses++
+	}
+	for _, round := range record.Rounds {
+		for _, result := range round.Results {
+			if result.PlayerID == playerID && result.TimeMs > 0 {
+				t.times = append(t.times, result.TimeMs)
+			}
+		}
+	}
+}
+
+// finish works out the time stats once every game has been added
+func (t *gameTally) finish(rating int) net.GameRecord {
+	t.record.Rating = rating
+	if len(t.times) == 0 {
+		return t.record
+	}
+
+	sort.Float64s(t.times)
+	total := 0.0
+	for _, ms := range t.times {
+		total += ms
+	}
+	t.record.Times = net.TimeStats{
+		Rounds: len(t.times),
+		BestMs: t.times[0],
+		AvgMs:  total / float64(len(t.times)),
+		P50Ms:  percentile(t.times, 50),
+		P90Ms:  percentile(t.times, 90),
+		P99Ms:  percentile(t.times, 99),
+	}
+	return t.record
+}
+
+// percentile returns the nearest-rank percentile of sorted times
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func (m *Matchmaking) matchStore() (storage.MatchStore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		return nil, ErrNoMatchStore
+	}
+	return m.store, nil
+}
+
+// Leaderboard ranks everyone who has played the given game type by rating
+func (m *Matchmaking) Leaderboard(gameType string) (*net.Leaderboard, error) {
+	if !game.IsRegistered(gameType) {
+		return nil, fmt.Errorf("unknown game type: %s", gameType)
+	}
+	store, err := m.matchStore()
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.Matches(storage.MatchFilter{GameType: gameType})
+	if err != nil {
+		return nil, err
+	}
+
+	tallies := make(map[string]*gameTally)
+	for i := range records {
+		for _, p := range records[i].Players {
+			tally := tallies[p.Name]
+			if tally == nil {
+				tally = &gameTally{record: net.GameRecord{GameType: gameType}}
+				tallies[p.Name] = tally
+			}
+			tally.add(&records[i], p.Name)
+		}
+	}
+
+	board := &net.Leaderboard{GameType: gameType, Players: make([]net.LeaderboardEntry, 0, len(tallies))}
+	for name, tally := range tallies {
+		board.Players = append(board.Players, net.LeaderboardEntry{
+			Name:       name,
+			GameRecord: tally.finish(m.ratings.For(name)[gameType]),
+		})
+	}
+	sort.Slice(board.Players, func(i, j int) bool {
+		a, b := board.Players[i], board.Players[j]
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Name < b.Name
+	})
+	for i := range board.Players {
+		board.Players[i].Rank = i + 1
+	}
+	return board, nil
+}
+
+// PlayerStats returns a player's record in every game type they have played
+// along with their latest games. It reports false if they haven't played.
+func (m *Matchmaking) PlayerStats(name string) (*net.PlayerStats, bool, error) {
+	store, err := m.matchStore()
+	if err != nil {
+		return nil, false, err
+	}
+	records, err := store.Matches(storage.MatchFilter{Player: name})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(records) == 0 {
+		return nil, false, nil
+	}
+
+	tallies := make(map[string]*gameTally)
+	for i := range records {
+		tally := tallies[records[i].GameType]
+		if tally == nil {
+			tally = &gameTally{record: net.GameRecord{GameType: records[i].GameType}}
+			tallies[records[i].GameType] = tally
+		}
+		tally.add(&records[i], name)
+	}
+
+	ratings := m.ratings.For(name)
+	stats := &net.PlayerStats{Name: name}
+	for _, gameType := range game.Types() {
+		if tally := tallies[gameType]; tally != nil {
+			stats.Games = append(stats.Games, tally.finish(ratings[gameType]))
+		}
+	}
+
+	for i := len(records) - 1; i >= 0 && len(stats.RecentMatches) < recentMatchLimit; i-- {
+		record := &records[i]
+		recent := net.RecentMatch{
+			ID:       record.ID,
+			GameType: record.GameType,
+			RoomCode: record.RoomCode,
+			EndedAt:  record.EndedAt,
+			Players:  len(record.Players),
+			Winner:   record.WinnerName(),
+		}
+		for _, p := range record.Players {
+			if p.Name == name {
+				recent.Place = p.Place
+				recent.Score = p.Score
+				recent.AvgTimeMs = p.AvgTimeMs
+			}
+		}
+		stats.RecentMatches = append(stats.RecentMatches, recent)
+	}
+	return stats, true, nil
+}
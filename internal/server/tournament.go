@@ -0,0 +1,404 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"GoServerGames/internal/logging"
+	"GoServerGames/internal/net"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// MaxTournamentPlayers caps the size of a bracket
+const MaxTournamentPlayers = 64
+
+// Tournament is a single-elimination bracket for one minigame. Every match
+// gets its own room code, so it is played in an ordinary lobby and game
+// room, and the winner of the game summary moves on to the next round.
+type Tournament struct {
+	Code     string
+	GameType string
+	Rules    game.MatchRules
+	Host     string
+	Rounds   [][]*TournamentMatch // First round first, the final last
+	Champion string
+	seeds    map[string]int // 1 is the top seed, in the order players were entered
+}
+
+// TournamentMatch is one pairing in a bracket
+type TournamentMatch struct {
+	Tournament *Tournament
+	Round      int // 0 is the first round
+	Index      int // Position within the round; matches 2i and 2i+1 feed match i of the next round
+	RoomCode   string
+	Players    [2]string // "" for a bye or a seat still waiting on an earlier match
+	Winner     string
+	RoomID     string // Game room of the latest attempt at the match
+}
+
+// newTournament seeds the players into a bracket in the order given. When
+// the field isn't a power of two the top seeds get a bye into round two.
+func newTournament(code, gameType, host string, players []string, rules game.MatchRules) (*Tournament, error) {
+	if len(players) < 2 || len(players) > MaxTournamentPlayers {
+		return nil, fmt.Errorf("a tournament needs between 2 and %d players", MaxTournamentPlayers)
+	}
+
+	// A tie can't knock anyone out, so matches always go to sudden death
+	rules.SuddenDeath = true
+
+	t := &Tournament{
+		Code:     code,
+		GameType: gameType,
+		Rules:    rules,
+		Host:     host,
+		seeds:    make(map[string]int),
+	}
+	for i, name := range players {
+		if name == "" {
+			return nil, fmt.Errorf("player names cannot be empty")
+		}
+		if _, dup := t.seeds[name]; dup {
+			return nil, fmt.Errorf("%s is entered twice", name)
+		}
+		t.seeds[name] = i + 1
+	}
+
+	size := 2
+	for size < len(players) {
+		size *= 2
+	}
+	order := seedOrder(size)
+	for round := 0; size>>(round+1) > 0; round++ {
+		matches := make([]*TournamentMatch, size>>(round+1))
+		for i := range matches {
+			matches[i] = &TournamentMatch{
+				Tournament: t,
+				Round:      round,
+				Index:      i,
+				RoomCode:   fmt.Sprintf("%sR%dM%d", code, round+1, i+1),
+			}
+		}
+		t.Rounds = append(t.Rounds, matches)
+	}
+
+	for i, match := range t.Rounds[0] {
+		for slot := 0; slot < 2; slot++ {
+			if seed := order[2*i+slot]; seed <= len(players) {
+				match.Players[slot] = players[seed-1]
+			}
+		}
+	}
+	for _, match := range t.Rounds[0] {
+		if match.Players[1] == "" {
+			t.advance(match, match.Players[0])
+		} else if match.Players[0] == "" {
+			t.advance(match, match.Players[1])
+		}
+	}
+	return t, nil
+}
+
+// seedOrder lists the seeds of a bracket of the given size in the order
+// they are drawn, so the top two seeds can only meet in the final
+func seedOrder(size int) []int {
+	order := []int{1}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// advance records the winner of a match and seats them in the next round
+func (t *Tournament) advance(match *TournamentMatch, winner string) {
+	match.Winner = winner
+	if match.Round == len(t.Rounds)-1 {
+		t.Champion = winner
+		return
+	}
+	t.Rounds[match.Round+1][match.Index/2].Players[match.Index%2] = winner
+}
+
+// CurrentMatch returns the undecided match the player is drawn into, or nil
+// once they are knocked out or have won the tournament
+func (t *Tournament) CurrentMatch(name string) *TournamentMatch {
+	for _, round := range t.Rounds {
+		for _, match := range round {
+			if match.Winner == "" && match.Seats(name) {
+				return match
+			}
+		}
+	}
+	return nil
+}
+
+// Seats reports whether the player is one of the two drawn into an undecided match
+func (tm *TournamentMatch) Seats(name string) bool {
+	return tm.Winner == "" && name != "" && (tm.Players[0] == name || tm.Players[1] == name)
+}
+
+// winnerOf picks the match winner from a game summary. Sudden death
+// settles ties, but a game still tied after MaxMatchRounds goes to the
+// higher seed.
+func (tm *TournamentMatch) winnerOf(summary *game.GameSummary) string {
+	for _, standing := range summary.Standings {
+		if standing.PlayerID == summary.WinnerID && tm.Seats(standing.Name) {
+			return standing.Name
+		}
+	}
+	seeds := tm.Tournament.seeds
+	if tm.Players[1] != "" && seeds[tm.Players[1]] < seeds[tm.Players[0]] {
+		return tm.Players[1]
+	}
+	return tm.Players[0]
+}
+
+// CreateTournament draws a bracket for the given players, seeded in order.
+// Players log in with the tournament code to reach their current match.
+func (m *Matchmaking) CreateTournament(host, gameType string, players []string, rules game.MatchRules) (*net.TournamentState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !game.IsRegistered(gameType) {
+		return nil, fmt.Errorf("unknown game type: %s", gameType)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	t, err := newTournament(m.generateRoomCodeUnlocked(), gameType, host, players, rules)
+	if err != nil {
+		return nil, err
+	}
+	m.tournaments[t.Code] = t
+	for _, round := range t.Rounds {
+		for _, match := range round {
+			m.tournamentMatches[match.RoomCode] = match
+		}
+	}
+
+	slog.Info("Tournament created", logging.GameType(gameType), "tournament", t.Code, "host", host, "players", len(players), "rules", t.Rules.String())
+	return m.tournamentStateUnlocked(t, ""), nil
+}
+
+// Tournament returns the bracket for a tournament code. If viewer is drawn
+// into an undecided match, its room code is included.
+func (m *Matchmaking) Tournament(code, viewer string) (*net.TournamentState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tournaments[code]
+	if t == nil {
+		return nil, false
+	}
+	return m.tournamentStateUnlocked(t, viewer), true
+}
+
+// TournamentRoomCode returns the room code of the player's current match in
+// the tournament with the given code
+func (m *Matchmaking) TournamentRoomCode(code, name string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tournaments[code]
+	if t == nil {
+		return "", false
+	}
+	match := t.CurrentMatch(name)
+	if match == nil {
+		return "", false
+	}
+	return match.RoomCode, true
+}
+
+// recordTournamentResult moves the winner of a finished match on to the
+// next round and sends the new bracket to everyone in the tournament
+func (m *Matchmaking) recordTournamentResult(roomCode string, summary *game.GameSummary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	match := m.tournamentMatches[roomCode]
+	if match == nil || match.Winner != "" {
+		return
+	}
+	t := match.Tournament
+	winner := match.winnerOf(summary)
+	t.advance(match, winner)
+	slog.Info("Tournament match won", logging.RoomCode(roomCode), logging.GameType(t.GameType), "tournament", t.Code, "winner", winner, "round", match.Round+1, "match", match.Index+1)
+	if t.Champion != "" {
+		slog.Info("Tournament champion decided", logging.GameType(t.GameType), "tournament", t.Code, "champion", t.Champion)
+	}
+	m.broadcastBracketUnlocked(t)
+}
+
+// broadcastBracketUnlocked sends the bracket to every player connected to one
+// of the tournament's room codes, and to spectators in its match lobbies
+// Must be called with lock held
+func (m *Matchmaking) broadcastBracketUnlocked(t *Tournament) {
+	msg := net.BracketMessage{
+		Type:       "bracket",
+		Tournament: m.tournamentStateUnlocked(t, ""),
+	}
+	for _, conn := range m.connections {
+		if match := m.tournamentMatches[conn.session.RoomCode]; match != nil && match.Tournament == t {
+			conn.SendMessage(msg)
+		}
+	}
+	for _, round := range t.Rounds {
+		for _, match := range round {
+			if lobby := m.lobbies[match.RoomCode]; lobby != nil {
+				for _, sc := range lobby.Spectators {
+					sc.SendMessage(msg)
+				}
+			}
+		}
+	}
+}
+
+// tournamentStateUnlocked builds the bracket message for a tournament
+// Must be called with lock held
+func (m *Matchmaking) tournamentStateUnlocked(t *Tournament, viewer string) *net.TournamentState {
+	state := &net.TournamentState{
+		Code:     t.Code,
+		GameType: t.GameType,
+		Rules:    rulesMessage(t.Rules),
+		Host:     t.Host,
+		Champion: t.Champion,
+	}
+	for _, round := range t.Rounds {
+		matches := make([]net.TournamentMatch, len(round))
+		for i, match := range round {
+			matches[i] = net.TournamentMatch{
+				RoomCode: match.RoomCode,
+				Players:  match.Players,
+				Winner:   match.Winner,
+				State:    m.matchStateUnlocked(match),
+			}
+		}
+		state.Rounds = append(state.Rounds, matches)
+	}
+	if match := t.CurrentMatch(viewer); match != nil {
+		state.YourMatch = match.RoomCode
+	}
+	return state
+}
+
+// matchStateUnlocked works out where a match stands. A game that ends
+// without a summary, e.g. when both players leave, puts it back to ready.
+// Must be called with lock held
+func (m *Matchmaking) matchStateUnlocked(match *TournamentMatch) string {
+	switch {
+	case match.Winner != "":
+		return "done"
+	case match.RoomID != "" && m.gameRooms[match.RoomID] != nil && !m.gameRooms[match.RoomID].Ended():
+		return "playing"
+	case match.Players[0] != "" && match.Players[1] != "":
+		return "ready"
+	default:
+		return "pending"
+	}
+}
+
+// HandleTournaments serves the tournament API:
+//
+//	POST /api/tournaments              create a bracket, hosted by the logged in player
+//	GET  /api/tournaments/{code}       the bracket, e.g. for a TV
+//	POST /api/tournaments/{code}/next  move the logged in player's session to their current match
+func HandleTournaments(mm *Matchmaking, sessionStore *SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tournaments"), "/")
+		parts := strings.Split(path, "/")
+		session, loggedIn := sessionFromRequest(r, sessionStore)
+
+		switch {
+		case path == "":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if !loggedIn {
+				http.Error(w, "Not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			var req struct {
+				GameType string          `json:"gameType"`
+				Players  []string        `json:"players"`
+				Rules    *net.MatchRules `json:"rules"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+			players := make([]string, 0, len(req.Players))
+			for _, name := range req.Players {
+				if name = strings.TrimSpace(name); name != "" {
+					players = append(players, name)
+				}
+			}
+			rules := mm.defaultRules()
+			if req.Rules != nil {
+				rules = game.MatchRules{Mode: req.Rules.Mode, Count: req.Rules.Count}
+			}
+
+			state, err := mm.CreateTournament(session.PlayerName, req.GameType, players, rules)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(state)
+
+		case len(parts) == 1:
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			viewer := ""
+			if loggedIn {
+				viewer = session.PlayerName
+			}
+			state, ok := mm.Tournament(parts[0], viewer)
+			if !ok {
+				http.Error(w, "Tournament not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(state)
+
+		case len(parts) == 2 && parts[1] == "next":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if !loggedIn {
+				http.Error(w, "Not authenticated", http.StatusUnauthorized)
+				return
+			}
+			roomCode, ok := mm.TournamentRoomCode(parts[0], session.PlayerName)
+			if !ok {
+				http.Error(w, "No match to play in this tournament", http.StatusNotFound)
+				return
+			}
+			if _, ok := sessionStore.SetRoomCode(session.ID, roomCode); !ok {
+				http.Error(w, "Invalid session", http.StatusUnauthorized)
+				return
+			}
+			slog.Info("Player heading to tournament match", logging.RoomCode(roomCode), "tournament", parts[0], "name", session.PlayerName)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":  true,
+				"roomCode": roomCode,
+			})
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"GoServerGames/internal/game"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// entrants names n players p1 to pn, in seed order
+func entrants(n int) []string {
+	players := make([]string, n)
+	for i := range players {
+		players[i] = fmt.Sprintf("p%d", i+1)
+	}
+	return players
+}
+
+// summaryFor is a game summary of the match won by winner, or tied when
+// winner is ""
+func summaryFor(tm *TournamentMatch, winner string) *game.GameSummary {
+	summary := &game.GameSummary{}
+	for i, name := range tm.Players {
+		summary.Standings = append(summary.Standings, game.Standing{PlayerID: i + 1, Name: name})
+		if name == winner {
+			summary.WinnerID = i + 1
+		}
+	}
+	return summary
+}
+
+// pairings lists the players drawn into each match of a round
+func pairings(round []*TournamentMatch) [][2]string {
+	players := make([][2]string, len(round))
+	for i, match := range round {
+		players[i] = match.Players
+	}
+	return players
+}
+
+func TestSeedOrder(t *testing.T) {
+	tests := []struct {
+		size int
+		want []int
+	}{
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	}
+	for _, tt := range tests {
+		if got := seedOrder(tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("seedOrder(%d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestNewTournamentByes(t *testing.T) {
+	tests := []struct {
+		players     int
+		firstRound  [][2]string
+		secondRound [][2]string // Filled in by byes
+	}{
+		{
+			players:     3,
+			firstRound:  [][2]string{{"p1", ""}, {"p2", "p3"}},
+			secondRound: [][2]string{{"p1", ""}},
+		},
+		{
+			players:     5,
+			firstRound:  [][2]string{{"p1", ""}, {"p4", "p5"}, {"p2", ""}, {"p3", ""}},
+			secondRound: [][2]string{{"p1", ""}, {"p2", "p3"}},
+		},
+		{
+			players:     6,
+			firstRound:  [][2]string{{"p1", ""}, {"p4", "p5"}, {"p2", ""}, {"p3", "p6"}},
+			secondRound: [][2]string{{"p1", ""}, {"p2", ""}},
+		},
+		{
+			players:     8,
+			firstRound:  [][2]string{{"p1", "p8"}, {"p4", "p5"}, {"p2", "p7"}, {"p3", "p6"}},
+			secondRound: [][2]string{{"", ""}, {"", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d players", tt.players), func(t *testing.T) {
+			tour, err := newTournament("T", "speedtype", "host", entrants(tt.players), game.DefaultMatchRules(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pairings(tour.Rounds[0]); !reflect.DeepEqual(got, tt.firstRound) {
+				t.Errorf("first round = %v, want %v", got, tt.firstRound)
+			}
+			if got := pairings(tour.Rounds[1]); !reflect.DeepEqual(got, tt.secondRound) {
+				t.Errorf("second round = %v, want %v", got, tt.secondRound)
+			}
+			for _, match := range tour.Rounds[0] {
+				bye := match.Players[0] == "" || match.Players[1] == ""
+				if decided := match.Winner != ""; decided != bye {
+					t.Errorf("match %s %v decided = %v, want %v", match.RoomCode, match.Players, decided, bye)
+				}
+			}
+		})
+	}
+}
+
+func TestTournamentAdvance(t *testing.T) {
+	tour, err := newTournament("T", "speedtype", "host", entrants(5), game.DefaultMatchRules(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		round, index int
+		winner       string // Who the summary names, "" for a tie
+		want         string
+	}{
+		{0, 1, "", "p4"},   // A tie goes to the higher seed
+		{1, 1, "p3", "p3"}, // The lower seed wins
+		{1, 0, "p4", "p4"},
+		{2, 0, "", "p3"}, // The higher seed is drawn second
+	}
+	for _, step := range steps {
+		match := tour.Rounds[step.round][step.index]
+		got := match.winnerOf(summaryFor(match, step.winner))
+		if got != step.want {
+			t.Fatalf("match %s %v won by %q = %s, want %s", match.RoomCode, match.Players, step.winner, got, step.want)
+		}
+		tour.advance(match, got)
+	}
+
+	want := [][][2]string{
+		{{"p1", ""}, {"p4", "p5"}, {"p2", ""}, {"p3", ""}},
+		{{"p1", "p4"}, {"p2", "p3"}},
+		{{"p4", "p3"}},
+	}
+	for round, matches := range tour.Rounds {
+		if got := pairings(matches); !reflect.DeepEqual(got, want[round]) {
+			t.Errorf("round %d = %v, want %v", round+1, got, want[round])
+		}
+	}
+	if tour.Champion != "p3" {
+		t.Errorf("champion = %q, want p3", tour.Champion)
+	}
+	for _, name := range entrants(5) {
+		if match := tour.CurrentMatch(name); match != nil {
+			t.Errorf("%s still drawn into %s after the final", name, match.RoomCode)
+		}
+	}
+}
+
+func TestWinnerOfIgnoresUnseatedWinner(t *testing.T) {
+	tour, err := newTournament("T", "speedtype", "host", entrants(2), game.DefaultMatchRules(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := tour.Rounds[0][0]
+	summary := &game.GameSummary{
+		Standings: []game.Standing{{PlayerID: 7, Name: "someone"}, {PlayerID: 1, Name: "p1"}, {PlayerID: 2, Name: "p2"}},
+		WinnerID:  7,
+	}
+	if got := match.winnerOf(summary); got != "p1" {
+		t.Errorf("winnerOf = %s, want the higher seed p1", got)
+	}
+}
@@ -1,11 +1,13 @@
 package server
 
 import (
-	"GoServerGames/internal/game"
+	"GoServerGames/internal/logging"
 	"GoServerGames/internal/net"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -18,31 +20,82 @@ var upgrader = websocket.Upgrader{
 }
 
 type Connection struct {
-	conn            *websocket.Conn
-	send            chan []byte
-	mm              *Matchmaking
-	room            *game.Room
-	speedTypeRoom   *game.SpeedTypeRoom
-	mathSprintRoom  *game.MathSprintRoom
-	clickSpeedRoom  *game.ClickSpeedRoom
-	playerIdx       int
-	playerID        int
-	lobbyPlayer     *LobbyPlayer
-	session         *Session
+	conn              *websocket.Conn
+	send              chan []byte
+	mm                *Matchmaking
+	gameRoom          *GameRoom // Guarded by mu: set by matchmaking, read by readPump
+	playerID          int
+	lobbyPlayer       *LobbyPlayer
+	session           *Session
+	sessions          *SessionStore
+	lastTouch         time.Time // When the session was last refreshed; owned by readPump
+	spectator         bool      // Set by AddPlayer before the pumps start; never changes
 	lastBufferFullLog time.Time
+	done              chan struct{} // Closed when readPump exits, stopping the other loops
+	closing           chan string   // Close reason; writePump sends what's queued first
+	mu                sync.Mutex
 }
 
-func NewConnection(conn *websocket.Conn, mm *Matchmaking, session *Session) *Connection {
+func NewConnection(conn *websocket.Conn, mm *Matchmaking, session *Session, sessions *SessionStore) *Connection {
 	return &Connection{
-		conn:    conn,
-		send:    make(chan []byte, 1024), // Large buffer to handle bursts
-		mm:      mm,
-		session: session,
+		conn:      conn,
+		send:      make(chan []byte, mm.cfg.Server.SendBuffer), // Large buffer to handle bursts
+		mm:        mm,
+		session:   session,
+		sessions:  sessions,
+		lastTouch: time.Now(),
+		done:      make(chan struct{}),
+		closing:   make(chan string, 1),
 	}
 }
 
+// shutdown closes the connection once the messages already queued are sent
+func (c *Connection) shutdown(reason string) {
+	select {
+	case c.closing <- reason:
+	default:
+	}
+}
+
+// closeWith tells the client why before closing the connection
+func (c *Connection) closeWith(code int, reason string) {
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	c.conn.Close()
+}
+
+// touchSession keeps the session alive while the connection is in use.
+// Must only be called from readPump.
+func (c *Connection) touchSession() {
+	if time.Since(c.lastTouch) < sessionTouchInterval {
+		return
+	}
+	c.lastTouch = time.Now()
+	c.sessions.Touch(c.session.ID)
+}
+
+func (c *Connection) setGameRoom(room *GameRoom) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gameRoom = room
+}
+
+func (c *Connection) currentGameRoom() *GameRoom {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gameRoom
+}
+
+// logger tags log lines with the connection's room code and player, and
+// the game room it is in, if any
+func (c *Connection) logger() *slog.Logger {
+	attrs := []any{logging.RoomCode(c.session.RoomCode), logging.PlayerID(c.playerID)}
+	if room := c.currentGameRoom(); room != nil {
+		attrs = append(attrs, logging.RoomID(room.ID), logging.GameType(room.GameType))
+	}
+	return slog.With(attrs...)
+}
+
 func (c *Connection) SendWelcome(playerID int, roomID string, lobby *net.LobbyState) {
-	c.playerID = playerID
 	msg := net.WelcomeMessage{
 		Type:     "welcome",
 		PlayerID: playerID,
@@ -50,7 +103,38 @@ func (c *Connection) SendWelcome(playerID int, roomID string, lobby *net.LobbySt
 		RoomCode: c.session.RoomCode,
 		Lobby:    lobby,
 	}
-	log.Printf("Sending welcome to player %d with lobby: %v", playerID, lobby != nil)
+	slog.Debug("Sending welcome", logging.RoomCode(c.session.RoomCode), logging.PlayerID(playerID), "lobby", lobby != nil)
+	c.SendMessage(msg)
+}
+
+// SendGameWelcome greets a player or spectator joining a game room, along with
+// the match rules, the tournament the game is a match in, if any, and the
+// player's reconnect token
+func (c *Connection) SendGameWelcome(playerID int, roomID string, rules *net.MatchRules, tournament, reconnectToken string) {
+	msg := net.WelcomeMessage{
+		Type:           "welcome",
+		PlayerID:       playerID,
+		RoomID:         roomID,
+		RoomCode:       c.session.RoomCode,
+		Rules:          rules,
+		Spectator:      c.spectator,
+		Tournament:     tournament,
+		ReconnectToken: reconnectToken,
+	}
+	slog.Debug("Sending game welcome", logging.RoomCode(c.session.RoomCode), logging.RoomID(roomID), logging.PlayerID(playerID))
+	c.SendMessage(msg)
+}
+
+// SendSpectatorWelcome greets a spectator watching a full lobby
+func (c *Connection) SendSpectatorWelcome(spectatorID int, lobby *net.LobbyState) {
+	msg := net.WelcomeMessage{
+		Type:      "welcome",
+		PlayerID:  spectatorID,
+		RoomCode:  c.session.RoomCode,
+		Lobby:     lobby,
+		Spectator: true,
+	}
+	slog.Debug("Sending spectator welcome", logging.RoomCode(c.session.RoomCode), logging.PlayerID(spectatorID))
 	c.SendMessage(msg)
 }
 
@@ -59,14 +143,14 @@ func (c *Connection) SendLobbyUpdate(lobby *net.LobbyState) {
 		Type:  "lobby",
 		Lobby: lobby,
 	}
-	log.Printf("Sending lobby update to player %d: %d players", c.playerID, len(lobby.Players))
+	c.logger().Debug("Sending lobby update", "players", len(lobby.Players))
 	c.SendMessage(msg)
 }
 
 func (c *Connection) SendMessage(v interface{}) {
 	data, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		c.logger().Error("Error marshaling message", "error", err)
 		return
 	}
 	select {
@@ -74,16 +158,25 @@ func (c *Connection) SendMessage(v interface{}) {
 		// Message queued successfully
 	default:
 		// Buffer full - log occasionally to avoid spam (once per second max)
-		if c.lastBufferFullLog.IsZero() || time.Since(c.lastBufferFullLog) > time.Second {
-			log.Printf("Send buffer full for player %d - messages may be dropped", c.playerID)
+		messagesDropped.Inc()
+		c.mu.Lock()
+		logIt := c.lastBufferFullLog.IsZero() || time.Since(c.lastBufferFullLog) > time.Second
+		if logIt {
 			c.lastBufferFullLog = time.Now()
 		}
+		c.mu.Unlock()
+		if logIt {
+			c.logger().Warn("Send buffer full - messages may be dropped")
+		}
 	}
 }
 
 func (c *Connection) readPump() {
 	defer func() {
+		connectionsOpen.Dec()
+		close(c.done)
 		c.conn.Close()
+		c.sessions.detach(c.session.ID, c)
 		if c.playerID > 0 {
 			c.mm.RemovePlayer(c.playerID, c)
 		}
@@ -92,6 +185,7 @@ func (c *Connection) readPump() {
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.touchSession()
 		return nil
 	})
 
@@ -99,10 +193,11 @@ func (c *Connection) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				c.logger().Warn("WebSocket error", "error", err)
 			}
 			break
 		}
+		c.touchSession()
 
 		var baseMsg map[string]interface{}
 		if err := json.Unmarshal(message, &baseMsg); err != nil {
@@ -114,10 +209,15 @@ func (c *Connection) readPump() {
 			continue
 		}
 
+		// Spectators are read-only
+		if c.spectator {
+			continue
+		}
+
 		switch msgType {
 		case "hello":
 			// Hello is now just for compatibility - player is already added on connect
-			log.Printf("Hello received from player %d (%s)", c.playerID, c.session.PlayerName)
+			c.logger().Debug("Hello received", "name", c.session.PlayerName)
 
 		case "ready":
 			var ready net.ReadyMessage
@@ -125,47 +225,38 @@ func (c *Connection) readPump() {
 				c.mm.SetReady(c.playerID, ready.Ready)
 			}
 
-		case "input":
-			var input net.InputMessage
-			if err := json.Unmarshal(message, &input); err == nil {
-				if c.room != nil && c.playerIdx >= 0 && c.playerIdx < 2 {
-					c.room.QueueInput(c.playerIdx, input)
-				}
-			}
-
 		case "selectGame":
 			var selectMsg net.SelectGameMessage
 			if err := json.Unmarshal(message, &selectMsg); err == nil {
 				c.mm.SelectGame(c.playerID, selectMsg.GameType)
 			}
 
-		// readyForNextRound message handler removed - rounds auto-advance after 5 seconds
+		case "setRules":
+			var rulesMsg net.SetRulesMessage
+			if err := json.Unmarshal(message, &rulesMsg); err == nil {
+				c.mm.SetRules(c.playerID, rulesMsg.Rules)
+			}
 
-		case "speedTypeSubmit":
-			var submitMsg net.SpeedTypeSubmitMessage
-			if err := json.Unmarshal(message, &submitMsg); err == nil {
-				if c.speedTypeRoom != nil {
-					c.speedTypeRoom.SubmitWord(c.playerID, submitMsg.Word, submitMsg.TimeMs)
-					c.mm.broadcastSpeedTypeState(c.speedTypeRoom)
-				}
+		case "setPlaylist":
+			var playlistMsg net.SetPlaylistMessage
+			if err := json.Unmarshal(message, &playlistMsg); err == nil {
+				c.mm.SetPlaylist(c.playerID, playlistMsg.GameTypes)
 			}
 
-		case "mathSprintSubmit":
-			var submitMsg net.MathSprintSubmitMessage
-			if err := json.Unmarshal(message, &submitMsg); err == nil {
-				if c.mathSprintRoom != nil {
-					c.mathSprintRoom.SubmitAnswer(c.playerID, submitMsg.Answer, submitMsg.TimeMs)
-					c.mm.broadcastMathSprintState(c.mathSprintRoom)
+		case "readyForNewGame":
+			var rematchMsg net.ReadyForNewGameMessage
+			if err := json.Unmarshal(message, &rematchMsg); err == nil {
+				if room := c.currentGameRoom(); room != nil {
+					room.ReadyForNewGame(c.playerID, rematchMsg.Ready)
 				}
 			}
 
-		case "clickSpeedSubmit":
-			var submitMsg net.ClickSpeedSubmitMessage
-			if err := json.Unmarshal(message, &submitMsg); err == nil {
-				if c.clickSpeedRoom != nil {
-					c.clickSpeedRoom.SubmitClick(c.playerID, submitMsg.TimeMs)
-					c.mm.broadcastClickSpeedState(c.clickSpeedRoom)
-				}
+		// readyForNextRound message handler removed - rounds auto-advance after 5 seconds
+
+		default:
+			// Minigame submissions use a message type chosen by the game itself
+			if room := c.currentGameRoom(); room != nil && msgType == room.SubmitType {
+				room.Submit(c.playerID, message)
 			}
 		}
 	}
@@ -209,74 +300,109 @@ func (c *Connection) writePump() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
-		}
-	}
-}
 
-func (c *Connection) StartSnapshotLoop() {
-	ticker := time.NewTicker(time.Second / 60) // 60Hz
-	defer ticker.Stop()
+		case reason := <-c.closing:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			for n := len(c.send); n > 0; n-- {
+				if err := c.conn.WriteMessage(websocket.TextMessage, <-c.send); err != nil {
+					return
+				}
+			}
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, reason))
+			return
 
-	for range ticker.C {
-		// Only send snapshots for the old FPS game room, not speed type
-		if c.room != nil && c.speedTypeRoom == nil {
-			snap := c.room.GetSnap()
-			c.SendMessage(snap)
+		case <-c.done:
+			return
 		}
 	}
 }
 
-func HandleWebSocketWithAuth(mm *Matchmaking, sessionStore *SessionStore) http.HandlerFunc {
+func HandleWebSocketWithAuth(mm *Matchmaking, sessionStore *SessionStore, limiter *Limiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get session from cookie
 		cookie, err := r.Cookie("session")
 		if err != nil {
-			log.Printf("WebSocket upgrade failed: no session cookie - %v", err)
+			slog.Info("WebSocket upgrade failed: no session cookie", "error", err)
 			http.Error(w, "Not authenticated", http.StatusUnauthorized)
 			return
 		}
 
-		session, ok := sessionStore.GetSession(cookie.Value)
-		if !ok {
-			log.Printf("WebSocket upgrade failed: invalid session cookie %s", cookie.Value)
+		// Guessing session IDs is throttled like guessing passwords
+		ipKey := IPKey(limiter.ClientIP(r))
+		if wait, blocked := limiter.Blocked(ipKey); blocked {
+			slog.Warn("WebSocket upgrade throttled", "ip", ipKey)
+			TooManyRequests(w, wait)
+			return
+		}
+
+		session, err := sessionStore.GetSession(cookie.Value)
+		if errors.Is(err, ErrSessionExpired) {
+			slog.Info("WebSocket upgrade failed: session expired", "ip", ipKey)
+			http.Error(w, "Session expired", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.Warn("WebSocket upgrade failed: invalid session cookie", "ip", ipKey)
+			if wait := limiter.Fail(ipKey); wait > 0 {
+				TooManyRequests(w, wait)
+				return
+			}
 			http.Error(w, "Invalid session", http.StatusUnauthorized)
 			return
 		}
-		
-		log.Printf("WebSocket upgrade: session validated for %s (room: %s)", session.PlayerName, session.RoomCode)
+
+		slog.Debug("WebSocket upgrade: session validated", logging.RoomCode(session.RoomCode), "name", session.PlayerName)
 
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			log.Printf("WebSocket upgrade error: %v", err)
+			slog.Warn("WebSocket upgrade error", logging.RoomCode(session.RoomCode), "name", session.PlayerName, "error", err)
 			return
 		}
 
-		c := NewConnection(conn, mm, session)
-		
-		// Validate room code from session
-		if session.RoomCode == "" {
-			log.Printf("Client rejected: %s - session has empty room code", session.PlayerName)
+		// Validate room code from session; only quick-match players start without one
+		if session.RoomCode == "" && session.QuickMatch == nil {
+			slog.Warn("Client rejected: session has empty room code", "name", session.PlayerName)
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Invalid session: room code missing"))
 			conn.Close()
 			return
 		}
-		
-		log.Printf("Client connected: %s (room: %s)", session.PlayerName, session.RoomCode)
-		
+
+		// Attached before the player is added so logging out meanwhile still closes it
+		c := NewConnection(conn, mm, session, sessionStore)
+		if !sessionStore.attach(session.ID, c) {
+			slog.Info("Client rejected: logged out while connecting", logging.RoomCode(session.RoomCode), "name", session.PlayerName)
+			conn.Close()
+			return
+		}
+
+		slog.Info("Client connected", logging.RoomCode(session.RoomCode), "name", session.PlayerName)
+
 		// Add player immediately when they connect (handles both lobby and game page connections)
-		log.Printf("About to call AddPlayer for %s in room %s", session.PlayerName, session.RoomCode)
-		playerID := mm.AddPlayer(session.PlayerName, session.RoomCode, c)
-		log.Printf("AddPlayer returned playerID=%d for %s in room %s", playerID, session.PlayerName, session.RoomCode)
+		var playerID int
+		if session.RoomCode == "" {
+			playerID = mm.JoinQueue(session.PlayerName, *session.QuickMatch, c)
+		} else {
+			playerID = mm.AddPlayer(session.PlayerName, session.RoomCode, r.URL.Query().Get("token"), c)
+		}
 		if playerID == 0 {
-			log.Printf("Failed to add player: %s in room %s (room may be full or validation error)", session.PlayerName, session.RoomCode)
+			// Never added, so readPump won't run to detach it
+			sessionStore.detach(session.ID, c)
+		}
+		if playerID == 0 && mm.Draining() {
+			slog.Info("Client rejected: server is shutting down", logging.RoomCode(session.RoomCode), "name", session.PlayerName)
+			c.closeWith(websocket.CloseGoingAway, "Server is shutting down")
+			return
+		}
+		if playerID == 0 {
+			slog.Warn("Failed to add player (room may be full or validation error)", logging.RoomCode(session.RoomCode), "name", session.PlayerName)
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Room full or invalid"))
 			conn.Close()
 			return
 		}
-		log.Printf("Player added with ID %d, name: %s, room: %s", playerID, session.PlayerName, session.RoomCode)
-		
+		c.logger().Info("Player added", "name", session.PlayerName)
+
+		connectionsOpen.Inc()
 		go c.writePump()
 		go c.readPump()
-		go c.StartSnapshotLoop()
 	}
 }
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUsernameTaken is returned when registering a username that already has
+// an account, ignoring case
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// Account is a registered player
+type Account struct {
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"` // Never the password itself
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// AccountStore keeps the registered accounts. Implementations must be safe
+// for concurrent use.
+type AccountStore interface {
+	CreateAccount(username, passwordHash string) (*Account, error) // ErrUsernameTaken if the name is in use
+	AccountByUsername(username string) (*Account, bool)            // Ignores case
+	Account(id int) (*Account, bool)
+	Close() error
+}
+
+// FileAccountStore is an AccountStore kept in a single file, one JSON
+// account per line
+type FileAccountStore struct {
+	file       *jsonLines
+	byID       map[int]*Account
+	byUsername map[string]*Account // Keyed by lowercase username
+	nextID     int
+	mu         sync.Mutex
+}
+
+// OpenFileAccountStore opens the accounts file at path, creating the file and
+// its directory if needed, and loads the accounts already in it
+func OpenFileAccountStore(path string) (*FileAccountStore, error) {
+	file, err := openJSONLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("open account store: %w", err)
+	}
+
+	s := &FileAccountStore{
+		file:       file,
+		byID:       make(map[int]*Account),
+		byUsername: make(map[string]*Account),
+		nextID:     1,
+	}
+	err = file.load(func(line []byte) error {
+		account := &Account{}
+		if err := json.Unmarshal(line, account); err != nil {
+			return err
+		}
+		s.addUnlocked(account)
+		return nil
+	})
+	if err != nil {
+		file.close()
+		return nil, fmt.Errorf("load account store: %w", err)
+	}
+	slog.Info("Loaded accounts", "accounts", len(s.byID), "path", path)
+	return s, nil
+}
+
+// Must be called with lock held
+func (s *FileAccountStore) addUnlocked(account *Account) {
+	s.byID[account.ID] = account
+	s.byUsername[strings.ToLower(account.Username)] = account
+	if account.ID >= s.nextID {
+		s.nextID = account.ID + 1
+	}
+}
+
+func (s *FileAccountStore) CreateAccount(username, passwordHash string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, taken := s.byUsername[strings.ToLower(username)]; taken {
+		return nil, ErrUsernameTaken
+	}
+	account := &Account{
+		ID:           s.nextID,
+		Username:     username,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.file.append(account); err != nil {
+		return nil, fmt.Errorf("save account: %w", err)
+	}
+	s.addUnlocked(account)
+	copied := *account
+	return &copied, nil
+}
+
+func (s *FileAccountStore) AccountByUsername(username string) (*Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.byUsername[strings.ToLower(username)]
+	if !ok {
+		return nil, false
+	}
+	copied := *account
+	return &copied, true
+}
+
+func (s *FileAccountStore) Account(id int) (*Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.byID[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *account
+	return &copied, true
+}
+
+func (s *FileAccountStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.close()
+}
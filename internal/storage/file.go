@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// FileStore is a MatchStore kept in a single file, one JSON record per line
+type FileStore struct {
+	file    *jsonLines
+	records []MatchRecord // Every stored game, oldest first
+	nextID  int
+	mu      sync.Mutex
+}
+
+// OpenFileStore opens the match history at path, creating the file and its
+// directory if needed, and loads the games already in it
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := openJSONLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("open match store: %w", err)
+	}
+
+	s := &FileStore{file: file, nextID: 1}
+	err = file.load(func(line []byte) error {
+		var record MatchRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		s.records = append(s.records, record)
+		if record.ID >= s.nextID {
+			s.nextID = record.ID + 1
+		}
+		return nil
+	})
+	if err != nil {
+		file.close()
+		return nil, fmt.Errorf("load match store: %w", err)
+	}
+	slog.Info("Loaded recorded games", "games", len(s.records), "path", path)
+	return s, nil
+}
+
+func (s *FileStore) SaveMatch(record *MatchRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.ID = s.nextID
+	if err := s.file.append(record); err != nil {
+		return fmt.Errorf("save match %d: %w", record.ID, err)
+	}
+	s.nextID++
+	s.records = append(s.records, *record)
+	return nil
+}
+
+func (s *FileStore) Matches(filter MatchFilter) ([]MatchRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matches []MatchRecord
+	for i := range s.records {
+		if filter.Matches(&s.records[i]) {
+			matches = append(matches, s.records[i])
+		}
+	}
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[len(matches)-filter.Limit:]
+	}
+	return matches, nil
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.close()
+}
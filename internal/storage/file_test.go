@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testMatch is a finished two player game won by winner
+func testMatch(gameType, winner, loser string) *MatchRecord {
+	start := time.Date(2026, 1, 10, 3, 4, 5, 0, time.UTC)
+	return &MatchRecord{
+		RoomCode:  "ABCD",
+		RoomID:    "room1",
+		GameType:  gameType,
+		StartedAt: start,
+		EndedAt:   start.Add(time.Minute),
+		Players: []PlayerRecord{
+			{PlayerID: 1, Name: winner, Score: 3, Place: 1},
+			{PlayerID: 2, Name: loser, Score: 1, Place: 2},
+		},
+		WinnerID: 1,
+	}
+}
+
+func openStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	return s
+}
+
+func save(t *testing.T, s *FileStore, record *MatchRecord) {
+	t.Helper()
+	if err := s.SaveMatch(record); err != nil {
+		t.Fatalf("SaveMatch: %v", err)
+	}
+}
+
+// winners maps the ID of every stored game to its winner
+func winners(t *testing.T, s *FileStore) map[int]string {
+	t.Helper()
+	records, err := s.Matches(MatchFilter{})
+	if err != nil {
+		t.Fatalf("Matches: %v", err)
+	}
+	got := make(map[int]string)
+	for _, r := range records {
+		got[r.ID] = r.WinnerName()
+	}
+	return got
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "matches.jsonl")
+	s := openStore(t, path)
+	save(t, s, testMatch("speedtype", "alice", "bob"))
+	save(t, s, testMatch("mathsprint", "bob", "alice"))
+	save(t, s, testMatch("speedtype", "carol", "bob"))
+	s.Close()
+
+	s = openStore(t, path)
+	defer s.Close()
+	if got, want := winners(t, s), map[int]string{1: "alice", 2: "bob", 3: "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded games = %v, want %v", got, want)
+	}
+
+	records, _ := s.Matches(MatchFilter{GameType: "speedtype", Player: "bob", Limit: 1})
+	if len(records) != 1 || records[0].ID != 3 {
+		t.Errorf("latest speedtype game with bob = %+v, want game 3", records)
+	}
+
+	record := testMatch("clickspeed", "dave", "alice")
+	save(t, s, record)
+	if record.ID != 4 {
+		t.Errorf("ID after reopening = %d, want 4", record.ID)
+	}
+}
+
+func TestFileStoreTruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matches.jsonl")
+	s := openStore(t, path)
+	save(t, s, testMatch("speedtype", "alice", "bob"))
+	save(t, s, testMatch("speedtype", "bob", "alice"))
+	s.Close()
+
+	// Cut the last record short, as a crash mid-write would
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-20); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openStore(t, path)
+	if got, want := winners(t, s), map[int]string{1: "alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("games after a cut short write = %v, want %v", got, want)
+	}
+	save(t, s, testMatch("mathsprint", "carol", "bob"))
+	s.Close()
+
+	// The game saved after the repair must be on a line of its own
+	s = openStore(t, path)
+	defer s.Close()
+	if got, want := winners(t, s), map[int]string{1: "alice", 2: "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("games after saving past a cut short write = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// jsonLines is a file of JSON records, one per line. It is only ever
+// appended to and is read back in full when opened, so there's no database
+// to run alongside the server.
+type jsonLines struct {
+	path string
+	file *os.File
+}
+
+// openJSONLines opens the file at path, creating it and its directory if needed
+func openJSONLines(path string) (*jsonLines, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create directory for %s: %w", path, err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &jsonLines{path: path, file: file}, nil
+}
+
+// load hands every line of the file to decode. A line that doesn't decode,
+// e.g. one cut short by a crash mid-write, is skipped rather than losing
+// everything after it.
+func (f *jsonLines) load(decode func(line []byte) error) error {
+	scanner := bufio.NewScanner(f.file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := decode(scanner.Bytes()); err != nil {
+			slog.Warn("Skipping unreadable line", "path", f.path, "line", line, "error", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", f.path, err)
+	}
+
+	// Start the next record on a line of its own after a cut short write
+	info, err := f.file.Stat()
+	if err != nil {
+		return fmt.Errorf("read %s: %w", f.path, err)
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.file.ReadAt(last, info.Size()-1); err != nil {
+			return fmt.Errorf("read %s: %w", f.path, err)
+		}
+		if last[0] != '\n' {
+			if _, err := f.file.Write([]byte{'\n'}); err != nil {
+				return fmt.Errorf("repair %s: %w", f.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// append writes a record as a new line and flushes it to disk
+func (f *jsonLines) append(record interface{}) error {
+	if f.file == nil {
+		return fmt.Errorf("%s is closed", f.path)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode record: %w", err)
+	}
+	// One write per record, so a crash can only cut the last line short
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write %s: %w", f.path, err)
+	}
+	return f.file.Sync()
+}
+
+func (f *jsonLines) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
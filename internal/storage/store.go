@@ -0,0 +1,92 @@
+package storage
+
+import "time"
+
+// MatchRecord is a finished game as kept in the match history
+type MatchRecord struct {
+	ID         int            `json:"id"` // Assigned by the store, increasing across restarts
+	RoomCode   string         `json:"roomCode"`
+	RoomID     string         `json:"roomId"` // Only unique within one server run
+	GameType   string         `json:"gameType"`
+	Tournament string         `json:"tournament,omitempty"`
+	StartedAt  time.Time      `json:"startedAt"`
+	EndedAt    time.Time      `json:"endedAt"`
+	Players    []PlayerRecord `json:"players"`  // Ordered by place
+	WinnerID   int            `json:"winnerId"` // 0 if the top score was tied
+	Rounds     []RoundRecord  `json:"rounds"`
+}
+
+// PlayerRecord is a player's final standing in a recorded game. Player IDs
+// are handed out per server run, so names identify players across games.
+type PlayerRecord struct {
+	PlayerID  int     `json:"playerId"`
+	Name      string  `json:"name"`
+	Score     int     `json:"score"`
+	AvgTimeMs float64 `json:"avgTimeMs"`
+	Place     int     `json:"place"`
+}
+
+// RoundRecord is one round of a recorded game
+type RoundRecord struct {
+	RoundNumber int            `json:"roundNumber"`
+	WinnerID    int            `json:"winnerId"`
+	TimedOut    bool           `json:"timedOut,omitempty"`
+	Prompt      string         `json:"prompt,omitempty"` // The word or question, if the game has one
+	Answer      string         `json:"answer,omitempty"`
+	Results     []ResultRecord `json:"results"`
+}
+
+// ResultRecord is a player's finish in a recorded round
+type ResultRecord struct {
+	PlayerID int     `json:"playerId"`
+	TimeMs   float64 `json:"timeMs"` // 0 if the player didn't submit
+	Place    int     `json:"place"`
+	Points   int     `json:"points"`
+}
+
+// WinnerName returns the name of the game's winner, or "" on a tie
+func (r *MatchRecord) WinnerName() string {
+	for _, p := range r.Players {
+		if p.PlayerID == r.WinnerID {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// HasPlayer reports whether a player with the given name played the game
+func (r *MatchRecord) HasPlayer(name string) bool {
+	for _, p := range r.Players {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchFilter narrows down the games returned by a MatchStore. Zero values
+// match everything.
+type MatchFilter struct {
+	GameType string
+	Player   string // Only games this player played in
+	Limit    int    // Most recent games only; 0 for no limit
+}
+
+// Matches reports whether a record passes the filter, ignoring Limit
+func (f MatchFilter) Matches(r *MatchRecord) bool {
+	if f.GameType != "" && r.GameType != f.GameType {
+		return false
+	}
+	if f.Player != "" && !r.HasPlayer(f.Player) {
+		return false
+	}
+	return true
+}
+
+// MatchStore keeps the history of finished games. Implementations must be
+// safe for concurrent use.
+type MatchStore interface {
+	SaveMatch(record *MatchRecord) error               // Sets record.ID
+	Matches(filter MatchFilter) ([]MatchRecord, error) // Oldest first
+	Close() error
+}
@@ -0,0 +1,185 @@
+// Admin dashboard: lists lobbies and game rooms through the admin API and
+// lets the admin end games, kick players, clear lobbies, announce and change
+// the server's log level.
+// The admin token is kept for this tab only.
+const ADMIN_REFRESH_MS = 3000;
+
+class AdminPage {
+    constructor() {
+        this.token = sessionStorage.getItem('adminToken') || '';
+        this.timer = null;
+
+        document.getElementById('adminTokenForm').addEventListener('submit', event => {
+            event.preventDefault();
+            this.token = document.getElementById('adminToken').value.trim();
+            sessionStorage.setItem('adminToken', this.token);
+            this.refresh();
+        });
+        document.getElementById('adminSignOut').addEventListener('click', () => this.signOut());
+        document.getElementById('announceForm').addEventListener('submit', event => {
+            event.preventDefault();
+            this.announce();
+        });
+        document.getElementById('logLevel').addEventListener('change', () => this.setLogLevel());
+
+        if (this.token) {
+            this.refresh();
+        }
+    }
+
+    signOut(message = '') {
+        this.token = '';
+        sessionStorage.removeItem('adminToken');
+        clearTimeout(this.timer);
+        document.getElementById('adminPanel').style.display = 'none';
+        document.getElementById('adminMessage').textContent = message;
+    }
+
+    // Calls the admin API, resolving to the parsed JSON or null on failure
+    async request(method, path, body) {
+        const options = { method, headers: { 'Authorization': `Bearer ${this.token}` } };
+        if (body) {
+            options.headers['Content-Type'] = 'application/json';
+            options.body = JSON.stringify(body);
+        }
+        try {
+            const response = await fetch(`/api/admin${path}`, options);
+            if (response.status === 401 || response.status === 404 && path === '/rooms') {
+                this.signOut(response.status === 401 ? 'Wrong admin token' : 'The admin API is off - set ADMIN_TOKEN');
+                return null;
+            }
+            if (!response.ok) {
+                document.getElementById('adminMessage').textContent = await response.text();
+                return null;
+            }
+            return response.json();
+        } catch (error) {
+            document.getElementById('adminMessage').textContent = 'Connection error. Please try again.';
+            console.error('Admin request failed:', error);
+            return null;
+        }
+    }
+
+    async refresh() {
+        clearTimeout(this.timer);
+        const rooms = await this.request('GET', '/rooms');
+        if (!rooms) {
+            return;
+        }
+        document.getElementById('adminPanel').style.display = 'block';
+        document.getElementById('adminMessage').textContent = rooms.draining
+            ? 'The server is shutting down'
+            : `${rooms.queued} waiting for a quick match`;
+        this.renderGameRooms(rooms.gameRooms);
+        this.renderLobbies(rooms.lobbies);
+        const logLevel = await this.request('GET', '/loglevel');
+        if (logLevel && document.activeElement !== document.getElementById('logLevel')) {
+            document.getElementById('logLevel').value = logLevel.level;
+        }
+        this.timer = setTimeout(() => this.refresh(), ADMIN_REFRESH_MS);
+    }
+
+    // Lists players with their connection status, each with a kick button
+    renderPlayers(cell, players) {
+        players.forEach(player => {
+            const item = document.createElement('div');
+            const status = player.connected ? (player.ready ? 'ready' : 'connected') : 'disconnected';
+            item.innerHTML = `${Results.escape(player.name)} <small>#${player.id}, ${status}</small> `;
+            if (player.connected) {
+                const kick = document.createElement('button');
+                kick.className = 'invite-btn';
+                kick.textContent = 'Kick';
+                kick.addEventListener('click', () => this.act(`Kick ${player.name}?`, 'POST', `/players/${player.id}/kick`));
+                item.appendChild(kick);
+            }
+            cell.appendChild(item);
+        });
+    }
+
+    renderGameRooms(gameRooms) {
+        const rows = document.getElementById('gameRoomRows');
+        rows.innerHTML = '';
+        gameRooms.forEach(room => {
+            let state = room.ended ? 'ended' : `${room.phase}, round ${room.round}`;
+            if (!room.responding && !room.ended) {
+                state = 'not responding';
+            }
+            const row = document.createElement('tr');
+            row.innerHTML = `
+                <td>${Results.escape(room.id)}</td>
+                <td>${Results.escape(room.roomCode)}${room.tournament ? ` <small>(${Results.escape(room.tournament)})</small>` : ''}</td>
+                <td>${Results.escape(room.gameType)}</td>
+                <td>${Results.escape(state)}</td>
+                <td></td>
+                <td>${room.spectators}</td>
+                <td></td>
+            `;
+            this.renderPlayers(row.children[4], room.players);
+            if (!room.ended) {
+                const end = document.createElement('button');
+                end.className = 'invite-btn';
+                end.textContent = 'End game';
+                end.addEventListener('click', () => this.act(`End ${room.gameType} room ${room.id}?`, 'POST', `/rooms/${encodeURIComponent(room.id)}/end`));
+                row.children[6].appendChild(end);
+            }
+            rows.appendChild(row);
+        });
+    }
+
+    renderLobbies(lobbies) {
+        const rows = document.getElementById('lobbyRows');
+        rows.innerHTML = '';
+        lobbies.forEach(lobby => {
+            const selected = lobby.playlist ? lobby.playlist.join(', ') : (lobby.selectedGame || '-');
+            const row = document.createElement('tr');
+            row.innerHTML = `
+                <td>${Results.escape(lobby.code)}${lobby.tournament ? ` <small>(${Results.escape(lobby.tournament)})</small>` : ''}</td>
+                <td>${Results.escape(selected)}</td>
+                <td></td>
+                <td>${lobby.spectators}</td>
+                <td></td>
+            `;
+            this.renderPlayers(row.children[2], lobby.players);
+            const clear = document.createElement('button');
+            clear.className = 'invite-btn';
+            clear.textContent = 'Clear lobby';
+            clear.addEventListener('click', () => this.act(`Clear lobby ${lobby.code}?`, 'DELETE', `/lobbies/${encodeURIComponent(lobby.code)}`));
+            row.children[4].appendChild(clear);
+            rows.appendChild(row);
+        });
+    }
+
+    async act(question, method, path) {
+        if (!confirm(question)) {
+            return;
+        }
+        if (await this.request(method, path)) {
+            this.refresh();
+        }
+    }
+
+    async announce() {
+        const input = document.getElementById('announceText');
+        const message = input.value.trim();
+        if (!message) {
+            return;
+        }
+        const result = await this.request('POST', '/announce', { message });
+        if (result) {
+            input.value = '';
+            document.getElementById('adminMessage').textContent = `Announcement sent to ${result.sent} connections`;
+        }
+    }
+
+    async setLogLevel() {
+        const level = document.getElementById('logLevel').value;
+        const result = await this.request('PUT', '/loglevel', { level });
+        if (result) {
+            document.getElementById('adminMessage').textContent = `Logging at ${result.level} and above`;
+        }
+    }
+}
+
+window.addEventListener('DOMContentLoaded', () => {
+    window.adminPage = new AdminPage();
+});
@@ -0,0 +1,67 @@
+// Renders a tournament bracket, one column per round. Shared by the
+// tournament page and the lobby of a tournament match.
+const Bracket = {
+    gameNames: {
+        'speedtype': 'Speed Type',
+        'mathsprint': 'Quick Math',
+        'clickspeed': 'Click Speed'
+    },
+
+    escape(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    },
+
+    roundName(index, total) {
+        const fromFinal = total - 1 - index;
+        if (fromFinal === 0) return 'Final';
+        if (fromFinal === 1) return 'Semifinals';
+        if (fromFinal === 2) return 'Quarterfinals';
+        return `Round ${index + 1}`;
+    },
+
+    // A seat is empty while its feeder match is undecided, or for a bye
+    seatName(match, slot) {
+        const name = match.players[slot];
+        if (name) return this.escape(name);
+        return match.state === 'done' ? '<em>bye</em>' : '<em>TBD</em>';
+    },
+
+    render(container, tournament, highlight) {
+        container.innerHTML = '';
+        const rounds = tournament.rounds || [];
+        rounds.forEach((round, index) => {
+            const column = document.createElement('div');
+            column.className = 'bracket-round';
+            column.innerHTML = `<h3>${this.roundName(index, rounds.length)}</h3>`;
+            round.forEach(match => {
+                const item = document.createElement('div');
+                item.className = `bracket-match ${match.state}` + (match.roomCode === highlight ? ' current' : '');
+                const seats = [0, 1].map(slot => {
+                    const name = match.players[slot];
+                    const won = name && match.winner === name;
+                    return `<div class="bracket-seat${won ? ' winner' : ''}">${this.seatName(match, slot)}</div>`;
+                }).join('');
+                item.innerHTML = `
+                    ${seats}
+                    <div class="bracket-match-info">
+                        <span class="bracket-room">${this.escape(match.roomCode)}</span>
+                        <span class="bracket-state">${match.state}</span>
+                    </div>
+                `;
+                column.appendChild(item);
+            });
+            container.appendChild(column);
+        });
+    },
+
+    describe(tournament) {
+        const game = this.gameNames[tournament.gameType] || tournament.gameType;
+        const rules = tournament.rules;
+        let mode = `${rules.count} rounds`;
+        if (rules.mode === 'bestOf') mode = `best of ${rules.count}`;
+        if (rules.mode === 'firstTo') mode = `first to ${rules.count}`;
+        return `${game} · ${mode} · hosted by ${tournament.host}`;
+    }
+};
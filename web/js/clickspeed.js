@@ -9,39 +9,44 @@ class ClickSpeedClient {
         this.waitingForTarget = false;
         this.hasClicked = false;
         this.currentTargetKey = null;
-        this.scores = { player1: 0, player2: 0 };
-        this.playerIDs = { player1: null, player2: null };
-        this.playerNames = { player1: 'You', player2: 'Opponent' };
+        this.spectator = false;
+        this.tournament = null; // Tournament code when this game is a bracket match
+        this.series = null; // Latest status of the party series this game is part of
+        this.roundDeadline = 0;
+        this.roundTimerInterval = null;
+        this.names = {}; // Player names keyed by ID
         
         // Setup back to lobby button that's always visible
         const backBtnHeader = document.getElementById('backToLobbyBtnHeader');
         if (backBtnHeader) {
-            backBtnHeader.addEventListener('click', () => {
-                if (this.ws) {
-                    this.ws.close();
-                }
-                window.location.href = '/lobby.html';
-            });
+            backBtnHeader.addEventListener('click', () => this.leaveToLobby());
         }
         
         this.connect();
     }
 
     connect() {
-        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        this.ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
+        this.ws = new WebSocket(PlayerSession.gameSocketURL());
 
         this.ws.onopen = () => {
             console.log('WebSocket connected');
         };
 
         this.ws.onmessage = (event) => {
-            const msg = JSON.parse(event.data);
-            this.handleMessage(msg);
+            // Server may send multiple JSON messages separated by newlines
+            const messages = event.data.split('\n').filter(line => line.trim());
+            for (const line of messages) {
+                try {
+                    this.handleMessage(JSON.parse(line));
+                } catch (error) {
+                    console.error('Error parsing message:', error, 'Raw data:', line);
+                }
+            }
         };
 
         this.ws.onclose = () => {
             console.log('WebSocket closed');
+            PlayerSession.check();
         };
 
         this.ws.onerror = (error) => {
@@ -49,56 +54,82 @@ class ClickSpeedClient {
         };
     }
 
+    sendMessage(msg) {
+        if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+            this.ws.send(JSON.stringify(msg));
+        }
+    }
+
     handleMessage(msg) {
         switch (msg.type) {
+            case 'serverShutdown':
+                PlayerSession.showShutdownBanner(msg);
+                break;
+            case 'announcement':
+                PlayerSession.showAnnouncement(msg);
+                break;
             case 'welcome':
                 this.playerID = msg.playerId;
+                PlayerSession.saveReconnectToken(msg);
                 this.roomID = msg.roomId;
                 console.log('Welcome! Player ID:', this.playerID, 'Room:', this.roomID);
+                if (msg.spectator) {
+                    this.enterSpectatorMode();
+                }
+                if (msg.rules) {
+                    this.describeRules(msg.rules);
+                }
+                this.tournament = msg.tournament || null;
                 break;
             case 'clickSpeedState':
+                if (msg.state === 'ready' && document.getElementById('gameSummary').style.display === 'block') {
+                    // Rematch accepted - back to the game
+                    document.getElementById('gameSummary').style.display = 'none';
+                    document.querySelector('.game-area').style.display = 'block';
+                    document.querySelector('.game-header').style.display = 'block';
+                }
                 this.handleGameState(msg);
                 break;
             case 'clickGameSummary':
                 this.showGameSummary(msg);
                 break;
+            case 'rematchStatus':
+                this.updateRematchStatus(msg);
+                break;
+            case 'seriesStatus':
+                this.series = msg;
+                Results.renderSeriesStatus(msg, this.playerID);
+                if (document.getElementById('gameSummary').style.display === 'block') {
+                    document.getElementById('rematchStatus').textContent = Results.describeSeriesNext(msg);
+                }
+                break;
+            case 'seriesSummary':
+                Results.renderSeriesSummary(msg, this.playerID);
+                break;
+            case 'redirect':
+                window.location.replace(msg.url || '/');
+                break;
         }
     }
 
     handleGameState(msg) {
-        // Update scores and player names - ensure each player sees personalized view
-        if (msg.scores && msg.scores.length > 0) {
-            // Server sends scores in order: [Players[0], Players[1]]
-            // Always map server's Players[0] to player1 (left) and Players[1] to player2 (right)
-            if (msg.scores[0]) {
-                const isMe = msg.scores[0].playerId === this.playerID;
-                this.scores.player1 = msg.scores[0].score;
-                document.getElementById('player1Score').textContent = msg.scores[0].score;
-                // Show "You" for current player, actual name for opponent
-                document.getElementById('player1Name').textContent = isMe ? 'You' : msg.scores[0].name;
-                this.playerIDs.player1 = msg.scores[0].playerId;
-                // Store actual name (not "You") for use in results - CRITICAL for correct opponent name
-                this.playerNames.player1 = msg.scores[0].name;
-            }
-            
-            if (msg.scores[1]) {
-                const isMe = msg.scores[1].playerId === this.playerID;
-                this.scores.player2 = msg.scores[1].score;
-                document.getElementById('player2Score').textContent = msg.scores[1].score;
-                // Show "You" for current player, actual name for opponent
-                document.getElementById('player2Name').textContent = isMe ? 'You' : msg.scores[1].name;
-                this.playerIDs.player2 = msg.scores[1].playerId;
-                // Store actual name (not "You") for use in results - CRITICAL for correct opponent name
-                this.playerNames.player2 = msg.scores[1].name;
-            }
+        if (this.spectator) {
+            this.handleSpectatorState(msg);
+            return;
         }
 
+        // Update scores and player names - "You" for this player, real names for everyone else
+        Results.rememberNames(this.names, msg.scores);
+        Results.renderScoreboard(document.getElementById('scoreboard'), msg.scores, this.playerID);
+
+        this.updateRoundTimer(msg);
+
         // Create a unique key for this target position
         const targetKey = `${msg.targetX}-${msg.targetY}`;
 
         switch (msg.state) {
             case 'waiting':
-                this.showArenaOverlay('Waiting for opponent...');
+                this.showArenaOverlay('Waiting for players...');
                 break;
                 
             case 'ready':
@@ -124,9 +155,8 @@ class ClickSpeedClient {
                 this.waitingForTarget = false;
                 this.hasClicked = false;
                 this.currentTargetKey = null;
-                if (msg.roundResult && msg.scores) {
-                    // Pass scores array so showResults can get fresh names
-                    this.showResults(msg.roundResult, msg.scores);
+                if (msg.roundResult) {
+                    this.showResults(msg.roundResult);
                 }
                 break;
         }
@@ -223,7 +253,7 @@ class ClickSpeedClient {
         setTimeout(() => {
             if (this.currentState === 'playing') {
                 this.hideTarget();
-                this.showArenaOverlay('Waiting for opponent...');
+                this.showArenaOverlay('Waiting for the others...');
             }
         }, 300);
     }
@@ -245,57 +275,92 @@ class ClickSpeedClient {
         document.getElementById('arenaOverlay').style.display = 'none';
     }
 
-    showResults(result, scoresArray) {
+    enterSpectatorMode() {
+        this.spectator = true;
+        document.body.classList.add('spectating');
+        document.getElementById('spectatorBanner').style.display = 'block';
+    }
+
+    handleSpectatorState(msg) {
+        // Spectators see every player by name
+        Results.rememberNames(this.names, msg.scores);
+        Results.renderScoreboard(document.getElementById('scoreboard'), msg.scores, 0);
+        this.updateRoundTimer(msg);
+
+        const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(3)}s` : '—';
+        if (msg.state === 'playing') {
+            this.showArenaOverlay('Players are waiting for the target...');
+        } else if (msg.state === 'results' && msg.roundResult) {
+            this.showArenaOverlay(Results.describeRound(msg.roundResult, this.names, formatTime));
+        } else {
+            this.showArenaOverlay('Waiting for the next round...');
+        }
+    }
+
+    describeRules(rules) {
+        let text;
+        if (rules.mode === 'bestOf') {
+            text = `Best of <strong>${rules.count}</strong> rounds!`;
+        } else if (rules.mode === 'firstTo') {
+            text = `First to <strong>${rules.count}</strong> wins!`;
+        } else {
+            text = `<strong>${rules.count}</strong> rounds!`;
+        }
+        if (rules.suddenDeath) {
+            text += ' Sudden death on a tie.';
+        }
+        document.querySelector('.target-score').innerHTML = text;
+    }
+
+    updateRoundTimer(msg) {
+        // Server sends the time left so the countdown doesn't depend on clock sync
+        if (msg.state === 'playing' && msg.timeLeftMs) {
+            this.roundDeadline = Date.now() + msg.timeLeftMs;
+            if (!this.roundTimerInterval) {
+                this.roundTimerInterval = setInterval(() => this.renderRoundTimer(), 200);
+            }
+            this.renderRoundTimer();
+        } else {
+            this.stopRoundTimer();
+        }
+    }
+
+    renderRoundTimer() {
+        const timerEl = document.getElementById('roundTimer');
+        const secondsLeft = Math.max(0, Math.ceil((this.roundDeadline - Date.now()) / 1000));
+        timerEl.textContent = `⏱ ${secondsLeft}s`;
+        timerEl.classList.toggle('urgent', secondsLeft <= 5);
+        timerEl.style.display = 'block';
+    }
+
+    stopRoundTimer() {
+        if (this.roundTimerInterval) {
+            clearInterval(this.roundTimerInterval);
+            this.roundTimerInterval = null;
+        }
+        document.getElementById('roundTimer').style.display = 'none';
+    }
+
+    showResults(result) {
         this.roundActive = false;
         this.hideArenaOverlay();
         this.hideTarget();
         
         document.getElementById('resultsArea').style.display = 'block';
         
-        // Use fresh scores array to determine opponent name
-        if (!scoresArray || scoresArray.length < 2) {
-            console.error('No scores array provided to showResults');
-            return;
-        }
-        
-        const player1Id = scoresArray[0].playerId;
-        const player1Name = scoresArray[0].name;
-        const player2Id = scoresArray[1].playerId;
-        const player2Name = scoresArray[1].name;
-        
-        // Determine which player slot we are
-        const isPlayer1Slot = this.playerID === player1Id;
-        
-        // Map times and determine opponent name
-        let myTime, oppTime, opponentName;
-        
-        if (isPlayer1Slot) {
-            // We are player1 (server slot 0)
-            myTime = result.player1TimeMs;
-            oppTime = result.player2TimeMs;
-            opponentName = player2Name; // Other player's name
-        } else {
-            // We are player2 (server slot 1)
-            myTime = result.player2TimeMs;
-            oppTime = result.player1TimeMs;
-            opponentName = player1Name; // Other player's name
-        }
-        
-        document.getElementById('yourTime').textContent = `${(myTime / 1000).toFixed(3)}s`;
-        document.getElementById('opponentTime').textContent = `${(oppTime / 1000).toFixed(3)}s`;
-        document.getElementById('opponentNameResult').textContent = `${opponentName}:`;
+        // Everyone's time, fastest first
+        const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(3)}s` : '—';
+        Results.renderPlacements(document.getElementById('resultTimes'), result.results, this.names, this.playerID, formatTime);
         
         const resultTitle = document.getElementById('resultTitle');
-        // Use server's winnerId directly - ensures both clients show same result
-        if (!result.winnerId || result.winnerId === 0) {
-            resultTitle.textContent = "It's a tie!";
+        // Use server's winnerId directly - ensures every client shows the same result
+        resultTitle.textContent = Results.describeRoundWinner(result, this.names, this.playerID);
+        if (!result.winnerId) {
             resultTitle.style.color = '#f59e0b';
         } else if (result.winnerId === this.playerID) {
-            resultTitle.textContent = '🎯 You won this round!';
+            resultTitle.textContent = '🎯 ' + resultTitle.textContent;
             resultTitle.style.color = '#10b981';
         } else {
-            // Opponent won - use opponent's actual name
-            resultTitle.textContent = `${opponentName} won this round`;
             resultTitle.style.color = '#ef4444';
         }
     }
@@ -305,6 +370,8 @@ class ClickSpeedClient {
     }
 
     showGameSummary(summary) {
+        this.stopRoundTimer();
+
         // Hide game area completely
         document.querySelector('.game-area').style.display = 'none';
         document.querySelector('.game-header').style.display = 'none';
@@ -319,33 +386,24 @@ class ClickSpeedClient {
             console.error('Game summary div not found!');
         }
 
-        const isPlayer1 = this.playerID === summary.player1Id;
-        const myName = isPlayer1 ? summary.player1Name : summary.player2Name;
-        const opponentName = isPlayer1 ? summary.player2Name : summary.player1Name;
-        const myScore = isPlayer1 ? summary.player1Score : summary.player2Score;
-        const opponentScore = isPlayer1 ? summary.player2Score : summary.player1Score;
-        const myAvgTime = isPlayer1 ? summary.player1AvgTime : summary.player2AvgTime;
-        const opponentAvgTime = isPlayer1 ? summary.player2AvgTime : summary.player1AvgTime;
+        (summary.players || []).forEach(player => {
+            this.names[player.playerId] = player.name;
+        });
 
         const winnerDiv = document.getElementById('summaryWinner');
         if (summary.winnerId === this.playerID) {
             winnerDiv.textContent = '🎯 You Won! 🎯';
             winnerDiv.className = 'summary-winner winner';
         } else if (summary.winnerId > 0) {
-            winnerDiv.textContent = `${opponentName} Won!`;
+            winnerDiv.textContent = Results.describeGameWinner(summary, this.playerID);
             winnerDiv.className = 'summary-winner loser';
         } else {
             winnerDiv.textContent = "It's a Tie!";
             winnerDiv.className = 'summary-winner tie';
         }
 
-        document.getElementById('summaryPlayer1Name').textContent = myName;
-        document.getElementById('summaryPlayer1Score').textContent = `Score: ${myScore}`;
-        document.getElementById('summaryPlayer1Avg').textContent = `Avg: ${(myAvgTime / 1000).toFixed(3)}s`;
-
-        document.getElementById('summaryPlayer2Name').textContent = opponentName;
-        document.getElementById('summaryPlayer2Score').textContent = `Score: ${opponentScore}`;
-        document.getElementById('summaryPlayer2Avg').textContent = `Avg: ${(opponentAvgTime / 1000).toFixed(3)}s`;
+        const formatAvg = (t) => t > 0 ? `${(t / 1000).toFixed(3)}s` : '—';
+        Results.renderStandings(document.getElementById('summaryStandings'), summary.players, this.playerID, formatAvg);
 
         const roundsList = document.getElementById('roundsList');
         roundsList.innerHTML = '';
@@ -354,52 +412,25 @@ class ClickSpeedClient {
             const roundDiv = document.createElement('div');
             roundDiv.className = 'round-item';
             
-            // Server sends player1TimeMs and player2TimeMs based on room slots (Players[0] and Players[1])
-            // We need to map these correctly based on which player we are
-            // If we're player1 (summary.player1Id === this.playerID), then player1TimeMs is ours
-            const myTime = isPlayer1 ? round.player1TimeMs : round.player2TimeMs;
-            const oppTime = isPlayer1 ? round.player2TimeMs : round.player1TimeMs;
-            
-            // Use server's winnerId directly (don't recalculate) - ensures both clients show same result
-            const iWon = round.winnerId === this.playerID;
-            const theyWon = round.winnerId > 0 && round.winnerId !== this.playerID;
-            const winnerText = iWon ? 'You' : (theyWon ? opponentName : 'Tie');
+            const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(3)}s` : '—';
             
             roundDiv.innerHTML = `
                 <div class="round-header">
                     <span class="round-number">Round ${round.roundNumber}</span>
+                    ${round.timedOut ? '<span class="round-timeout">Time\'s up</span>' : ''}
                 </div>
                 <div class="round-times">
-                    <div class="round-time-item">
-                        <span class="round-time-label">${myName}</span>
-                        <span class="round-time-value ${iWon ? 'winner-text' : ''}">${(myTime / 1000).toFixed(3)}s</span>
-                    </div>
-                    <div class="round-time-item">
-                        <span class="round-time-label">${opponentName}</span>
-                        <span class="round-time-value ${theyWon ? 'winner-text' : ''}">${(oppTime / 1000).toFixed(3)}s</span>
-                    </div>
+                    ${Results.roundTimesHTML(round, this.names, this.playerID, formatTime)}
                 </div>
-                <div class="round-winner">Winner: ${winnerText}</div>
+                <div class="round-winner">Winner: ${Results.roundWinnerName(round, this.names, this.playerID)}</div>
             `;
             roundsList.appendChild(roundDiv);
         });
         
-        // CRITICAL: Setup back to lobby button - SIMPLE and DIRECT
+        this.setupRematchButtons();
+
         const backBtn = document.getElementById('backToLobbyBtn');
         if (backBtn) {
-            // Clear any existing onclick
-            backBtn.onclick = null;
-            
-            // Add click handler directly
-            backBtn.addEventListener('click', (e) => {
-                e.preventDefault();
-                e.stopPropagation();
-                if (this.ws) {
-                    this.ws.close();
-                }
-                window.location.href = '/lobby.html';
-            });
-            
             // Make absolutely sure it's visible
             backBtn.style.display = 'block';
             backBtn.style.visibility = 'visible';
@@ -408,6 +439,54 @@ class ClickSpeedClient {
             backBtn.disabled = false;
         }
     }
+
+    setupRematchButtons() {
+        const playAgainBtn = document.getElementById('playAgainBtn');
+        const backToLobbyBtn = document.getElementById('backToLobbyBtn');
+        if (this.tournament) {
+            // A tournament match is a single game, the winner moves on in the bracket
+            playAgainBtn.style.display = 'none';
+            document.getElementById('rematchStatus').textContent = 'Heading back to the bracket...';
+            backToLobbyBtn.textContent = 'Back to Bracket';
+            backToLobbyBtn.onclick = () => {
+                window.location.replace(`/tournament.html?code=${encodeURIComponent(this.tournament)}`);
+            };
+            return;
+        }
+        if (this.series) {
+            // A party series moves on to its next game by itself
+            playAgainBtn.style.display = 'none';
+            document.getElementById('rematchStatus').textContent = Results.describeSeriesNext(this.series);
+            backToLobbyBtn.onclick = () => this.leaveToLobby();
+            return;
+        }
+        playAgainBtn.disabled = false;
+        playAgainBtn.textContent = 'Play Again';
+        playAgainBtn.onclick = () => {
+            this.sendMessage({ type: 'readyForNewGame', ready: true });
+            playAgainBtn.disabled = true;
+            playAgainBtn.textContent = 'Waiting for the others...';
+        };
+        document.getElementById('rematchStatus').textContent = '';
+        backToLobbyBtn.onclick = () => this.leaveToLobby();
+    }
+
+    updateRematchStatus(msg) {
+        if (this.spectator) return;
+        const others = (msg.readyStatus || []).filter(status => status.playerId !== this.playerID);
+        const ready = others.filter(status => status.ready).length;
+        document.getElementById('rematchStatus').textContent =
+            ready > 0 ? `${ready} of ${others.length} other players want a rematch!` : '';
+    }
+
+    leaveToLobby() {
+        // Declining the rematch sends everyone back to the room's lobby
+        this.sendMessage({ type: 'readyForNewGame', ready: false });
+        if (this.ws) {
+            this.ws.close();
+        }
+        window.location.replace('/lobby.html');
+    }
 }
 
 // Start game when page loads
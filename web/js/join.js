@@ -0,0 +1,58 @@
+// Join a room from an invite link: /join/{token}
+document.addEventListener('DOMContentLoaded', async function() {
+    const token = decodeURIComponent(window.location.pathname.replace(/^\/join\//, ''));
+    const info = document.getElementById('inviteInfo');
+    const form = document.getElementById('joinForm');
+    const errorDiv = document.getElementById('error');
+
+    try {
+        const response = await fetch(`/api/invites/${encodeURIComponent(token)}`);
+        if (!response.ok) {
+            info.textContent = `${await response.text() || 'Invalid invite link'}. Ask for a new one.`;
+            return;
+        }
+        const invite = await response.json();
+        info.textContent = `${invite.host} invited you to room ${invite.roomCode}`;
+        form.style.display = 'block';
+    } catch (error) {
+        info.textContent = 'Connection error. Please try again.';
+        console.error('Invite error:', error);
+        return;
+    }
+
+    form.addEventListener('submit', async function(e) {
+        e.preventDefault();
+
+        const username = document.getElementById('username').value.trim();
+        const password = document.getElementById('password').value;
+        if (!username || !password) {
+            errorDiv.textContent = 'Name and password are required';
+            errorDiv.style.display = 'block';
+            return;
+        }
+
+        errorDiv.textContent = '';
+        errorDiv.style.display = 'none';
+
+        try {
+            const response = await fetch('/api/join', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ token, username, password }),
+            });
+
+            if (response.ok) {
+                window.location.href = '/lobby.html';
+            } else {
+                errorDiv.textContent = await response.text() || 'Joining failed';
+                errorDiv.style.display = 'block';
+            }
+        } catch (error) {
+            errorDiv.textContent = 'Connection error. Please try again.';
+            errorDiv.style.display = 'block';
+            console.error('Join error:', error);
+        }
+    });
+});
@@ -0,0 +1,113 @@
+// Leaderboard page: ranks players of one game by rating, and shows a
+// player's stats when their name is clicked
+class LeaderboardPage {
+    constructor() {
+        const params = new URLSearchParams(window.location.search);
+        const select = document.getElementById('leaderboardGame');
+        if (params.get('game')) {
+            select.value = params.get('game');
+        }
+        select.addEventListener('change', () => this.load(select.value));
+        this.load(select.value);
+
+        if (params.get('player')) {
+            this.showPlayer(params.get('player'));
+        }
+    }
+
+    formatTime(ms) {
+        if (!ms) return '-';
+        return `${(ms / 1000).toFixed(3)}s`;
+    }
+
+    async load(gameType) {
+        const message = document.getElementById('leaderboardMessage');
+        const rows = document.getElementById('leaderboardRows');
+        try {
+            const response = await fetch(`/api/leaderboard?game=${encodeURIComponent(gameType)}`);
+            if (!response.ok) {
+                message.textContent = await response.text();
+                rows.innerHTML = '';
+                return;
+            }
+            const board = await response.json();
+            message.textContent = board.players.length ? '' : 'No games played yet';
+            rows.innerHTML = '';
+            board.players.forEach(player => {
+                const row = document.createElement('tr');
+                row.innerHTML = `
+                    <td>${player.rank}</td>
+                    <td><a href="#" class="leaderboard-player">${Results.escape(player.name)}</a></td>
+                    <td>${player.rating}</td>
+                    <td>${player.wins}-${player.losses}-${player.draws}</td>
+                    <td>${this.formatTime(player.times.bestMs)}</td>
+                    <td>${this.formatTime(player.times.avgMs)}</td>
+                    <td>${this.formatTime(player.times.p50Ms)}</td>
+                    <td>${this.formatTime(player.times.p90Ms)}</td>
+                `;
+                row.querySelector('a').addEventListener('click', event => {
+                    event.preventDefault();
+                    this.showPlayer(player.name);
+                });
+                rows.appendChild(row);
+            });
+        } catch (error) {
+            message.textContent = 'Connection error. Please try again.';
+            console.error('Failed to load leaderboard:', error);
+        }
+    }
+
+    async showPlayer(name) {
+        try {
+            const response = await fetch(`/api/players/${encodeURIComponent(name)}/stats`);
+            if (!response.ok) {
+                console.error('No stats for player:', await response.text());
+                return;
+            }
+            this.renderPlayer(await response.json());
+        } catch (error) {
+            console.error('Failed to load player stats:', error);
+        }
+    }
+
+    renderPlayer(stats) {
+        document.getElementById('playerStats').style.display = 'block';
+        document.getElementById('playerStatsName').textContent = stats.name;
+
+        const games = document.getElementById('playerStatsGames');
+        games.innerHTML = '';
+        stats.games.forEach(record => {
+            const item = document.createElement('div');
+            item.className = 'player-stats-game';
+            item.innerHTML = `
+                <h3>${Results.escape(Results.gameName(record.gameType))}</h3>
+                <div>Rating: <strong>${record.rating}</strong></div>
+                <div>${record.games} games · ${record.wins} won · ${record.losses} lost · ${record.draws} drawn</div>
+                <div>Best ${this.formatTime(record.times.bestMs)} · Average ${this.formatTime(record.times.avgMs)}</div>
+                <div>Median ${this.formatTime(record.times.p50Ms)} · 90th ${this.formatTime(record.times.p90Ms)} · 99th ${this.formatTime(record.times.p99Ms)}</div>
+            `;
+            games.appendChild(item);
+        });
+
+        const recent = document.getElementById('playerStatsRecent');
+        recent.innerHTML = '';
+        stats.recentMatches.forEach(match => {
+            const item = document.createElement('div');
+            item.className = 'player-stats-match';
+            const when = new Date(match.endedAt).toLocaleString();
+            const result = match.winner === stats.name ? '🏆 Won' : `${Results.ordinal(match.place)} of ${match.players}`;
+            item.innerHTML = `
+                <span>${Results.escape(Results.gameName(match.gameType))}</span>
+                <span>${result}</span>
+                <span>Score ${match.score} · Avg ${this.formatTime(match.avgTimeMs)}</span>
+                <span class="player-stats-when">${Results.escape(when)}</span>
+            `;
+            recent.appendChild(item);
+        });
+        document.getElementById('playerStats').scrollIntoView({ behavior: 'smooth' });
+    }
+}
+
+window.addEventListener('DOMContentLoaded', () => {
+    window.leaderboardPage = new LeaderboardPage();
+});
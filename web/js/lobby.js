@@ -1,4 +1,9 @@
 // Lobby/Game Selection
+
+// Seats per room, matching the server's MinLobbyPlayers and MaxLobbyPlayers
+const MIN_PLAYERS = 2;
+const MAX_PLAYERS = 8;
+
 class LobbyClient {
     constructor() {
         this.ws = null;
@@ -6,12 +11,22 @@ class LobbyClient {
         this.players = [];
         this.selectedGame = null;
         this.selectedBy = null; // { playerId, name }
+        this.rules = null; // { mode, count, suddenDeath }
         this.isReady = false;
+        this.spectator = false;
+        this.spectatorCount = 0;
         this.reconnecting = false;
         this.roomCode = null;
+        this.tournament = null; // Tournament code when this lobby hosts a bracket match
+        this.playlist = null; // Games of the party series picked for this lobby
+        this.partyPicks = []; // Games picked in party mode, in order
         this.initWebSocket();
         this.setupGameSelection();
         this.setupReadyButton();
+        this.setupRulesControls();
+        this.setupPartyMode();
+        this.setupSessionBar();
+        this.setupInvites();
     }
 
     initWebSocket() {
@@ -70,9 +85,12 @@ class LobbyClient {
             // Only reconnect if we're not already reconnecting
             if (!this.reconnecting) {
                 this.reconnecting = true;
-                setTimeout(() => {
+                setTimeout(async () => {
                     this.reconnecting = false;
-                    this.initWebSocket();
+                    // A logged out session can't reconnect
+                    if (await PlayerSession.check()) {
+                        this.initWebSocket();
+                    }
                 }, 1000);
             }
         };
@@ -87,8 +105,17 @@ class LobbyClient {
     handleMessage(msg) {
         console.log('Received message:', msg.type, msg);
         switch (msg.type) {
+            case 'serverShutdown':
+                PlayerSession.showShutdownBanner(msg);
+                break;
+            case 'announcement':
+                PlayerSession.showAnnouncement(msg);
+                break;
             case 'welcome':
                 this.playerID = msg.playerId;
+                if (msg.spectator) {
+                    this.enterSpectatorMode();
+                }
                 if (msg.roomCode) {
                     this.roomCode = msg.roomCode;
                     this.displayRoomCode(msg.roomCode);
@@ -115,8 +142,16 @@ class LobbyClient {
             case 'gameSelected':
                 this.handleGameSelection(msg);
                 break;
+            case 'bracket':
+                this.renderBracket(msg.tournament);
+                break;
+            case 'queueStatus':
+                // Logged in for a quick match and not paired yet
+                window.location.replace('/quickmatch.html');
+                break;
             case 'gameStart':
                 console.log('Game starting:', msg.gameType);
+                PlayerSession.saveReconnectToken(msg);
                 if (this.ws) {
                     this.ws.close();
                 }
@@ -156,6 +191,15 @@ class LobbyClient {
         this.selectedGame = lobby.selectedGame || lobby.SelectedGame || null;
         this.selectedBy = lobby.selectedBy || lobby.SelectedBy || null;
         const lobbyState = lobby.state || lobby.State || 'waiting';
+        this.spectatorCount = lobby.spectators || 0;
+        this.playlist = lobby.playlist || null;
+        this.updatePlaylistDisplay();
+        if (lobby.rules) {
+            this.updateRules(lobby.rules);
+        }
+        if (lobby.tournament && lobby.tournament !== this.tournament) {
+            this.enterTournamentMode(lobby.tournament);
+        }
         
         console.log('Updated lobby state - players:', this.players.length, 'selectedGame:', this.selectedGame, 'selectedBy:', this.selectedBy, 'state:', lobbyState);
         
@@ -220,6 +264,11 @@ class LobbyClient {
             return;
         }
 
+        const countItem = document.createElement('div');
+        countItem.className = 'players-count';
+        countItem.textContent = `${this.players.length}/${MAX_PLAYERS} players`;
+        playersList.appendChild(countItem);
+
         this.players.forEach((player) => {
             const item = document.createElement('div');
             item.className = 'player-item';
@@ -228,6 +277,8 @@ class LobbyClient {
             const playerName = player.name || player.Name || 'Unknown';
             const isReady = player.ready || player.Ready || false;
             const isMe = playerID === this.playerID;
+            // Ratings are per game, so they show once a game is picked
+            const rating = this.selectedGame && player.ratings ? player.ratings[this.selectedGame] : null;
             item.innerHTML = `
                 <div class="player-avatar">${playerName.charAt(0).toUpperCase()}</div>
                 <div style="flex: 1;">
@@ -236,23 +287,141 @@ class LobbyClient {
                     </div>
                     ${isReady ? '<div style="font-size: 0.85em; color: #10b981; margin-top: 4px;">✓ Ready</div>' : ''}
                 </div>
+                ${rating ? `<div class="player-rating">★ ${rating}</div>` : ''}
             `;
             playersList.appendChild(item);
         });
         
-        // Show waiting message if only one player
-        if (this.players.length === 1) {
+        if (this.spectatorCount > 0) {
+            const spectatorItem = document.createElement('div');
+            spectatorItem.className = 'spectator-count';
+            spectatorItem.textContent = `👀 ${this.spectatorCount} watching`;
+            playersList.appendChild(spectatorItem);
+        }
+
+        // Show waiting message until the room has enough players to start
+        if (this.players.length < MIN_PLAYERS) {
             const waitingItem = document.createElement('div');
             waitingItem.className = 'player-item';
             waitingItem.style.opacity = '0.6';
             waitingItem.innerHTML = `
                 <div class="player-avatar" style="background: #ccc;">?</div>
-                <span class="player-name">Waiting for second player...</span>
+                <span class="player-name">Waiting for more players...</span>
             `;
             playersList.appendChild(waitingItem);
         }
     }
 
+    // The game and rules of a tournament match are set by the tournament
+    async enterTournamentMode(code) {
+        this.tournament = code;
+        document.body.classList.add('tournament-match');
+        document.getElementById('tournamentSection').style.display = 'block';
+        document.getElementById('bracketLink').href = `/tournament.html?code=${encodeURIComponent(code)}`;
+        try {
+            const response = await fetch(`/api/tournaments/${encodeURIComponent(code)}`);
+            if (response.ok) {
+                this.renderBracket(await response.json());
+            }
+        } catch (error) {
+            console.error('Failed to load bracket:', error);
+        }
+    }
+
+    renderBracket(tournament) {
+        if (!tournament) return;
+        Bracket.render(document.getElementById('lobbyBracket'), tournament, this.roomCode);
+    }
+
+    async setupSessionBar() {
+        document.getElementById('logoutLink').addEventListener('click', (e) => {
+            e.preventDefault();
+            PlayerSession.logout();
+        });
+        const session = await PlayerSession.current();
+        if (session) {
+            document.getElementById('sessionUser').textContent = `Logged in as ${session.username}`;
+        }
+    }
+
+    setupInvites() {
+        const linkInput = document.getElementById('inviteLink');
+        document.getElementById('inviteBtn').addEventListener('click', async () => {
+            if (!this.roomCode) return;
+            const response = await fetch(`/api/rooms/${encodeURIComponent(this.roomCode)}/invite`, { method: 'POST' });
+            if (!response.ok) {
+                alert(await response.text() || 'Failed to create an invite link');
+                return;
+            }
+            const invite = await response.json();
+            linkInput.value = `${window.location.origin}${invite.path}`;
+            linkInput.style.display = 'block';
+            linkInput.select();
+            // Copying needs a secure context, so the link stays selectable either way
+            if (navigator.clipboard) {
+                navigator.clipboard.writeText(linkInput.value).catch(() => {});
+            }
+        });
+        document.getElementById('revokeInvitesBtn').addEventListener('click', async () => {
+            if (!this.roomCode) return;
+            const response = await fetch(`/api/rooms/${encodeURIComponent(this.roomCode)}/invite`, { method: 'DELETE' });
+            if (response.ok) {
+                linkInput.value = '';
+                linkInput.style.display = 'none';
+                alert('Your invite links to this room no longer work');
+            }
+        });
+    }
+
+    setupPartyMode() {
+        const partyBtn = document.getElementById('partyBtn');
+        document.querySelectorAll('.party-pick').forEach(pick => {
+            pick.addEventListener('click', () => {
+                const gameType = pick.dataset.game;
+                const index = this.partyPicks.indexOf(gameType);
+                if (index >= 0) {
+                    this.partyPicks.splice(index, 1);
+                } else {
+                    this.partyPicks.push(gameType);
+                }
+                this.updatePartyPicks();
+            });
+        });
+        partyBtn.addEventListener('click', () => {
+            if (this.partyPicks.length < 2) return;
+            this.sendMessage({
+                type: 'setPlaylist',
+                gameTypes: this.partyPicks
+            });
+        });
+    }
+
+    // Numbers the picked games in play order
+    updatePartyPicks() {
+        document.querySelectorAll('.party-pick').forEach(pick => {
+            const index = this.partyPicks.indexOf(pick.dataset.game);
+            pick.classList.toggle('picked', index >= 0);
+            pick.dataset.order = index >= 0 ? index + 1 : '';
+        });
+        const partyBtn = document.getElementById('partyBtn');
+        partyBtn.disabled = this.partyPicks.length < 2;
+        partyBtn.textContent = this.partyPicks.length < 2 ? 'Pick at least two games' : 'Play Party Series';
+    }
+
+    gameTitle(gameType) {
+        const title = document.querySelector(`.game-card[data-game="${gameType}"] h3`);
+        return title ? title.textContent : gameType;
+    }
+
+    updatePlaylistDisplay() {
+        const display = document.getElementById('playlistDisplay');
+        if (!this.playlist) {
+            display.textContent = '';
+            return;
+        }
+        display.textContent = '🎉 Party series: ' + this.playlist.map(gameType => this.gameTitle(gameType)).join(' → ');
+    }
+
     updateReadySection() {
         const readySection = document.getElementById('readySection');
         
@@ -293,6 +462,33 @@ class LobbyClient {
         });
     }
 
+    setupRulesControls() {
+        const sendRules = () => {
+            const count = parseInt(document.getElementById('rulesCount').value, 10);
+            if (!count || count < 1) {
+                return;
+            }
+            this.sendMessage({
+                type: 'setRules',
+                rules: {
+                    mode: document.getElementById('rulesMode').value,
+                    count: count,
+                    suddenDeath: document.getElementById('rulesSuddenDeath').checked
+                }
+            });
+        };
+        document.getElementById('rulesMode').addEventListener('change', sendRules);
+        document.getElementById('rulesCount').addEventListener('change', sendRules);
+        document.getElementById('rulesSuddenDeath').addEventListener('change', sendRules);
+    }
+
+    updateRules(rules) {
+        this.rules = rules;
+        document.getElementById('rulesMode').value = rules.mode;
+        document.getElementById('rulesCount').value = rules.count;
+        document.getElementById('rulesSuddenDeath').checked = rules.suddenDeath;
+    }
+
     selectGame(gameType) {
         // Remove previous selection
         document.querySelectorAll('.game-card').forEach(card => {
@@ -371,6 +567,12 @@ class LobbyClient {
         }
     }
 
+    enterSpectatorMode() {
+        this.spectator = true;
+        document.body.classList.add('spectating');
+        document.getElementById('spectatorBanner').style.display = 'block';
+    }
+
     displayRoomCode(roomCode) {
         const roomCodeDisplay = document.getElementById('roomCodeDisplay');
         const roomCodeValue = document.getElementById('roomCodeValue');
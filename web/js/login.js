@@ -2,6 +2,33 @@ document.addEventListener('DOMContentLoaded', function() {
     const form = document.getElementById('loginForm');
     const errorDiv = document.getElementById('error');
     const roomCodeInput = document.getElementById('roomCode');
+    let registering = false;
+    let inviteRequired = false;
+
+    // Ask whether registering needs an invite code
+    fetch('/api/register')
+        .then(response => response.json())
+        .then(data => { inviteRequired = data.inviteRequired; })
+        .catch(error => console.error('Failed to load registration settings:', error));
+
+    // Switch between logging in and creating an account
+    document.getElementById('switchMode').addEventListener('click', function(e) {
+        e.preventDefault();
+        registering = !registering;
+        document.getElementById('inviteGroup').style.display = registering && inviteRequired ? 'block' : 'none';
+        document.getElementById('submitBtn').textContent = registering ? 'Create Account' : 'Login';
+        document.getElementById('switchText').textContent = registering ? 'Have an account?' : 'New here?';
+        e.target.textContent = registering ? 'Log in' : 'Create an account';
+        errorDiv.textContent = '';
+        errorDiv.style.display = 'none';
+    });
+
+    // Quick match swaps the room code for a game to be paired in
+    const quickMatchBox = document.getElementById('quickMatch');
+    quickMatchBox.addEventListener('change', function() {
+        document.getElementById('quickMatchGroup').style.display = quickMatchBox.checked ? 'block' : 'none';
+        document.getElementById('roomCodeGroup').style.display = quickMatchBox.checked ? 'none' : 'block';
+    });
 
     // Remove non-alphanumeric characters as user types (allow typing, just filter)
     roomCodeInput.addEventListener('input', function(e) {
@@ -35,7 +62,12 @@ document.addEventListener('DOMContentLoaded', function() {
             return;
         }
 
-        if (!roomCode || roomCode.length === 0) {
+        const quickMatch = quickMatchBox.checked ? {
+            gameType: document.getElementById('quickMatchGame').value,
+            byRating: document.getElementById('quickMatchByRating').checked
+        } : null;
+
+        if (!quickMatch && (!roomCode || roomCode.length === 0)) {
             errorDiv.textContent = 'Room code is required';
             errorDiv.style.display = 'block';
             return;
@@ -45,18 +77,37 @@ document.addEventListener('DOMContentLoaded', function() {
         errorDiv.style.display = 'none';
 
         try {
+            if (registering) {
+                const registered = await fetch('/api/register', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify({
+                        username,
+                        password,
+                        inviteCode: document.getElementById('inviteCode').value
+                    }),
+                });
+                if (!registered.ok) {
+                    errorDiv.textContent = await registered.text() || 'Registration failed';
+                    errorDiv.style.display = 'block';
+                    return;
+                }
+            }
+
             const response = await fetch('/api/login', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
                 },
-                body: JSON.stringify({ username, password, roomCode }),
+                body: JSON.stringify(quickMatch ? { username, password, quickMatch } : { username, password, roomCode }),
             });
 
             if (response.ok) {
                 const data = await response.json();
-                // Redirect to lobby page
-                window.location.href = '/lobby.html';
+                // Redirect to lobby page, or to wait for a quick match
+                window.location.href = data.quickMatch ? '/quickmatch.html' : '/lobby.html';
             } else {
                 const errorText = await response.text();
                 errorDiv.textContent = errorText || 'Login failed';
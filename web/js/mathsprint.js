@@ -9,28 +9,38 @@ class MathSprintClient {
         this.roundActive = false;
         this.countdownActive = false;
         this.hasSubmitted = false;
-        this.scores = { player1: 0, player2: 0 };
-        this.playerIDs = { player1: null, player2: null };
-        this.playerNames = { player1: 'You', player2: 'Opponent' };
+        this.spectator = false;
+        this.tournament = null; // Tournament code when this game is a bracket match
+        this.series = null; // Latest status of the party series this game is part of
+        this.roundDeadline = 0;
+        this.roundTimerInterval = null;
+        this.names = {}; // Player names keyed by ID
         
         this.connect();
     }
 
     connect() {
-        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        this.ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
+        this.ws = new WebSocket(PlayerSession.gameSocketURL());
 
         this.ws.onopen = () => {
             console.log('WebSocket connected');
         };
 
         this.ws.onmessage = (event) => {
-            const msg = JSON.parse(event.data);
-            this.handleMessage(msg);
+            // Server may send multiple JSON messages separated by newlines
+            const messages = event.data.split('\n').filter(line => line.trim());
+            for (const line of messages) {
+                try {
+                    this.handleMessage(JSON.parse(line));
+                } catch (error) {
+                    console.error('Error parsing message:', error, 'Raw data:', line);
+                }
+            }
         };
 
         this.ws.onclose = () => {
             console.log('WebSocket closed');
+            PlayerSession.check();
         };
 
         this.ws.onerror = (error) => {
@@ -49,45 +59,79 @@ class MathSprintClient {
         });
     }
 
+    sendMessage(msg) {
+        if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+            this.ws.send(JSON.stringify(msg));
+        }
+    }
+
     handleMessage(msg) {
         switch (msg.type) {
+            case 'serverShutdown':
+                PlayerSession.showShutdownBanner(msg);
+                break;
+            case 'announcement':
+                PlayerSession.showAnnouncement(msg);
+                break;
             case 'welcome':
                 this.playerID = msg.playerId;
+                PlayerSession.saveReconnectToken(msg);
                 this.roomID = msg.roomId;
                 console.log('Welcome! Player ID:', this.playerID, 'Room:', this.roomID);
+                if (msg.spectator) {
+                    this.enterSpectatorMode();
+                }
+                if (msg.rules) {
+                    this.describeRules(msg.rules);
+                }
+                this.tournament = msg.tournament || null;
                 break;
             case 'mathSprintState':
+                if (msg.state === 'ready' && document.getElementById('gameSummary').style.display === 'block') {
+                    // Rematch accepted - back to the game
+                    document.getElementById('gameSummary').style.display = 'none';
+                    document.querySelector('.game-area').style.display = 'block';
+                    document.querySelector('.game-header').style.display = 'block';
+                }
                 this.handleGameState(msg);
                 break;
             case 'mathGameSummary':
                 this.showGameSummary(msg);
                 break;
+            case 'rematchStatus':
+                this.updateRematchStatus(msg);
+                break;
+            case 'seriesStatus':
+                this.series = msg;
+                Results.renderSeriesStatus(msg, this.playerID);
+                if (document.getElementById('gameSummary').style.display === 'block') {
+                    document.getElementById('rematchStatus').textContent = Results.describeSeriesNext(msg);
+                }
+                break;
+            case 'seriesSummary':
+                Results.renderSeriesSummary(msg, this.playerID);
+                break;
+            case 'redirect':
+                window.location.replace(msg.url || '/');
+                break;
         }
     }
 
     handleGameState(msg) {
-        // Update scores and player names
-        if (msg.scores) {
-            msg.scores.forEach(score => {
-                if (score.playerId === this.playerID) {
-                    this.scores.player1 = score.score;
-                    document.getElementById('player1Score').textContent = score.score;
-                    document.getElementById('player1Name').textContent = 'You';
-                    this.playerIDs.player1 = score.playerId;
-                    this.playerNames.player1 = 'You';
-                } else {
-                    this.scores.player2 = score.score;
-                    document.getElementById('player2Score').textContent = score.score;
-                    document.getElementById('player2Name').textContent = score.name || 'Opponent';
-                    this.playerIDs.player2 = score.playerId;
-                    this.playerNames.player2 = score.name || 'Opponent';
-                }
-            });
+        if (this.spectator) {
+            this.handleSpectatorState(msg);
+            return;
         }
 
+        // Update scores and player names
+        Results.rememberNames(this.names, msg.scores);
+        Results.renderScoreboard(document.getElementById('scoreboard'), msg.scores, this.playerID);
+
+        this.updateRoundTimer(msg);
+
         switch (msg.state) {
             case 'waiting':
-                this.showStatusOverlay('Waiting for opponent...');
+                this.showStatusOverlay('Waiting for players...');
                 break;
                 
             case 'ready':
@@ -197,7 +241,78 @@ class MathSprintClient {
         input.classList.add('correct'); // Show green - server will validate
         
         // Show waiting message below but keep the input visible
-        document.querySelector('.input-hint').textContent = 'Waiting for opponent...';
+        document.querySelector('.input-hint').textContent = 'Waiting for the others...';
+    }
+
+    enterSpectatorMode() {
+        this.spectator = true;
+        document.body.classList.add('spectating');
+        document.getElementById('spectatorBanner').style.display = 'block';
+    }
+
+    handleSpectatorState(msg) {
+        // Spectators see every player by name
+        Results.rememberNames(this.names, msg.scores);
+        Results.renderScoreboard(document.getElementById('scoreboard'), msg.scores, 0);
+        this.updateRoundTimer(msg);
+        this.hideStatusOverlay();
+
+        const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
+        const questionText = document.getElementById('questionText');
+        document.getElementById('questionDisplay').style.display = 'block';
+        if (msg.state === 'playing') {
+            // The answer is only sent with the results
+            questionText.textContent = msg.question;
+        } else if (msg.state === 'results' && msg.roundResult) {
+            questionText.textContent = `${msg.question} = ${msg.roundResult.correctAnswer}`;
+            this.showStatusOverlay(Results.describeRound(msg.roundResult, this.names, formatTime));
+        } else {
+            questionText.textContent = 'Get ready...';
+        }
+    }
+
+    describeRules(rules) {
+        let text;
+        if (rules.mode === 'bestOf') {
+            text = `Best of <strong>${rules.count}</strong> rounds!`;
+        } else if (rules.mode === 'firstTo') {
+            text = `First to <strong>${rules.count}</strong> wins!`;
+        } else {
+            text = `<strong>${rules.count}</strong> rounds!`;
+        }
+        if (rules.suddenDeath) {
+            text += ' Sudden death on a tie.';
+        }
+        document.querySelector('.target-score').innerHTML = text;
+    }
+
+    updateRoundTimer(msg) {
+        // Server sends the time left so the countdown doesn't depend on clock sync
+        if (msg.state === 'playing' && msg.timeLeftMs) {
+            this.roundDeadline = Date.now() + msg.timeLeftMs;
+            if (!this.roundTimerInterval) {
+                this.roundTimerInterval = setInterval(() => this.renderRoundTimer(), 200);
+            }
+            this.renderRoundTimer();
+        } else {
+            this.stopRoundTimer();
+        }
+    }
+
+    renderRoundTimer() {
+        const timerEl = document.getElementById('roundTimer');
+        const secondsLeft = Math.max(0, Math.ceil((this.roundDeadline - Date.now()) / 1000));
+        timerEl.textContent = `⏱ ${secondsLeft}s`;
+        timerEl.classList.toggle('urgent', secondsLeft <= 5);
+        timerEl.style.display = 'block';
+    }
+
+    stopRoundTimer() {
+        if (this.roundTimerInterval) {
+            clearInterval(this.roundTimerInterval);
+            this.roundTimerInterval = null;
+        }
+        document.getElementById('roundTimer').style.display = 'none';
     }
 
     showResults(result) {
@@ -212,24 +327,19 @@ class MathSprintClient {
         // Reset input hint for next round
         document.querySelector('.input-hint').textContent = 'Press Enter to submit';
         
-        const isPlayer1 = this.playerID === this.playerIDs.player1;
-        const myTime = isPlayer1 ? result.player1TimeMs : result.player2TimeMs;
-        const oppTime = isPlayer1 ? result.player2TimeMs : result.player1TimeMs;
-        
-        document.getElementById('yourTime').textContent = `${(myTime / 1000).toFixed(2)}s`;
-        document.getElementById('opponentTime').textContent = `${(oppTime / 1000).toFixed(2)}s`;
-        document.getElementById('opponentNameResult').textContent = `${this.playerNames.player2}:`;
+        // Everyone's time, fastest first
+        const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
+        Results.renderPlacements(document.getElementById('resultTimes'), result.results, this.names, this.playerID, formatTime);
         document.getElementById('correctAnswer').textContent = `Answer: ${result.correctAnswer}`;
         
         const resultTitle = document.getElementById('resultTitle');
+        resultTitle.textContent = Results.describeRoundWinner(result, this.names, this.playerID);
         if (result.winnerId === this.playerID) {
-            resultTitle.textContent = '🎉 You won this round!';
+            resultTitle.textContent = '🎉 ' + resultTitle.textContent;
             resultTitle.style.color = '#10b981';
         } else if (result.winnerId > 0) {
-            resultTitle.textContent = `${this.playerNames.player2} won this round`;
             resultTitle.style.color = '#ef4444';
         } else {
-            resultTitle.textContent = "It's a tie!";
             resultTitle.style.color = '#f59e0b';
         }
     }
@@ -246,38 +356,30 @@ class MathSprintClient {
     }
 
     showGameSummary(summary) {
+        this.stopRoundTimer();
         document.querySelector('.game-area').style.display = 'none';
         document.querySelector('.game-header').style.display = 'none';
         document.getElementById('statusOverlay').style.display = 'none';
         document.getElementById('gameSummary').style.display = 'block';
 
-        const isPlayer1 = this.playerID === summary.player1Id;
-        const myName = isPlayer1 ? summary.player1Name : summary.player2Name;
-        const opponentName = isPlayer1 ? summary.player2Name : summary.player1Name;
-        const myScore = isPlayer1 ? summary.player1Score : summary.player2Score;
-        const opponentScore = isPlayer1 ? summary.player2Score : summary.player1Score;
-        const myAvgTime = isPlayer1 ? summary.player1AvgTime : summary.player2AvgTime;
-        const opponentAvgTime = isPlayer1 ? summary.player2AvgTime : summary.player1AvgTime;
+        (summary.players || []).forEach(player => {
+            this.names[player.playerId] = player.name;
+        });
 
         const winnerDiv = document.getElementById('summaryWinner');
         if (summary.winnerId === this.playerID) {
             winnerDiv.textContent = '🎉 You Won! 🎉';
             winnerDiv.className = 'summary-winner winner';
         } else if (summary.winnerId > 0) {
-            winnerDiv.textContent = `${opponentName} Won!`;
+            winnerDiv.textContent = Results.describeGameWinner(summary, this.playerID);
             winnerDiv.className = 'summary-winner loser';
         } else {
             winnerDiv.textContent = "It's a Tie!";
             winnerDiv.className = 'summary-winner tie';
         }
 
-        document.getElementById('summaryPlayer1Name').textContent = myName;
-        document.getElementById('summaryPlayer1Score').textContent = `Score: ${myScore}`;
-        document.getElementById('summaryPlayer1Avg').textContent = `Avg: ${(myAvgTime / 1000).toFixed(2)}s`;
-
-        document.getElementById('summaryPlayer2Name').textContent = opponentName;
-        document.getElementById('summaryPlayer2Score').textContent = `Score: ${opponentScore}`;
-        document.getElementById('summaryPlayer2Avg').textContent = `Avg: ${(opponentAvgTime / 1000).toFixed(2)}s`;
+        const formatAvg = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
+        Results.renderStandings(document.getElementById('summaryStandings'), summary.players, this.playerID, formatAvg);
 
         const roundsList = document.getElementById('roundsList');
         roundsList.innerHTML = '';
@@ -286,42 +388,71 @@ class MathSprintClient {
             const roundDiv = document.createElement('div');
             roundDiv.className = 'round-item';
             
-            const myTime = isPlayer1 ? round.player1TimeMs : round.player2TimeMs;
-            const oppTime = isPlayer1 ? round.player2TimeMs : round.player1TimeMs;
-            const iWon = round.winnerId === this.playerID;
-            const theyWon = round.winnerId > 0 && round.winnerId !== this.playerID;
-            const winnerText = iWon ? 'You' : (theyWon ? opponentName : 'Tie');
+            const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
             
             roundDiv.innerHTML = `
                 <div class="round-header">
                     <span class="round-number">Round ${round.roundNumber}</span>
                     <span class="round-word">${round.question} = ${round.answer}</span>
+                    ${round.timedOut ? '<span class="round-timeout">Time\'s up</span>' : ''}
                 </div>
                 <div class="round-times">
-                    <div class="round-time-item">
-                        <span class="round-time-label">${myName}</span>
-                        <span class="round-time-value ${iWon ? 'winner-text' : ''}">${(myTime / 1000).toFixed(2)}s</span>
-                    </div>
-                    <div class="round-time-item">
-                        <span class="round-time-label">${opponentName}</span>
-                        <span class="round-time-value ${theyWon ? 'winner-text' : ''}">${(oppTime / 1000).toFixed(2)}s</span>
-                    </div>
+                    ${Results.roundTimesHTML(round, this.names, this.playerID, formatTime)}
                 </div>
-                <div class="round-winner">Winner: ${winnerText}</div>
+                <div class="round-winner">Winner: ${Results.roundWinnerName(round, this.names, this.playerID)}</div>
             `;
             roundsList.appendChild(roundDiv);
         });
         
-        // Setup back to lobby button
-        const backBtn = document.getElementById('backToLobbyBtn');
-        if (backBtn) {
-            backBtn.onclick = () => {
-                if (this.ws) {
-                    this.ws.close();
-                }
-                window.location.replace('/lobby.html');
+        this.setupRematchButtons();
+    }
+
+    setupRematchButtons() {
+        const playAgainBtn = document.getElementById('playAgainBtn');
+        const backToLobbyBtn = document.getElementById('backToLobbyBtn');
+        if (this.tournament) {
+            // A tournament match is a single game, the winner moves on in the bracket
+            playAgainBtn.style.display = 'none';
+            document.getElementById('rematchStatus').textContent = 'Heading back to the bracket...';
+            backToLobbyBtn.textContent = 'Back to Bracket';
+            backToLobbyBtn.onclick = () => {
+                window.location.replace(`/tournament.html?code=${encodeURIComponent(this.tournament)}`);
             };
+            return;
+        }
+        if (this.series) {
+            // A party series moves on to its next game by itself
+            playAgainBtn.style.display = 'none';
+            document.getElementById('rematchStatus').textContent = Results.describeSeriesNext(this.series);
+            backToLobbyBtn.onclick = () => this.leaveToLobby();
+            return;
+        }
+        playAgainBtn.disabled = false;
+        playAgainBtn.textContent = 'Play Again';
+        playAgainBtn.onclick = () => {
+            this.sendMessage({ type: 'readyForNewGame', ready: true });
+            playAgainBtn.disabled = true;
+            playAgainBtn.textContent = 'Waiting for the others...';
+        };
+        document.getElementById('rematchStatus').textContent = '';
+        backToLobbyBtn.onclick = () => this.leaveToLobby();
+    }
+
+    updateRematchStatus(msg) {
+        if (this.spectator) return;
+        const others = (msg.readyStatus || []).filter(status => status.playerId !== this.playerID);
+        const ready = others.filter(status => status.ready).length;
+        document.getElementById('rematchStatus').textContent =
+            ready > 0 ? `${ready} of ${others.length} other players want a rematch!` : '';
+    }
+
+    leaveToLobby() {
+        // Declining the rematch sends everyone back to the room's lobby
+        this.sendMessage({ type: 'readyForNewGame', ready: false });
+        if (this.ws) {
+            this.ws.close();
         }
+        window.location.replace('/lobby.html');
     }
 }
 
@@ -0,0 +1,98 @@
+// Quick match: waits in the server's queue until paired, then follows the
+// game start to the game page like the lobby does
+
+const QUICK_MATCH_GAMES = {
+    speedtype: { title: 'Speed Type', page: '/speedtype.html' },
+    mathsprint: { title: 'Quick Math', page: '/mathsprint.html' },
+    clickspeed: { title: 'Click Speed', page: '/clickspeed.html' }
+};
+
+class QuickMatchClient {
+    constructor() {
+        this.ws = null;
+        this.matched = false;
+        this.initWebSocket();
+    }
+
+    initWebSocket() {
+        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+        this.ws = new WebSocket(`${protocol}//${window.location.host}/ws`);
+
+        this.ws.onmessage = (event) => {
+            // Server may send multiple JSON messages separated by newlines
+            for (const line of event.data.trim().split('\n')) {
+                if (!line.trim()) continue;
+                try {
+                    this.handleMessage(JSON.parse(line));
+                } catch (error) {
+                    console.error('Error parsing message:', error, 'Raw data:', line);
+                }
+            }
+        };
+
+        this.ws.onclose = () => {
+            if (this.matched) return;
+            // Rejoin the queue unless the session has ended
+            setTimeout(async () => {
+                if (await PlayerSession.check()) {
+                    this.initWebSocket();
+                }
+            }, 1000);
+        };
+    }
+
+    handleMessage(msg) {
+        switch (msg.type) {
+            case 'serverShutdown':
+                PlayerSession.showShutdownBanner(msg);
+                break;
+            case 'announcement':
+                PlayerSession.showAnnouncement(msg);
+                break;
+            case 'queueStatus':
+                this.updateStatus(msg);
+                break;
+            case 'welcome':
+                // A session that already has a room code belongs in its lobby
+                if (msg.lobby) {
+                    this.matched = true;
+                    window.location.replace('/lobby.html');
+                }
+                break;
+            case 'gameStart': {
+                const game = QUICK_MATCH_GAMES[msg.gameType];
+                if (!game) {
+                    console.error('Unknown game type:', msg.gameType);
+                    return;
+                }
+                this.matched = true;
+                PlayerSession.saveReconnectToken(msg);
+                document.getElementById('queueWaiting').textContent = 'Match found!';
+                this.ws.close();
+                window.location.replace(game.page);
+                break;
+            }
+        }
+    }
+
+    updateStatus(msg) {
+        const game = QUICK_MATCH_GAMES[msg.gameType];
+        document.getElementById('queueTitle').textContent = `Finding a ${game ? game.title : msg.gameType} Match`;
+
+        const others = msg.waiting - 1;
+        document.getElementById('queueWaiting').textContent = others > 0
+            ? `${others} other player${others === 1 ? '' : 's'} looking for this game`
+            : 'Waiting for another player...';
+
+        document.getElementById('queueRating').textContent = msg.byRating
+            ? `Your rating: ${msg.rating} · opponents within ±${msg.ratingRange}`
+            : `Your rating: ${msg.rating} · playing anyone`;
+
+        const seconds = Math.floor(msg.waitedMs / 1000);
+        document.getElementById('queueElapsed').textContent = `Searching for ${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')}`;
+    }
+}
+
+window.addEventListener('DOMContentLoaded', () => {
+    new QuickMatchClient();
+});
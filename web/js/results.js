@@ -0,0 +1,194 @@
+// Shared rendering for the minigame pages. Rounds and games are ranked
+// across every player in the room, so scores, round results and summaries
+// are all lists ordered by the server.
+const Results = {
+    ordinal(place) {
+        const suffixes = { 1: 'st', 2: 'nd', 3: 'rd' };
+        const tens = place % 100;
+        const suffix = (tens >= 11 && tens <= 13) ? 'th' : (suffixes[place % 10] || 'th');
+        return `${place}${suffix}`;
+    },
+
+    escape(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    },
+
+    // Remembers every player's name from a state message's scores
+    rememberNames(names, scores) {
+        (scores || []).forEach(score => {
+            names[score.playerId] = score.name;
+        });
+    },
+
+    nameOf(names, playerID, myID) {
+        if (playerID === myID) return 'You';
+        return names[playerID] || `Player ${playerID}`;
+    },
+
+    // Scoreboard in the game header, in seat order
+    renderScoreboard(container, scores, myID) {
+        container.innerHTML = '';
+        (scores || []).forEach(score => {
+            const item = document.createElement('div');
+            item.className = 'player-score' + (score.playerId === myID ? ' me' : '');
+            item.innerHTML = `
+                <span class="player-name">${this.escape(score.playerId === myID ? 'You' : score.name)}</span>
+                <span class="score-value">${score.score}</span>
+            `;
+            container.appendChild(item);
+        });
+    },
+
+    // Ranked list of everyone's time in a round
+    renderPlacements(container, results, names, myID, formatTime) {
+        container.innerHTML = '';
+        (results || []).forEach(result => {
+            const row = document.createElement('div');
+            row.className = 'time-result' + (result.playerId === myID ? ' me' : '');
+            const place = result.place > 0 ? this.ordinal(result.place) : '—';
+            const points = result.points > 0 ? `+${result.points}` : '';
+            row.innerHTML = `
+                <span class="time-place">${place}</span>
+                <span class="time-label">${this.escape(this.nameOf(names, result.playerId, myID))}</span>
+                <span class="time-value">${formatTime(result.timeMs)}</span>
+                <span class="time-points">${points}</span>
+            `;
+            container.appendChild(row);
+        });
+    },
+
+    describeRoundWinner(result, names, myID) {
+        let text;
+        if (result.winnerId && result.winnerId === myID) {
+            text = 'You won this round!';
+        } else if (result.winnerId > 0) {
+            text = `${this.nameOf(names, result.winnerId, myID)} won this round`;
+        } else {
+            return result.timedOut ? "Time's up - no winner" : 'Round ended - no winner';
+        }
+        return result.timedOut ? "Time's up! " + text : text;
+    },
+
+    // One line summary of a round, used by spectators
+    describeRound(result, names, formatTime) {
+        const times = (result.results || [])
+            .map(r => `${this.nameOf(names, r.playerId, 0)}: ${formatTime(r.timeMs)}`)
+            .join(', ');
+        return `${times} - ${this.describeRoundWinner(result, names, 0)}`;
+    },
+
+    describeGameWinner(summary, myID) {
+        if (summary.winnerId === myID) return '🎉 You Won! 🎉';
+        const winner = (summary.players || []).find(p => p.playerId === summary.winnerId);
+        if (winner) return `${winner.name} Won!`;
+        return "It's a Tie!";
+    },
+
+    // Final standings on the summary screen
+    renderStandings(container, players, myID, formatTime) {
+        container.innerHTML = '';
+        (players || []).forEach(player => {
+            const item = document.createElement('div');
+            item.className = 'summary-player' + (player.playerId === myID ? ' me' : '');
+            item.innerHTML = `
+                <div class="summary-player-place">${this.ordinal(player.place)}</div>
+                <div class="summary-player-name">${this.escape(player.playerId === myID ? 'You' : player.name)}</div>
+                <div class="summary-player-score">Score: ${player.score}</div>
+                <div class="summary-player-avg">Avg Time: ${formatTime(player.avgTimeMs)}</div>
+                ${player.rating ? this.ratingHTML(player) : ''}
+            `;
+            container.appendChild(item);
+        });
+    },
+
+    // A player's new skill rating and how far it moved
+    ratingHTML(player) {
+        const change = player.ratingChange || 0;
+        const direction = change > 0 ? 'up' : change < 0 ? 'down' : '';
+        const sign = change > 0 ? '+' : '';
+        return `<div class="summary-player-rating">Rating: ${player.rating} <span class="rating-change ${direction}">(${sign}${change})</span></div>`;
+    },
+
+    // Times for one round of the summary breakdown
+    roundTimesHTML(round, names, myID, formatTime) {
+        return (round.results || []).map(result => `
+            <div class="round-time-item">
+                <span class="round-time-label">${result.place > 0 ? this.ordinal(result.place) + ' ' : ''}${this.escape(this.nameOf(names, result.playerId, myID))}:</span>
+                <span class="round-time-value">${formatTime(result.timeMs)}</span>
+            </div>
+        `).join('');
+    },
+
+    roundWinnerName(round, names, myID) {
+        if (round.winnerId > 0) return this.nameOf(names, round.winnerId, myID);
+        return round.timedOut ? 'None' : 'Tie';
+    },
+
+    gameNames: {
+        'speedtype': 'Speed Type',
+        'mathsprint': 'Quick Math',
+        'clickspeed': 'Click Speed'
+    },
+
+    gameName(gameType) {
+        return this.gameNames[gameType] || gameType;
+    },
+
+    // Banner with the party series game being played and everyone's total
+    renderSeriesStatus(status, myID) {
+        const banner = document.getElementById('seriesBanner');
+        const totals = (status.players || [])
+            .map(p => `${this.escape(p.playerId === myID ? 'You' : p.name)}: ${p.points}`)
+            .join(' · ');
+        banner.innerHTML = `🎉 Party game ${status.game + 1} of ${status.playlist.length}: ` +
+            `${this.gameName(status.playlist[status.game])} · ${totals}`;
+        banner.style.display = 'block';
+    },
+
+    // What follows a game's summary in a party series
+    describeSeriesNext(status) {
+        if (status.game + 1 >= status.playlist.length) return 'That was the last game of the series!';
+        return `Next up: ${this.gameName(status.playlist[status.game + 1])}...`;
+    },
+
+    // Final series standings and the winner of each game
+    renderSeriesSummary(summary, myID) {
+        const players = summary.players || [];
+        let title = 'The series is a tie!';
+        if (summary.winnerId === myID) {
+            title = '🏆 You won the series!';
+        } else {
+            const winner = players.find(p => p.playerId === summary.winnerId);
+            if (winner) title = `🏆 ${winner.name} won the series!`;
+        }
+        document.getElementById('seriesWinner').textContent = title;
+
+        const names = {};
+        players.forEach(p => { names[p.playerId] = p.name; });
+
+        const standings = document.getElementById('seriesStandings');
+        standings.innerHTML = '';
+        players.forEach(player => {
+            const item = document.createElement('div');
+            item.className = 'summary-player' + (player.playerId === myID ? ' me' : '');
+            item.innerHTML = `
+                <div class="summary-player-place">${this.ordinal(player.place)}</div>
+                <div class="summary-player-name">${this.escape(player.playerId === myID ? 'You' : player.name)}</div>
+                <div class="summary-player-score">${player.points} pts</div>
+                <div class="summary-player-avg">Games won: ${player.gamesWon}</div>
+            `;
+            standings.appendChild(item);
+        });
+
+        document.getElementById('seriesGames').innerHTML = (summary.games || []).map(g => `
+            <div class="series-game">
+                <span>${this.gameName(g.gameType)}</span>
+                <span>${g.winnerId > 0 ? this.escape(this.nameOf(names, g.winnerId, myID)) : 'Tie'}</span>
+            </div>
+        `).join('');
+
+        document.getElementById('seriesSummary').style.display = 'block';
+    },
+};
@@ -0,0 +1,82 @@
+// Login session helpers shared by the lobby and game pages
+const PlayerSession = {
+    // Sends the player to the login page if their session has ended, e.g.
+    // after logging out in another tab. Resolves to whether it is still live.
+    async check() {
+        try {
+            const response = await fetch('/api/session');
+            if (response.status === 401) {
+                window.location.href = '/';
+                return false;
+            }
+        } catch (error) {
+            console.error('Session check error:', error);
+        }
+        return true;
+    },
+
+    async current() {
+        const response = await fetch('/api/session');
+        return response.ok ? response.json() : null;
+    },
+
+    // A game seat is reclaimed with the secret reconnect token sent in the
+    // gameStart and welcome messages, kept for this tab only
+    saveReconnectToken(msg) {
+        if (msg.reconnectToken) {
+            sessionStorage.setItem('reconnectToken', msg.reconnectToken);
+        }
+    },
+
+    // WebSocket URL for a game page, carrying the reconnect token if there is one
+    gameSocketURL() {
+        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+        const token = sessionStorage.getItem('reconnectToken');
+        const query = token ? `?token=${encodeURIComponent(token)}` : '';
+        return `${protocol}//${window.location.host}/ws${query}`;
+    },
+
+    // Warns that the server is restarting, counting down to when games
+    // still running are ended
+    showShutdownBanner(msg) {
+        let banner = document.getElementById('shutdownBanner');
+        if (!banner) {
+            banner = document.createElement('div');
+            banner.id = 'shutdownBanner';
+            banner.className = 'shutdown-banner';
+            document.body.prepend(banner);
+        }
+        clearInterval(this.shutdownTimer);
+        const deadline = Date.now() + Math.max(0, msg.inMs || 0);
+        const update = () => {
+            const seconds = Math.ceil((deadline - Date.now()) / 1000);
+            if (seconds > 0) {
+                banner.textContent = `The server is restarting - games still running end in ${seconds}s`;
+            } else {
+                banner.textContent = 'The server is restarting - log in again in a moment';
+                clearInterval(this.shutdownTimer);
+            }
+        };
+        update();
+        this.shutdownTimer = setInterval(update, 1000);
+    },
+
+    // Shows a message from the server admin until it is dismissed
+    showAnnouncement(msg) {
+        const banner = document.createElement('div');
+        banner.className = 'announcement-banner';
+        banner.textContent = msg.message;
+        banner.title = 'Click to dismiss';
+        banner.addEventListener('click', () => banner.remove());
+        document.body.prepend(banner);
+    },
+
+    async logout() {
+        try {
+            await fetch('/api/logout', { method: 'POST' });
+        } catch (error) {
+            console.error('Logout error:', error);
+        }
+        window.location.href = '/';
+    }
+};
@@ -5,21 +5,21 @@ class SpeedTypeClient {
         this.playerID = 0;
         this.currentWord = '';
         this.startTime = null;
-        this.scores = { player1: 0, player2: 0 };
-        this.playerNames = { player1: 'Player 1', player2: 'Player 2' };
-        this.playerIDs = { player1: 0, player2: 0 };
+        this.names = {}; // Player names keyed by ID
         this.roundActive = false;
         this.currentState = '';
         this.countdownActive = false;
+        this.spectator = false;
+        this.tournament = null; // Tournament code when this game is a bracket match
+        this.series = null; // Latest status of the party series this game is part of
+        this.roundDeadline = 0;
+        this.roundTimerInterval = null;
         this.initWebSocket();
         this.setupInput();
     }
 
     initWebSocket() {
-        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/ws`;
-        
-        this.ws = new WebSocket(wsUrl);
+        this.ws = new WebSocket(PlayerSession.gameSocketURL());
 
         this.ws.onopen = () => {
             console.log('WebSocket connected - waiting for welcome message');
@@ -49,7 +49,12 @@ class SpeedTypeClient {
 
         this.ws.onclose = () => {
             console.log('WebSocket closed');
-            setTimeout(() => this.initWebSocket(), 1000);
+            setTimeout(async () => {
+                // A logged out session can't reconnect
+                if (await PlayerSession.check()) {
+                    this.initWebSocket();
+                }
+            }, 1000);
         };
     }
 
@@ -61,9 +66,23 @@ class SpeedTypeClient {
 
     handleMessage(msg) {
         switch (msg.type) {
+            case 'serverShutdown':
+                PlayerSession.showShutdownBanner(msg);
+                break;
+            case 'announcement':
+                PlayerSession.showAnnouncement(msg);
+                break;
             case 'welcome':
                 this.playerID = msg.playerId;
+                PlayerSession.saveReconnectToken(msg);
                 console.log('Welcome received: playerId=', msg.playerId, 'roomId=', msg.roomId);
+                if (msg.spectator) {
+                    this.enterSpectatorMode();
+                }
+                if (msg.rules) {
+                    this.describeRules(msg.rules);
+                }
+                this.tournament = msg.tournament || null;
                 if (msg.roomId) {
                     this.hideStatusOverlay();
                 } else {
@@ -71,11 +90,24 @@ class SpeedTypeClient {
                     console.log('No roomId in welcome - waiting for state message');
                 }
                 break;
+            case 'seriesStatus':
+                this.series = msg;
+                Results.renderSeriesStatus(msg, this.playerID);
+                if (document.getElementById('gameSummary').style.display === 'block') {
+                    document.getElementById('rematchStatus').textContent = Results.describeSeriesNext(msg);
+                }
+                break;
+            case 'seriesSummary':
+                Results.renderSeriesSummary(msg, this.playerID);
+                break;
             case 'redirect':
-                // Server is redirecting us to login
-                console.log('Redirecting to login:', msg.url);
+                // Server is sending us elsewhere, e.g. back to the lobby after a declined rematch
+                console.log('Redirecting to:', msg.url);
                 window.location.replace(msg.url || '/');
                 break;
+            case 'rematchStatus':
+                this.updateRematchStatus(msg);
+                break;
             case 'lobby':
             case 'lobbyUpdate':
                 // Received lobby update while in game - redirect to login
@@ -97,25 +129,17 @@ class SpeedTypeClient {
     }
 
     handleGameState(msg) {
-        // Update scores and player names/IDs
-        if (msg.scores) {
-            msg.scores.forEach(score => {
-                if (score.playerId === this.playerID) {
-                    this.scores.player1 = score.score;
-                    document.getElementById('player1Score').textContent = score.score;
-                    document.getElementById('player1Name').textContent = 'You';
-                    this.playerIDs.player1 = score.playerId;
-                    this.playerNames.player1 = 'You';
-                } else {
-                    this.scores.player2 = score.score;
-                    document.getElementById('player2Score').textContent = score.score;
-                    document.getElementById('player2Name').textContent = score.name || 'Opponent';
-                    this.playerIDs.player2 = score.playerId;
-                    this.playerNames.player2 = score.name || 'Opponent';
-                }
-            });
+        if (this.spectator) {
+            this.handleSpectatorState(msg);
+            return;
         }
 
+        // Update scores and player names
+        Results.rememberNames(this.names, msg.scores);
+        Results.renderScoreboard(document.getElementById('scoreboard'), msg.scores, this.playerID);
+
+        this.updateRoundTimer(msg);
+
         // Show results if we receive a results state with roundResult
         if (msg.state === 'results' && msg.roundResult) {
             this.currentState = 'results';
@@ -131,7 +155,7 @@ class SpeedTypeClient {
             switch (msg.state) {
                 case 'waiting':
                     if (document.getElementById('gameSummary').style.display !== 'block') {
-                        this.showStatusOverlay('Waiting for opponent...');
+                        this.showStatusOverlay('Waiting for players...');
                     }
                     this.countdownActive = false;
                     break;
@@ -171,6 +195,73 @@ class SpeedTypeClient {
         }
     }
 
+    enterSpectatorMode() {
+        this.spectator = true;
+        document.body.classList.add('spectating');
+        document.getElementById('spectatorBanner').style.display = 'block';
+    }
+
+    handleSpectatorState(msg) {
+        // Spectators see every player by name
+        Results.rememberNames(this.names, msg.scores);
+        Results.renderScoreboard(document.getElementById('scoreboard'), msg.scores, 0);
+        this.updateRoundTimer(msg);
+
+        const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
+        if (msg.state === 'playing') {
+            // The word is withheld from spectators until the results
+            this.showStatusOverlay('Players are typing...');
+        } else if (msg.state === 'results' && msg.roundResult) {
+            this.showStatusOverlay(`"${msg.word}" - ` + Results.describeRound(msg.roundResult, this.names, formatTime));
+        } else {
+            this.showStatusOverlay('Waiting for the next round...');
+        }
+    }
+
+    describeRules(rules) {
+        let text;
+        if (rules.mode === 'bestOf') {
+            text = `Best of <strong>${rules.count}</strong> rounds!`;
+        } else if (rules.mode === 'firstTo') {
+            text = `First to <strong>${rules.count}</strong> wins!`;
+        } else {
+            text = `<strong>${rules.count}</strong> rounds!`;
+        }
+        if (rules.suddenDeath) {
+            text += ' Sudden death on a tie.';
+        }
+        document.querySelector('.target-score').innerHTML = text;
+    }
+
+    updateRoundTimer(msg) {
+        // Server sends the time left so the countdown doesn't depend on clock sync
+        if (msg.state === 'playing' && msg.timeLeftMs) {
+            this.roundDeadline = Date.now() + msg.timeLeftMs;
+            if (!this.roundTimerInterval) {
+                this.roundTimerInterval = setInterval(() => this.renderRoundTimer(), 200);
+            }
+            this.renderRoundTimer();
+        } else {
+            this.stopRoundTimer();
+        }
+    }
+
+    renderRoundTimer() {
+        const timerEl = document.getElementById('roundTimer');
+        const secondsLeft = Math.max(0, Math.ceil((this.roundDeadline - Date.now()) / 1000));
+        timerEl.textContent = `⏱ ${secondsLeft}s`;
+        timerEl.classList.toggle('urgent', secondsLeft <= 5);
+        timerEl.style.display = 'block';
+    }
+
+    stopRoundTimer() {
+        if (this.roundTimerInterval) {
+            clearInterval(this.roundTimerInterval);
+            this.roundTimerInterval = null;
+        }
+        document.getElementById('roundTimer').style.display = 'none';
+    }
+
     startRound(word) {
         if (word !== this.currentWord) {
             this.currentWord = word;
@@ -294,41 +385,17 @@ class SpeedTypeClient {
         const resultsArea = document.getElementById('resultsArea');
         resultsArea.style.display = 'block';
 
-        const player1Time = result.player1TimeMs || 0;
-        const player2Time = result.player2TimeMs || 0;
-        
-        let yourTime = 0;
-        let opponentTime = 0;
-        
-        if (this.playerIDs.player1 === this.playerID) {
-            yourTime = player1Time;
-            opponentTime = player2Time;
-        } else if (this.playerIDs.player2 === this.playerID) {
-            yourTime = player2Time;
-            opponentTime = player1Time;
-        } else {
-            yourTime = (result.winnerId === this.playerID) ? player1Time : player2Time;
-            opponentTime = (result.winnerId === this.playerID) ? player2Time : player1Time;
-        }
-
-        document.getElementById('yourTime').textContent = yourTime > 0 ? (yourTime / 1000).toFixed(2) + 's' : '0.00s';
-        document.getElementById('opponentTime').textContent = opponentTime > 0 ? (opponentTime / 1000).toFixed(2) + 's' : '0.00s';
+        // Everyone's time, fastest first
+        const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
+        Results.renderPlacements(document.getElementById('resultTimes'), result.results, this.names, this.playerID, formatTime);
 
         const winnerDiv = document.getElementById('resultWinner');
+        winnerDiv.textContent = Results.describeRoundWinner(result, this.names, this.playerID);
         if (result.winnerId && result.winnerId === this.playerID) {
-            winnerDiv.textContent = 'You won this round!';
             winnerDiv.className = 'result-winner winner';
-        } else if (result.winnerId && result.winnerId > 0) {
-            let opponentName = 'Opponent';
-            if (this.playerIDs.player1 === result.winnerId) {
-                opponentName = this.playerNames.player1;
-            } else if (this.playerIDs.player2 === result.winnerId) {
-                opponentName = this.playerNames.player2;
-            }
-            winnerDiv.textContent = `${opponentName} won this round`;
+        } else if (result.winnerId > 0) {
             winnerDiv.className = 'result-winner loser';
         } else {
-            winnerDiv.textContent = 'Round ended - no winner';
             winnerDiv.className = 'result-winner';
         }
 
@@ -358,39 +425,29 @@ class SpeedTypeClient {
     }
 
     showGameSummary(summary) {
+        this.stopRoundTimer();
         document.querySelector('.game-area').style.display = 'none';
         document.querySelector('.game-header').style.display = 'none';
         document.getElementById('resultsArea').style.display = 'none';
         document.getElementById('statusOverlay').style.display = 'none';
         document.getElementById('gameSummary').style.display = 'block';
 
-        const isPlayer1 = this.playerID === summary.player1Id;
-        const myName = isPlayer1 ? summary.player1Name : summary.player2Name;
-        const opponentName = isPlayer1 ? summary.player2Name : summary.player1Name;
-        const myScore = isPlayer1 ? summary.player1Score : summary.player2Score;
-        const opponentScore = isPlayer1 ? summary.player2Score : summary.player1Score;
-        const myAvgTime = isPlayer1 ? summary.player1AvgTime : summary.player2AvgTime;
-        const opponentAvgTime = isPlayer1 ? summary.player2AvgTime : summary.player1AvgTime;
+        (summary.players || []).forEach(player => {
+            this.names[player.playerId] = player.name;
+        });
 
         const winnerDiv = document.getElementById('summaryWinner');
+        winnerDiv.textContent = Results.describeGameWinner(summary, this.playerID);
         if (summary.winnerId === this.playerID) {
-            winnerDiv.textContent = '🎉 You Won! 🎉';
             winnerDiv.className = 'summary-winner winner';
         } else if (summary.winnerId > 0) {
-            winnerDiv.textContent = `${opponentName} Won!`;
             winnerDiv.className = 'summary-winner loser';
         } else {
-            winnerDiv.textContent = "It's a Tie!";
             winnerDiv.className = 'summary-winner tie';
         }
 
-        document.getElementById('summaryPlayer1Name').textContent = myName;
-        document.getElementById('summaryPlayer1Score').textContent = `Score: ${myScore}`;
-        document.getElementById('summaryPlayer1Avg').textContent = `Avg Time: ${(myAvgTime / 1000).toFixed(2)}s`;
-
-        document.getElementById('summaryPlayer2Name').textContent = opponentName;
-        document.getElementById('summaryPlayer2Score').textContent = `Score: ${opponentScore}`;
-        document.getElementById('summaryPlayer2Avg').textContent = `Avg Time: ${(opponentAvgTime / 1000).toFixed(2)}s`;
+        const formatAvg = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
+        Results.renderStandings(document.getElementById('summaryStandings'), summary.players, this.playerID, formatAvg);
 
         const roundsList = document.getElementById('roundsList');
         roundsList.innerHTML = '';
@@ -399,42 +456,71 @@ class SpeedTypeClient {
             const roundDiv = document.createElement('div');
             roundDiv.className = 'round-item';
             
-            const myTime = isPlayer1 ? round.player1TimeMs : round.player2TimeMs;
-            const oppTime = isPlayer1 ? round.player2TimeMs : round.player1TimeMs;
-            const roundWinner = round.winnerId === this.playerID ? 'You' : 
-                              (round.winnerId > 0 ? opponentName : 'Tie');
+            const formatTime = (t) => t > 0 ? `${(t / 1000).toFixed(2)}s` : '—';
             
             roundDiv.innerHTML = `
                 <div class="round-header">
                     <span class="round-number">Round ${round.roundNumber}</span>
                     <span class="round-word">Word: "${round.word}"</span>
+                    ${round.timedOut ? '<span class="round-timeout">Time\'s up</span>' : ''}
                 </div>
                 <div class="round-times">
-                    <div class="round-time-item">
-                        <span class="round-time-label">${myName}:</span>
-                        <span class="round-time-value">${(myTime / 1000).toFixed(2)}s</span>
-                    </div>
-                    <div class="round-time-item">
-                        <span class="round-time-label">${opponentName}:</span>
-                        <span class="round-time-value">${(oppTime / 1000).toFixed(2)}s</span>
-                    </div>
+                    ${Results.roundTimesHTML(round, this.names, this.playerID, formatTime)}
                 </div>
-                <div class="round-winner">Winner: ${roundWinner}</div>
+                <div class="round-winner">Winner: ${Results.roundWinnerName(round, this.names, this.playerID)}</div>
             `;
             roundsList.appendChild(roundDiv);
         });
         
-        // Setup back to lobby button
-        const backBtn = document.getElementById('backToLobbyBtn');
-        if (backBtn) {
-            backBtn.onclick = () => {
-                // Close WebSocket and redirect to lobby
-                if (this.ws) {
-                    this.ws.close();
-                }
-                window.location.replace('/lobby.html');
+        this.setupRematchButtons();
+    }
+
+    setupRematchButtons() {
+        const playAgainBtn = document.getElementById('playAgainBtn');
+        const backToLobbyBtn = document.getElementById('backToLobbyBtn');
+        if (this.tournament) {
+            // A tournament match is a single game, the winner moves on in the bracket
+            playAgainBtn.style.display = 'none';
+            document.getElementById('rematchStatus').textContent = 'Heading back to the bracket...';
+            backToLobbyBtn.textContent = 'Back to Bracket';
+            backToLobbyBtn.onclick = () => {
+                window.location.replace(`/tournament.html?code=${encodeURIComponent(this.tournament)}`);
             };
+            return;
+        }
+        if (this.series) {
+            // A party series moves on to its next game by itself
+            playAgainBtn.style.display = 'none';
+            document.getElementById('rematchStatus').textContent = Results.describeSeriesNext(this.series);
+            backToLobbyBtn.onclick = () => this.leaveToLobby();
+            return;
+        }
+        playAgainBtn.disabled = false;
+        playAgainBtn.textContent = 'Play Again';
+        playAgainBtn.onclick = () => {
+            this.sendMessage({ type: 'readyForNewGame', ready: true });
+            playAgainBtn.disabled = true;
+            playAgainBtn.textContent = 'Waiting for the others...';
+        };
+        document.getElementById('rematchStatus').textContent = '';
+        backToLobbyBtn.onclick = () => this.leaveToLobby();
+    }
+
+    updateRematchStatus(msg) {
+        if (this.spectator) return;
+        const others = (msg.readyStatus || []).filter(status => status.playerId !== this.playerID);
+        const ready = others.filter(status => status.ready).length;
+        document.getElementById('rematchStatus').textContent =
+            ready > 0 ? `${ready} of ${others.length} other players want a rematch!` : '';
+    }
+
+    leaveToLobby() {
+        // Declining the rematch sends everyone back to the room's lobby
+        this.sendMessage({ type: 'readyForNewGame', ready: false });
+        if (this.ws) {
+            this.ws.close();
         }
+        window.location.replace('/lobby.html');
     }
 }
 
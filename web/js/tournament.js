@@ -0,0 +1,106 @@
+// Tournament page: creates a bracket, or shows one (e.g. on a TV) and
+// refreshes it while matches are played
+class TournamentPage {
+    constructor() {
+        this.code = new URLSearchParams(window.location.search).get('code');
+        this.yourMatch = null;
+
+        if (this.code) {
+            document.getElementById('bracketSection').style.display = 'block';
+            document.getElementById('playMatchBtn').addEventListener('click', () => this.playMatch());
+            this.load();
+            setInterval(() => this.load(), 3000);
+        } else {
+            document.getElementById('createSection').style.display = 'block';
+            document.getElementById('createBtn').addEventListener('click', () => this.create());
+        }
+    }
+
+    async load() {
+        try {
+            const response = await fetch(`/api/tournaments/${encodeURIComponent(this.code)}`);
+            if (!response.ok) {
+                document.getElementById('tournamentMeta').textContent = 'Tournament not found';
+                return;
+            }
+            this.render(await response.json());
+        } catch (error) {
+            console.error('Failed to load tournament:', error);
+        }
+    }
+
+    render(tournament) {
+        document.getElementById('tournamentInfo').style.display = 'block';
+        document.getElementById('tournamentCode').textContent = tournament.code;
+        document.getElementById('tournamentMeta').textContent = Bracket.describe(tournament);
+        document.getElementById('champion').textContent =
+            tournament.champion ? `🏆 ${tournament.champion} wins the tournament!` : '';
+
+        this.yourMatch = tournament.yourMatch || null;
+        document.getElementById('yourMatch').style.display = this.yourMatch ? 'block' : 'none';
+
+        Bracket.render(document.getElementById('bracket'), tournament, this.yourMatch);
+    }
+
+    async create() {
+        const errorDiv = document.getElementById('createError');
+        const players = document.getElementById('tournamentPlayers').value
+            .split('\n')
+            .map(name => name.trim())
+            .filter(name => name);
+        if (players.length < 2) {
+            errorDiv.textContent = 'A tournament needs at least two players';
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/tournaments', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({
+                    gameType: document.getElementById('tournamentGame').value,
+                    players: players,
+                    rules: {
+                        mode: document.getElementById('rulesMode').value,
+                        count: parseInt(document.getElementById('rulesCount').value, 10)
+                    }
+                }),
+            });
+            if (response.status === 401) {
+                errorDiv.textContent = 'Log in first to host a tournament';
+                return;
+            }
+            if (!response.ok) {
+                errorDiv.textContent = await response.text();
+                return;
+            }
+            const tournament = await response.json();
+            window.location.replace(`/tournament.html?code=${encodeURIComponent(tournament.code)}`);
+        } catch (error) {
+            errorDiv.textContent = 'Connection error. Please try again.';
+            console.error('Create tournament error:', error);
+        }
+    }
+
+    // Moves this player's session to their match's room code, then joins its lobby
+    async playMatch() {
+        try {
+            const response = await fetch(`/api/tournaments/${encodeURIComponent(this.code)}/next`, {
+                method: 'POST',
+            });
+            if (response.ok) {
+                window.location.href = '/lobby.html';
+            } else {
+                console.error('No match to play:', await response.text());
+            }
+        } catch (error) {
+            console.error('Play match error:', error);
+        }
+    }
+}
+
+window.addEventListener('DOMContentLoaded', () => {
+    window.tournamentPage = new TournamentPage();
+});